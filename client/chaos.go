@@ -0,0 +1,75 @@
+// Failure injection for chaos testing, only built with the chaos tag
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build chaos
+// +build chaos
+
+package client
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Chaos configures the failures injected into every request of the
+// clients. It must be set before any client is created.
+var Chaos struct {
+	// ErrorRate is the fraction of requests failing with 500
+	ErrorRate float64
+	// Latency is added to every request, plus a random delay of up to
+	// Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+}
+
+type chaosClient struct {
+	hc HTTPClient
+}
+
+func (c *chaosClient) Do(req *http.Request) (*http.Response, error) {
+	d := Chaos.Latency
+	if Chaos.Jitter > 0 {
+		d += time.Duration(rand.Int63n(int64(Chaos.Jitter)))
+	}
+	if d > 0 {
+		select {
+		case <-time.After(d):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	if Chaos.ErrorRate > 0 && rand.Float64() < Chaos.ErrorRate {
+		return &http.Response{
+			Status:     "500 Internal Server Error",
+			StatusCode: http.StatusInternalServerError,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("Injected failure")),
+			Request:    req,
+		}, nil
+	}
+	return c.hc.Do(req)
+}
+
+func chaosWrap(hc HTTPClient) HTTPClient {
+	return &chaosClient{hc}
+}
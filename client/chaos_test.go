@@ -0,0 +1,66 @@
+// Tests of the failure injection
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build chaos
+// +build chaos
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestChaos(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{}`)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		Chaos.ErrorRate = 0
+		Chaos.Latency = 0
+	}()
+
+	c := NewTokenClient(context.Background(), *u, srv.Client(), nil, "tok")
+	var obj struct{}
+
+	Chaos.ErrorRate = 1
+	var ae *APIError
+	if err := c.Get("rest/lssystem", "", &obj); !errors.As(err, &ae) || ae.StatusCode != 500 {
+		t.Errorf("Expected injected 500, got %v", err)
+	}
+
+	Chaos.ErrorRate = 0
+	Chaos.Latency = 20 * time.Millisecond
+	start := time.Now()
+	if err := c.Get("rest/lssystem", "", &obj); err != nil {
+		t.Errorf("Get: %v", err)
+	}
+	if d := time.Since(start); d < Chaos.Latency {
+		t.Errorf("Expected at least %v latency, got %v", Chaos.Latency, d)
+	}
+}
@@ -0,0 +1,247 @@
+// CIM-XML (SMI-S) client for Spectrum Virtualize inventory
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// cimField maps a CIM property to a field of the REST API response
+type cimField struct {
+	Property string
+	// Convert translates the CIM value to the REST API representation
+	Convert func(string) string
+}
+
+// cimCommand emulates a REST API command by enumerating a CIM class
+type cimCommand struct {
+	Class  string
+	Fields map[string]cimField
+}
+
+// cimOperationalStatus translates the first CIM OperationalStatus value
+// to the status strings used by the REST API
+func cimOperationalStatus(v string) string {
+	switch v {
+	case "2": // OK
+		return "online"
+	case "3": // Degraded
+		return "degraded"
+	default:
+		return "offline"
+	}
+}
+
+// cimBytes turns a byte count into a capacity string as used by the REST
+// API
+func cimBytes(v string) string {
+	return v + "B"
+}
+
+// cimCommands lists the commands that can be served over CIM. Only the
+// inventory needed by the collectors listed in CIMCollectors is mapped.
+var cimCommands = map[string]cimCommand{
+	"lsmdiskgrp": {
+		Class: "IBMTSSVC_ConcreteStoragePool",
+		Fields: map[string]cimField{
+			"id":            {Property: "PoolID"},
+			"name":          {Property: "ElementName"},
+			"status":        {Property: "OperationalStatus", Convert: cimOperationalStatus},
+			"capacity":      {Property: "TotalManagedSpace", Convert: cimBytes},
+			"free_capacity": {Property: "RemainingManagedSpace", Convert: cimBytes},
+			"used_capacity": {Property: "UsedCapacity", Convert: cimBytes},
+			"vdisk_count":   {Property: "NumberOfStorageVolumes"},
+		},
+	},
+	"lsdrive": {
+		Class: "IBMTSSVC_DiskDrive",
+		Fields: map[string]cimField{
+			"id":           {Property: "DeviceID"},
+			"status":       {Property: "OperationalStatus", Convert: cimOperationalStatus},
+			"enclosure_id": {Property: "EnclosureID"},
+			"slot_id":      {Property: "SlotID"},
+		},
+	},
+}
+
+// CIMCollectors lists the collectors that work with a CIM client
+var CIMCollectors = []string{"pool", "drive"}
+
+type cimClient struct {
+	tgt       url.URL
+	hc        HTTPClient
+	ctx       context.Context
+	obs       Observer
+	user      string
+	passwd    string
+	namespace string
+}
+
+// NewCIMClient returns a client that emulates the REST API commands needed
+// by the inventory collectors using the CIM-XML (SMI-S) interface of the
+// device, for environments where the REST API is disabled. The target is
+// usually https://<device>:5989.
+func NewCIMClient(ctx context.Context, tgt url.URL, hc HTTPClient, obs Observer, user string, passwd string) SpectrumHTTP {
+	return &cimClient{tgt: tgt, hc: chaosWrap(hc), ctx: ctx, obs: obs, user: user, passwd: passwd, namespace: "root/ibm"}
+}
+
+// CIM-XML response, only the parts needed to read instances
+type cimResponse struct {
+	Error *struct {
+		Code        string `xml:"CODE,attr"`
+		Description string `xml:"DESCRIPTION,attr"`
+	} `xml:"MESSAGE>SIMPLERSP>IMETHODRESPONSE>ERROR"`
+	Instances []struct {
+		Properties []struct {
+			Name  string `xml:"NAME,attr"`
+			Value string `xml:"VALUE"`
+		} `xml:"PROPERTY"`
+		Arrays []struct {
+			Name   string   `xml:"NAME,attr"`
+			Values []string `xml:"VALUE.ARRAY>VALUE"`
+		} `xml:"PROPERTY.ARRAY"`
+	} `xml:"MESSAGE>SIMPLERSP>IMETHODRESPONSE>IRETURNVALUE>VALUE.NAMEDINSTANCE>INSTANCE"`
+}
+
+func (c *cimClient) enumerateInstances(class string) ([]map[string]string, int64, error) {
+	var ns bytes.Buffer
+	for _, n := range strings.Split(c.namespace, "/") {
+		fmt.Fprintf(&ns, `<NAMESPACE NAME="%s"/>`, n)
+	}
+	var cn bytes.Buffer
+	xml.EscapeText(&cn, []byte(class))
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<CIM CIMVERSION="2.0" DTDVERSION="2.0"><MESSAGE ID="1" PROTOCOLVERSION="1.0"><SIMPLEREQ>` +
+		`<IMETHODCALL NAME="EnumerateInstances"><LOCALNAMESPACEPATH>` + ns.String() + `</LOCALNAMESPACEPATH>` +
+		`<IPARAMVALUE NAME="ClassName"><CLASSNAME NAME="` + cn.String() + `"/></IPARAMVALUE>` +
+		`<IPARAMVALUE NAME="LocalOnly"><VALUE>FALSE</VALUE></IPARAMVALUE>` +
+		`</IMETHODCALL></SIMPLEREQ></MESSAGE></CIM>`
+
+	u := c.tgt
+	u.Path = "/cimom"
+	req, err := http.NewRequestWithContext(c.ctx, "POST", u.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.SetBasicAuth(c.user, c.passwd)
+	req.Header.Set("Content-Type", `application/xml; charset="utf-8"`)
+	req.Header.Set("CIMOperation", "MethodCall")
+	req.Header.Set("CIMMethod", "EnumerateInstances")
+	req.Header.Set("CIMObject", url.QueryEscape(c.namespace))
+
+	start := time.Now()
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	observeClock(c.obs, start, resp)
+	if resp.StatusCode != 200 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, 0, &APIError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var r cimResponse
+	if err := xml.Unmarshal(b, &r); err != nil {
+		return nil, 0, err
+	}
+	if r.Error != nil {
+		return nil, 0, fmt.Errorf("CIM error %s: %s", r.Error.Code, r.Error.Description)
+	}
+	var instances []map[string]string
+	for _, i := range r.Instances {
+		props := map[string]string{}
+		for _, p := range i.Properties {
+			props[p.Name] = p.Value
+		}
+		for _, p := range i.Arrays {
+			// Only the first value of e.g. OperationalStatus is of interest
+			if len(p.Values) > 0 {
+				props[p.Name] = p.Values[0]
+			}
+		}
+		instances = append(instances, props)
+	}
+	return instances, int64(len(b)), nil
+}
+
+// getJSON emulates path and returns the result in the JSON format of the
+// REST API
+func (c *cimClient) getJSON(path string) ([]byte, error) {
+	cmd, ok := cimCommands[strings.TrimPrefix(path, "rest/")]
+	if !ok {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Body: fmt.Sprintf("%s is not available over CIM", path)}
+	}
+	instances, n, err := c.enumerateInstances(cmd.Class)
+	if err != nil {
+		return nil, err
+	}
+	if c.obs != nil {
+		c.obs.ObserveResponse(path, n)
+	}
+	objs := []map[string]string{}
+	for _, i := range instances {
+		o := map[string]string{}
+		for f, cf := range cmd.Fields {
+			v, ok := i[cf.Property]
+			if !ok {
+				continue
+			}
+			if cf.Convert != nil {
+				v = cf.Convert(v)
+			}
+			o[f] = v
+		}
+		objs = append(objs, o)
+	}
+	return json.Marshal(objs)
+}
+
+func (c *cimClient) Get(path string, query string, obj interface{}) error {
+	b, err := c.getJSON(path)
+	if err != nil {
+		return err
+	}
+	return decodeError(c.obs, path, b, json.Unmarshal(b, obj))
+}
+
+func (c *cimClient) GetEach(path string, query string, obj interface{}, fn func()) error {
+	b, err := c.getJSON(path)
+	if err != nil {
+		return err
+	}
+	return decodeError(c.obs, path, b, DecodeEach(bytes.NewReader(b), obj, fn))
+}
+
+func (c *cimClient) String() string {
+	return c.tgt.String()
+}
@@ -0,0 +1,94 @@
+// Tests of the CIM-XML client
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const cimPoolResponse = `<?xml version="1.0" encoding="utf-8"?>
+<CIM CIMVERSION="2.0" DTDVERSION="2.0">
+<MESSAGE ID="1" PROTOCOLVERSION="1.0">
+<SIMPLERSP>
+<IMETHODRESPONSE NAME="EnumerateInstances">
+<IRETURNVALUE>
+<VALUE.NAMEDINSTANCE>
+<INSTANCENAME CLASSNAME="IBMTSSVC_ConcreteStoragePool"/>
+<INSTANCE CLASSNAME="IBMTSSVC_ConcreteStoragePool">
+<PROPERTY NAME="PoolID" TYPE="string"><VALUE>0</VALUE></PROPERTY>
+<PROPERTY NAME="ElementName" TYPE="string"><VALUE>Pool0</VALUE></PROPERTY>
+<PROPERTY NAME="TotalManagedSpace" TYPE="uint64"><VALUE>1099511627776</VALUE></PROPERTY>
+<PROPERTY NAME="RemainingManagedSpace" TYPE="uint64"><VALUE>549755813888</VALUE></PROPERTY>
+<PROPERTY.ARRAY NAME="OperationalStatus" TYPE="uint16"><VALUE.ARRAY><VALUE>3</VALUE><VALUE>32768</VALUE></VALUE.ARRAY></PROPERTY.ARRAY>
+</INSTANCE>
+</VALUE.NAMEDINSTANCE>
+</IRETURNVALUE>
+</IMETHODRESPONSE>
+</SIMPLERSP>
+</MESSAGE>
+</CIM>`
+
+func TestCIMClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != "user" || p != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path != "/cimom" || r.Header.Get("CIMMethod") != "EnumerateInstances" ||
+			!strings.Contains(string(b), `<CLASSNAME NAME="IBMTSSVC_ConcreteStoragePool"/>`) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, cimPoolResponse)
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCIMClient(context.Background(), *u, srv.Client(), nil, "user", "pass")
+
+	type pool struct {
+		ID           string
+		Name         string
+		Status       string
+		Capacity     string
+		FreeCapacity string `json:"free_capacity"`
+	}
+	var pools []pool
+	if err := c.Get("rest/lsmdiskgrp", "", &pools); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	want := pool{ID: "0", Name: "Pool0", Status: "degraded", Capacity: "1099511627776B", FreeCapacity: "549755813888B"}
+	if len(pools) != 1 || pools[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, pools)
+	}
+
+	if err := c.Get("rest/lsvdisk", "", &pools); !IsUnsupported(err) {
+		t.Errorf("Expected unsupported error, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Common interface of the Spectrum Virtualize API clients
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SpectrumHTTP is an authenticated connection to a Spectrum Virtualize
+// REST API.
+type SpectrumHTTP interface {
+	// Get calls the command at path, e.g. "rest/lsvdisk", and decodes the
+	// JSON response into obj, which is typically a pointer to a slice of
+	// structs with json tags matching the CLI field names. The URL-encoded
+	// query holds the parameters of the command, e.g. "filtervalue=...".
+	// Backends that cannot pass them on return all objects, so callers
+	// must still check the objects they get.
+	Get(path string, query string, obj interface{}) error
+	// GetEach decodes the array returned by path one element at a time into
+	// obj, calling fn after each element. Use it for endpoints that may return
+	// tens of thousands of rows to avoid holding the whole response in memory.
+	GetEach(path string, query string, obj interface{}, fn func()) error
+}
+
+// HTTPClient is the subset of *http.Client used by the API clients.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Observer is notified about every successful API response together with
+// the size of the payload in bytes.
+type Observer interface {
+	ObserveResponse(path string, size int64)
+}
+
+// ClockObserver may be implemented by an Observer to be notified about the
+// offset of the device clock to the local clock, estimated from the Date
+// header of every response. A positive offset means the device is ahead.
+type ClockObserver interface {
+	ObserveClockOffset(offset time.Duration)
+}
+
+// observeClock estimates the clock offset from the Date header of resp,
+// received for a request sent at start. The header has a resolution of a
+// second, so its midpoint is compared with the midpoint of the request.
+func observeClock(obs Observer, start time.Time, resp *http.Response) {
+	co, ok := obs.(ClockObserver)
+	if !ok {
+		return
+	}
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	mid := start.Add(now.Sub(start) / 2)
+	co.ObserveClockOffset(date.Add(500 * time.Millisecond).Sub(mid))
+}
+
+// APIError is returned when the REST API responds with a non-200 status
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Response code was %d, expected 200", e.StatusCode)
+}
+
+// LoginError is returned when a login is rejected, e.g. for wrong
+// credentials, to tell it apart from the target being unreachable
+type LoginError struct {
+	// Request is what was rejected, e.g. "Login"
+	Request    string
+	StatusCode int
+	Expected   int
+}
+
+func (e *LoginError) Error() string {
+	return fmt.Sprintf("%s code was %d, expected %d", e.Request, e.StatusCode, e.Expected)
+}
+
+// IsUnsupported returns true if err signals that the endpoint is not
+// available on the device, e.g. because the firmware is too old.
+func IsUnsupported(err error) bool {
+	var ae *APIError
+	if !errors.As(err, &ae) {
+		return false
+	}
+	// CMMVC7205E The command failed because it is not supported.
+	return ae.StatusCode == http.StatusNotFound || strings.Contains(ae.Body, "CMMVC7205E")
+}
+
+// errNotArray is returned by DecodeEach if the response is not an array
+var errNotArray = errors.New("Expected JSON array")
+
+// DecodeEach stream-decodes a JSON array from r into obj, which is reset to
+// its zero value before each element.
+func DecodeEach(r io.Reader, obj interface{}, fn func()) error {
+	return decodeEach(r, obj, fn, nil)
+}
+
+// decodeEach is DecodeEach, passing the fields of the first element to
+// fields if not nil
+func decodeEach(r io.Reader, obj interface{}, fn func(), fields func([]string)) error {
+	dec := json.NewDecoder(r)
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != '[' {
+		return fmt.Errorf("%w, got %v", errNotArray, t)
+	}
+	v := reflect.ValueOf(obj).Elem()
+	zero := reflect.Zero(v.Type())
+	for first := true; dec.More(); first = false {
+		v.Set(zero)
+		if first && fields != nil {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+			fields(objectFields(raw))
+			if err := json.Unmarshal(raw, obj); err != nil {
+				return err
+			}
+		} else if err := dec.Decode(obj); err != nil {
+			return err
+		}
+		fn()
+	}
+	_, err = dec.Token()
+	return err
+}
@@ -0,0 +1,137 @@
+// Errors decoding the responses of the REST API
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Debug enables logging of a redacted snippet of every response that fails
+// to decode. It must be set before any client is created.
+var Debug bool
+
+// snippetSize is the number of bytes of a response kept for DecodeError
+const snippetSize = 512
+
+// DecodeErrorObserver may be implemented by an Observer to be notified
+// about responses that could not be decoded.
+type DecodeErrorObserver interface {
+	ObserveDecodeError(path string)
+}
+
+// DecodeError is returned when the response of path is not the JSON
+// expected by the caller.
+type DecodeError struct {
+	Path string
+	// Snippet is the start of the response with all string values redacted
+	Snippet string
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("Failed to decode response of %s: %v", e.Path, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// stringRE matches the JSON strings, including one cut off at the end of
+// the snippet
+var stringRE = regexp.MustCompile(`"(?:[^"\\]|\\.)*("|$)`)
+
+// keyRE matches what follows the key of a JSON object
+var keyRE = regexp.MustCompile(`^\s*:`)
+
+// redact truncates b and replaces the strings in it, which carry names,
+// addresses and serial numbers, but not the keys of objects. This keeps the
+// structure of the response to tell what the caller tripped over. Responses
+// that are not JSON at all, e.g. the HTML error page of a proxy, are
+// replaced entirely.
+func redact(b []byte) string {
+	if len(b) > snippetSize {
+		b = b[:snippetSize]
+	}
+	s := strings.TrimLeft(string(b), " \t\r\n")
+	if s == "" {
+		return ""
+	}
+	if s[0] != '{' && s[0] != '[' {
+		return "<redacted non-JSON response>"
+	}
+	var r strings.Builder
+	last := 0
+	for _, m := range stringRE.FindAllStringIndex(s, -1) {
+		r.WriteString(s[last:m[0]])
+		if keyRE.MatchString(s[m[1]:]) {
+			r.WriteString(s[m[0]:m[1]])
+		} else {
+			r.WriteString(`"<redacted>"`)
+		}
+		last = m[1]
+	}
+	r.WriteString(s[last:])
+	return r.String()
+}
+
+// isDecodeError returns true if err was caused by the payload rather than
+// by reading it
+func isDecodeError(err error) bool {
+	var se *json.SyntaxError
+	var te *json.UnmarshalTypeError
+	return errors.As(err, &se) || errors.As(err, &te) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, errNotArray)
+}
+
+// decodeError wraps err into a DecodeError if it was caused by the payload,
+// of which b is the start, and notifies obs about it.
+func decodeError(obs Observer, path string, b []byte, err error) error {
+	if err == nil || !isDecodeError(err) {
+		return err
+	}
+	de := &DecodeError{Path: path, Snippet: redact(b), Err: err}
+	if do, ok := obs.(DecodeErrorObserver); ok {
+		do.ObserveDecodeError(path)
+	}
+	if Debug {
+		log.Printf("Debug: Response of %s failed to decode, starting with: %s", path, de.Snippet)
+	}
+	return de
+}
+
+// prefixReader keeps the first snippetSize bytes read through it
+type prefixReader struct {
+	r      io.Reader
+	prefix []byte
+}
+
+func (pr *prefixReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if m := snippetSize - len(pr.prefix); m > 0 {
+		if m > n {
+			m = n
+		}
+		pr.prefix = append(pr.prefix, p[:m]...)
+	}
+	return n, err
+}
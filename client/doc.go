@@ -0,0 +1,43 @@
+// Package documentation of the Spectrum Virtualize REST API client
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package client implements an authenticated client for the IBM Spectrum
+// Virtualize REST API, as used by the exporter. It can be used to build
+// other tools, e.g. capacity reports or configuration backups, on the same
+// code base.
+//
+// A client is created either by logging in with a user and password using
+// NewPasswordClient, or from a pre-shared token using NewTokenClient.
+// Clients created with a password log in again transparently when their
+// session token expires.
+//
+// Responses are decoded into caller-supplied types:
+//
+//	type vdisk struct {
+//		ID       string `json:"id"`
+//		Name     string `json:"name"`
+//		Capacity string `json:"capacity"`
+//	}
+//	var vdisks []vdisk
+//	if err := c.Get("rest/lsvdisk", "", &vdisks); err != nil {
+//		return err
+//	}
+//
+// Endpoints returning large arrays can be stream-decoded with GetEach.
+// Errors returned by the device are of type *APIError; IsUnsupported tells
+// whether a command is unavailable on the device's firmware.
+package client
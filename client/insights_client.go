@@ -0,0 +1,260 @@
+// IBM Storage Insights client for Spectrum Virtualize inventory
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// insightsField maps a property of a Storage Insights resource to a field
+// of the REST API response
+type insightsField struct {
+	Property string
+	// Convert translates the Storage Insights value to the REST API
+	// representation
+	Convert func(string) string
+}
+
+// insightsCommand emulates a REST API command by listing a resource of the
+// storage system in Storage Insights
+type insightsCommand struct {
+	Resource string
+	Fields   map[string]insightsField
+}
+
+// insightsStatus translates the condensed status of Storage Insights to
+// the status strings used by the REST API
+func insightsStatus(v string) string {
+	switch strings.ToLower(v) {
+	case "normal":
+		return "online"
+	case "warning":
+		return "degraded"
+	default:
+		return "offline"
+	}
+}
+
+// insightsGiB turns a capacity in GiB, as reported by Storage Insights,
+// into a capacity string as used by the REST API
+func insightsGiB(v string) string {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	return fmt.Sprintf("%.0fB", f*(1<<30))
+}
+
+// insightsCommands lists the commands that can be served from Storage
+// Insights. Only the inventory needed by the collectors listed in
+// InsightsCollectors is mapped.
+var insightsCommands = map[string]insightsCommand{
+	"lsmdiskgrp": {
+		Resource: "pools",
+		Fields: map[string]insightsField{
+			"id":            {Property: "pool_id"},
+			"name":          {Property: "name"},
+			"status":        {Property: "status", Convert: insightsStatus},
+			"capacity":      {Property: "capacity", Convert: insightsGiB},
+			"free_capacity": {Property: "available_capacity", Convert: insightsGiB},
+			"used_capacity": {Property: "used_capacity", Convert: insightsGiB},
+			"vdisk_count":   {Property: "volumes"},
+		},
+	},
+	"lsdrive": {
+		Resource: "drives",
+		Fields: map[string]insightsField{
+			"id":           {Property: "drive_id"},
+			"status":       {Property: "status", Convert: insightsStatus},
+			"enclosure_id": {Property: "enclosure_id"},
+			"slot_id":      {Property: "slot_id"},
+		},
+	},
+}
+
+// InsightsCollectors lists the collectors that work with a Storage
+// Insights client
+var InsightsCollectors = []string{"pool", "drive"}
+
+// DefaultInsightsURL is the Storage Insights REST API used if none is given
+const DefaultInsightsURL = "https://insights.ibm.com"
+
+type insightsClient struct {
+	base   url.URL
+	hc     HTTPClient
+	ctx    context.Context
+	obs    Observer
+	tenant string
+	system string
+	apiKey string
+	tok    string
+}
+
+// NewInsightsClient returns a client that emulates the REST API commands
+// needed by the inventory collectors with the data IBM Storage Insights
+// has collected about the storage system with the given ID, for
+// environments where the device itself may not be accessed. It requests a
+// token for the tenant with apiKey before returning.
+func NewInsightsClient(ctx context.Context, base url.URL, hc HTTPClient, obs Observer, tenant string, system string, apiKey string) (SpectrumHTTP, error) {
+	c := &insightsClient{base: base, hc: chaosWrap(hc), ctx: ctx, obs: obs, tenant: tenant, system: system, apiKey: apiKey}
+	if err := c.login(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *insightsClient) login() error {
+	u := c.base
+	u.Path = "/restapi/v1/tenants/" + url.PathEscape(c.tenant) + "/token"
+	r, err := http.NewRequestWithContext(c.ctx, "POST", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	r.Header.Add("x-api-key", c.apiKey)
+	resp, err := c.hc.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 && resp.StatusCode != 201 {
+		return &LoginError{Request: "Storage Insights token request", StatusCode: resp.StatusCode, Expected: 201}
+	}
+
+	type token struct {
+		Result struct {
+			Token string
+		}
+	}
+	var obj token
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	if obj.Result.Token == "" {
+		return fmt.Errorf("Storage Insights returned no token")
+	}
+	c.tok = obj.Result.Token
+	return nil
+}
+
+// list returns the items of resource of the storage system
+func (c *insightsClient) list(resource string) ([]map[string]interface{}, int64, error) {
+	u := c.base
+	u.Path = "/restapi/v1/tenants/" + url.PathEscape(c.tenant) + "/storage-systems/" + url.PathEscape(c.system) + "/" + resource
+	req, err := http.NewRequestWithContext(c.ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("x-api-token", c.tok)
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	observeClock(c.obs, start, resp)
+	if resp.StatusCode != 200 {
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, 0, &APIError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	type page struct {
+		Data []map[string]interface{}
+	}
+	var p page
+	dec := json.NewDecoder(bytes.NewReader(b))
+	// Keep the numbers as they were sent, e.g. IDs should not become floats
+	dec.UseNumber()
+	if err := dec.Decode(&p); err != nil {
+		return nil, 0, err
+	}
+	return p.Data, int64(len(b)), nil
+}
+
+// getJSON emulates path and returns the result in the JSON format of the
+// REST API
+func (c *insightsClient) getJSON(path string) ([]byte, error) {
+	cmd, ok := insightsCommands[strings.TrimPrefix(path, "rest/")]
+	if !ok {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Body: fmt.Sprintf("%s is not available from Storage Insights", path)}
+	}
+	items, n, err := c.list(cmd.Resource)
+	if err != nil {
+		return nil, err
+	}
+	if c.obs != nil {
+		c.obs.ObserveResponse(path, n)
+	}
+	objs := []map[string]string{}
+	for _, i := range items {
+		o := map[string]string{}
+		for f, sf := range cmd.Fields {
+			v, ok := i[sf.Property]
+			if !ok || v == nil {
+				continue
+			}
+			s := fmt.Sprint(v)
+			if sf.Convert != nil {
+				s = sf.Convert(s)
+			}
+			o[f] = s
+		}
+		objs = append(objs, o)
+	}
+	return json.Marshal(objs)
+}
+
+func (c *insightsClient) Get(path string, query string, obj interface{}) error {
+	b, err := c.getJSON(path)
+	if err != nil {
+		return err
+	}
+	return decodeError(c.obs, path, b, json.Unmarshal(b, obj))
+}
+
+func (c *insightsClient) GetEach(path string, query string, obj interface{}, fn func()) error {
+	b, err := c.getJSON(path)
+	if err != nil {
+		return err
+	}
+	return decodeError(c.obs, path, b, DecodeEach(bytes.NewReader(b), obj, fn))
+}
+
+func (c *insightsClient) String() string {
+	return c.base.String() + "/" + c.system
+}
@@ -0,0 +1,98 @@
+// Tests of the Storage Insights client
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+const insightsPoolResponse = `{
+  "data": [
+    {
+      "pool_id": 0,
+      "name": "Pool0",
+      "status": "Warning",
+      "capacity": 1024,
+      "available_capacity": 512.5,
+      "used_capacity": 511.5,
+      "volumes": 12,
+      "last_data_collection": null
+    }
+  ]
+}`
+
+func TestInsightsClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/restapi/v1/tenants/t1/token":
+			if r.Method != "POST" || r.Header.Get("x-api-key") != "key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"result": {"token": "tok", "expiration": 1600000000000}}`)
+		case "/restapi/v1/tenants/t1/storage-systems/s1/pools":
+			if r.Header.Get("x-api-token") != "tok" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, insightsPoolResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewInsightsClient(context.Background(), *u, srv.Client(), nil, "t1", "s1", "wrong"); err == nil {
+		t.Errorf("Expected error for wrong API key")
+	}
+	c, err := NewInsightsClient(context.Background(), *u, srv.Client(), nil, "t1", "s1", "key")
+	if err != nil {
+		t.Fatalf("NewInsightsClient: %v", err)
+	}
+
+	type pool struct {
+		ID           string
+		Name         string
+		Status       string
+		Capacity     string
+		FreeCapacity string `json:"free_capacity"`
+		VDiskCount   string `json:"vdisk_count"`
+	}
+	var pools []pool
+	if err := c.Get("rest/lsmdiskgrp", "", &pools); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	want := pool{ID: "0", Name: "Pool0", Status: "degraded", Capacity: "1099511627776B", FreeCapacity: "550292684800B", VDiskCount: "12"}
+	if len(pools) != 1 || pools[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, pools)
+	}
+
+	if err := c.Get("rest/lsvdisk", "", &pools); !IsUnsupported(err) {
+		t.Errorf("Expected unsupported error, got %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+// HTTP client for Spectrum Virtualize API using user/password authentication
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// LoginOptions adapt the login to setups differing from the defaults, e.g.
+// remote users authenticated through LDAP or Kerberos that need a domain
+// qualified user name. The zero value gives the defaults.
+type LoginOptions struct {
+	// UserHeader carries the user name, X-Auth-Username if empty
+	UserHeader string
+	// PasswordHeader carries the password, X-Auth-Password if empty
+	PasswordHeader string
+	// TokenHeader carries the session token, X-Auth-Token if empty
+	TokenHeader string
+	// UserFormat is the user name sent with {user} replaced by the user,
+	// e.g. {user}@example.com or EXAMPLE\{user}; the plain user if empty
+	UserFormat string
+	// Tokens, if set, keeps the session token for other clients to reuse
+	Tokens TokenStore
+}
+
+func headerOrDefault(h string, def string) string {
+	if h == "" {
+		return def
+	}
+	return h
+}
+
+func (o LoginOptions) user(user string) string {
+	if o.UserFormat == "" {
+		return user
+	}
+	return strings.Replace(o.UserFormat, "{user}", user, -1)
+}
+
+type spectrumPasswordClient struct {
+	tgt    url.URL
+	hc     HTTPClient
+	ctx    context.Context
+	tok    string
+	obs    Observer
+	user   string
+	passwd string
+	opts   LoginOptions
+	// REST API version to request, empty for the unversioned API
+	version string
+}
+
+func (c *spectrumPasswordClient) newPostRequest(url string, body io.Reader) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(c.ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Add(headerOrDefault(c.opts.TokenHeader, "X-Auth-Token"), c.tok)
+	return r, nil
+}
+
+// commandBody returns the JSON body passing the URL-encoded query to the
+// command as its parameters, e.g. "filtervalue=status%3Doffline" becomes
+// {"filtervalue": "status=offline"}
+func commandBody(query string) (io.Reader, error) {
+	v, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]string{}
+	for k := range v {
+		params[k] = v.Get(k)
+	}
+	b, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(b), nil
+}
+
+func (c *spectrumPasswordClient) do(path string, query string) (*http.Response, error) {
+	resp, err := c.doOnce(path, query)
+	var ae *APIError
+	if c.user != "" && errors.As(err, &ae) && ae.StatusCode == http.StatusForbidden {
+		// The session token has most likely expired, log in again and retry
+		if err := c.login(); err != nil {
+			return nil, err
+		}
+		return c.doOnce(path, query)
+	}
+	return resp, err
+}
+
+func (c *spectrumPasswordClient) doOnce(path string, query string) (*http.Response, error) {
+	u := c.tgt
+	u.Path = versionedPath(path, c.version)
+
+	var body io.Reader
+	if query != "" {
+		b, err := commandBody(query)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	req, err := c.newPostRequest(u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	req = req.WithContext(c.ctx)
+	start := time.Now()
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	observeClock(c.obs, start, resp)
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		// Keep the start of the body, it carries the CMMVC error message
+		b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &APIError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	return resp, nil
+}
+
+func (c *spectrumPasswordClient) observeResponse(path string, n int64) {
+	if c.obs != nil {
+		c.obs.ObserveResponse(path, n)
+	}
+}
+
+func (c *spectrumPasswordClient) Get(path string, query string, obj interface{}) error {
+	resp, err := c.do(path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	c.observeResponse(path, int64(len(b)))
+	if err := json.Unmarshal(b, obj); err != nil {
+		return decodeError(c.obs, path, b, err)
+	}
+	observeSchema(c.obs, path, responseFields(b))
+	return nil
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (c *spectrumPasswordClient) GetEach(path string, query string, obj interface{}, fn func()) error {
+	resp, err := c.do(path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	cr := &countingReader{r: resp.Body}
+	pr := &prefixReader{r: cr}
+	err = decodeEach(pr, obj, fn, func(fields []string) { observeSchema(c.obs, path, fields) })
+	c.observeResponse(path, cr.n)
+	return decodeError(c.obs, path, pr.prefix, err)
+}
+
+func (c *spectrumPasswordClient) SetAPIVersion(version string) {
+	c.version = version
+}
+
+func (c *spectrumPasswordClient) String() string {
+	return c.tgt.String()
+}
+
+func (c *spectrumPasswordClient) login() error {
+	u := c.tgt
+	u.Path = "/rest/auth"
+	r, err := http.NewRequestWithContext(c.ctx, "POST", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	r.Header.Add(headerOrDefault(c.opts.UserHeader, "X-Auth-Username"), c.opts.user(c.user))
+	r.Header.Add(headerOrDefault(c.opts.PasswordHeader, "X-Auth-Password"), c.passwd)
+	resp, err := c.hc.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return &LoginError{Request: "Login", StatusCode: resp.StatusCode, Expected: 200}
+	}
+
+	type login struct {
+		Token string
+	}
+	var obj login
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+	c.tok = obj.Token
+	if c.opts.Tokens != nil {
+		c.opts.Tokens.SetToken(c.tokenKey(), c.tok)
+	}
+	return nil
+}
+
+// tokenKey identifies the session in the token store
+func (c *spectrumPasswordClient) tokenKey() string {
+	return c.user + "@" + c.tgt.String()
+}
+
+// NewPasswordClient logs in to the device at tgt with the given user and
+// password and returns a client using the resulting session token. The
+// client logs in again if the token expires.
+func NewPasswordClient(ctx context.Context, tgt url.URL, hc HTTPClient, obs Observer, user string, passwd string) (SpectrumHTTP, error) {
+	return NewPasswordClientWithOptions(ctx, tgt, hc, obs, user, passwd, LoginOptions{})
+}
+
+// NewPasswordClientWithOptions is like NewPasswordClient, but logs in
+// according to opts.
+func NewPasswordClientWithOptions(ctx context.Context, tgt url.URL, hc HTTPClient, obs Observer, user string, passwd string, opts LoginOptions) (SpectrumHTTP, error) {
+	c := &spectrumPasswordClient{tgt: tgt, hc: chaosWrap(hc), ctx: ctx, obs: obs, user: user, passwd: passwd, opts: opts}
+	if opts.Tokens != nil {
+		// Continue the stored session, if it expired the client logs in
+		// again on the first request
+		if tok := opts.Tokens.Token(c.tokenKey()); tok != "" {
+			c.tok = tok
+			return c, nil
+		}
+	}
+	if err := c.login(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewTokenClient returns a client that uses a pre-shared token instead of
+// logging in through /rest/auth.
+func NewTokenClient(ctx context.Context, tgt url.URL, hc HTTPClient, obs Observer, tok string) SpectrumHTTP {
+	return &spectrumPasswordClient{tgt: tgt, hc: chaosWrap(hc), ctx: ctx, tok: tok, obs: obs}
+}
@@ -0,0 +1,379 @@
+// Tests of the Spectrum Virtualize API client
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPasswordClientRelogin(t *testing.T) {
+	logins := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/auth":
+			if r.Header.Get("X-Auth-Username") != "user" || r.Header.Get("X-Auth-Password") != "pass" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			logins++
+			fmt.Fprintf(w, `{"token": "tok%d"}`, logins)
+		case "/rest/lsvdisk":
+			// Only the second token is valid, simulating an expired session
+			if r.Header.Get("X-Auth-Token") != "tok2" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `[{"id": "0", "name": "vdisk0"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewPasswordClient(context.Background(), *u, srv.Client(), nil, "user", "pass")
+	if err != nil {
+		t.Fatalf("NewPasswordClient: %v", err)
+	}
+
+	type vdisk struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	var vdisks []vdisk
+	if err := c.Get("rest/lsvdisk", "", &vdisks); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if logins != 2 {
+		t.Errorf("Expected 2 logins, got %d", logins)
+	}
+	if len(vdisks) != 1 || vdisks[0].Name != "vdisk0" {
+		t.Errorf("Unexpected response %+v", vdisks)
+	}
+
+	err = c.Get("rest/lsfoo", "", &vdisks)
+	if !IsUnsupported(err) {
+		t.Errorf("Expected unsupported error, got %v", err)
+	}
+}
+
+func TestPasswordClientLoginOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/auth":
+			if r.Header.Get("X-Remote-User") != `EXAMPLE\user` || r.Header.Get("X-Remote-Password") != "pass" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token": "tok"}`)
+		case "/rest/lssystem":
+			if r.Header.Get("X-Remote-Token") != "tok" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"name": "system"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewPasswordClientWithOptions(context.Background(), *u, srv.Client(), nil, "user", "pass", LoginOptions{
+		UserHeader:     "X-Remote-User",
+		PasswordHeader: "X-Remote-Password",
+		TokenHeader:    "X-Remote-Token",
+		UserFormat:     `EXAMPLE\{user}`,
+	})
+	if err != nil {
+		t.Fatalf("NewPasswordClientWithOptions: %v", err)
+	}
+	var st struct {
+		Name string `json:"name"`
+	}
+	if err := c.Get("rest/lssystem", "", &st); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+}
+
+type clockObserver struct {
+	offsets []time.Duration
+}
+
+func (o *clockObserver) ObserveResponse(path string, size int64) {}
+
+func (o *clockObserver) ObserveClockOffset(offset time.Duration) {
+	o.offsets = append(o.offsets, offset)
+}
+
+func TestClockOffset(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The device clock is an hour ahead
+		w.Header().Set("Date", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		fmt.Fprint(w, `{"name": "system"}`)
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs := &clockObserver{}
+	c := NewTokenClient(context.Background(), *u, srv.Client(), obs, "tok")
+	var st struct {
+		Name string `json:"name"`
+	}
+	if err := c.Get("rest/lssystem", "", &st); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(obs.offsets) != 1 {
+		t.Fatalf("Expected 1 clock offset, got %d", len(obs.offsets))
+	}
+	if d := obs.offsets[0] - time.Hour; d < -time.Second || d > time.Second {
+		t.Errorf("Expected an offset of about an hour, got %v", obs.offsets[0])
+	}
+}
+
+func TestCommandParameters(t *testing.T) {
+	var params map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Errorf("Failed to decode the request body: %v", err)
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewTokenClient(context.Background(), *u, srv.Client(), nil, "tok")
+	var st []struct{}
+	q := url.Values{"filtervalue": {"fixed=no:event_id=060001"}}.Encode()
+	if err := c.Get("rest/lseventlog", q, &st); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	want := map[string]string{"filtervalue": "fixed=no:event_id=060001"}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("Expected the parameters %v, got %v", want, params)
+	}
+}
+
+type decodeErrorObserver struct {
+	paths []string
+}
+
+func (o *decodeErrorObserver) ObserveResponse(path string, size int64) {}
+
+func (o *decodeErrorObserver) ObserveDecodeError(path string) {
+	o.paths = append(o.paths, path)
+}
+
+func TestDecodeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id": "0", "name": "secret-name", "capacity": 5}]`)
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs := &decodeErrorObserver{}
+	c := NewTokenClient(context.Background(), *u, srv.Client(), obs, "tok")
+	var vs []struct {
+		Capacity string `json:"capacity"`
+	}
+	var v struct {
+		Capacity string `json:"capacity"`
+	}
+	errs := []error{
+		c.Get("rest/lsvdisk", "", &vs),
+		c.GetEach("rest/lsvdisk", "", &v, func() {}),
+	}
+	for _, err := range errs {
+		var de *DecodeError
+		if !errors.As(err, &de) {
+			t.Fatalf("Expected a DecodeError, got %v", err)
+		}
+		if de.Path != "rest/lsvdisk" {
+			t.Errorf("Expected the error for rest/lsvdisk, got %q", de.Path)
+		}
+		if want := `[{"id": "<redacted>", "name": "<redacted>", "capacity": 5}]`; de.Snippet != want {
+			t.Errorf("Expected snippet %q, got %q", want, de.Snippet)
+		}
+		var te *json.UnmarshalTypeError
+		if !errors.As(err, &te) {
+			t.Errorf("Expected the DecodeError to wrap the JSON error, got %v", de.Err)
+		}
+	}
+	if len(obs.paths) != 2 {
+		t.Errorf("Expected 2 observed decode errors, got %v", obs.paths)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	for in, want := range map[string]string{
+		`{"names": ["secret-1", "secret\"2"], "id" : "3"}`: `{"names": ["<redacted>", "<redacted>"], "id" : "<redacted>"}`,
+		`  ["secret-1", 2, "secr`:                          `["<redacted>", 2, "<redacted>"`,
+		`<html><title>secret-proxy</title></html>`:         `<redacted non-JSON response>`,
+		`Service Unavailable on secret-host`:               `<redacted non-JSON response>`,
+		``:                                                 ``,
+	} {
+		if got := redact([]byte(in)); got != want {
+			t.Errorf("redact(%q) = %q, expected %q", in, got, want)
+		}
+	}
+}
+
+type schemaObserver struct {
+	schemas map[string][]string
+}
+
+func (o *schemaObserver) ObserveResponse(path string, size int64) {}
+
+func (o *schemaObserver) ObserveSchema(path string, fields []string) {
+	o.schemas[path] = fields
+}
+
+func TestSchema(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/lsvdisk":
+			fmt.Fprint(w, `[{"name": "a", "id": "0"}, {"name": "b", "id": "1", "extra": "x"}]`)
+		case "/rest/lssystem":
+			fmt.Fprint(w, `{"name": "v7k", "id": "0", "code_level": "8.4.0.0"}`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs := &schemaObserver{schemas: map[string][]string{}}
+	c := NewTokenClient(context.Background(), *u, srv.Client(), obs, "tok")
+	var v struct {
+		ID string
+	}
+	var vs []struct {
+		ID string
+	}
+	if err := c.GetEach("rest/lsvdisk", "", &v, func() {}); err != nil {
+		t.Fatalf("GetEach: %v", err)
+	}
+	if err := c.Get("rest/lssystem", "", &v); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := c.Get("rest/lsdrive", "", &vs); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	// Only the first element of an array is looked at
+	want := map[string][]string{
+		"rest/lsvdisk":  {"id", "name"},
+		"rest/lssystem": {"code_level", "id", "name"},
+	}
+	if !reflect.DeepEqual(obs.schemas, want) {
+		t.Errorf("Expected schemas %v, got %v", want, obs.schemas)
+	}
+	if h := SchemaHash(want["rest/lsvdisk"]); len(h) != 8 || h == SchemaHash(want["rest/lssystem"]) {
+		t.Errorf("Unexpected schema hash %q", h)
+	}
+}
+
+func TestNegotiateAPIVersion(t *testing.T) {
+	for _, tc := range []struct {
+		versioned bool
+		want      string
+	}{
+		{versioned: true, want: "v1"},
+		{versioned: false, want: APIVersionLatest},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/rest/lssystem":
+				fmt.Fprint(w, `{"name": "unversioned"}`)
+			case "/rest/v1/lssystem":
+				if !tc.versioned {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				fmt.Fprint(w, `{"name": "v1"}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		u, err := url.Parse(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := NewTokenClient(context.Background(), *u, srv.Client(), nil, "tok")
+		v, err := NegotiateAPIVersion(c, APIVersionAuto)
+		if err != nil {
+			t.Fatalf("NegotiateAPIVersion: %v", err)
+		}
+		if v != tc.want {
+			t.Errorf("Expected version %q, got %q", tc.want, v)
+		}
+		var st struct {
+			Name string `json:"name"`
+		}
+		if err := c.Get("rest/lssystem", "", &st); err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		if want := map[bool]string{true: "v1", false: "unversioned"}[tc.versioned]; st.Name != want {
+			t.Errorf("Expected response from %q, got %q", want, st.Name)
+		}
+		srv.Close()
+	}
+}
+
+func TestValidAPIVersion(t *testing.T) {
+	for v, want := range map[string]bool{
+		"":      true,
+		"auto":  true,
+		"v1":    true,
+		"v2":    false,
+		"../x":  false,
+		"v1?id": false,
+	} {
+		if got := ValidAPIVersion(v); got != want {
+			t.Errorf("ValidAPIVersion(%q) = %v, expected %v", v, got, want)
+		}
+	}
+}
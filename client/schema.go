@@ -0,0 +1,83 @@
+// Field sets of the responses of the REST API
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// SchemaObserver may be implemented by an Observer to be notified about
+// the fields of the objects returned by every response. Responses without
+// any object, such as empty arrays, are not observed.
+type SchemaObserver interface {
+	ObserveSchema(path string, fields []string)
+}
+
+// SchemaHash returns a short hash identifying the set of fields, to tell
+// apart the schemas of an endpoint across firmware levels
+func SchemaHash(fields []string) string {
+	h := sha256.Sum256([]byte(strings.Join(fields, ",")))
+	return hex.EncodeToString(h[:4])
+}
+
+// objectFields returns the sorted keys of the JSON object b, or nil if b
+// is not an object
+func objectFields(b []byte) []string {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil
+	}
+	fields := make([]string, 0, len(m))
+	for k := range m {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// responseFields returns the fields of the object b, or of the first
+// element if b is an array
+func responseFields(b []byte) []string {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	t, err := dec.Token()
+	if err != nil {
+		return nil
+	}
+	if d, ok := t.(json.Delim); !ok || d != '[' {
+		return objectFields(b)
+	}
+	if !dec.More() {
+		return nil
+	}
+	var first json.RawMessage
+	if err := dec.Decode(&first); err != nil {
+		return nil
+	}
+	return objectFields(first)
+}
+
+func observeSchema(obs Observer, path string, fields []string) {
+	if so, ok := obs.(SchemaObserver); ok && fields != nil {
+		so.ObserveSchema(path, fields)
+	}
+}
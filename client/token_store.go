@@ -0,0 +1,137 @@
+// Persistence of session tokens between clients and restarts
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TokenStore keeps the session tokens of password clients, so that a new
+// client can continue the session of a previous one instead of logging in.
+type TokenStore interface {
+	// Token returns the stored token for key, or "" if there is none
+	Token(key string) string
+	SetToken(key string, tok string)
+}
+
+// FileTokenStore is a TokenStore persisted to a file encrypted with
+// AES-GCM, surviving restarts of the exporter.
+type FileTokenStore struct {
+	path string
+	aead cipher.AEAD
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// NewFileTokenStore returns a store persisted at path and encrypted with a
+// key derived from secret. An unreadable file, e.g. written with another
+// secret, is logged and replaced.
+func NewFileTokenStore(path string, secret []byte) (*FileTokenStore, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("empty token store secret")
+	}
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileTokenStore{path: path, aead: aead, tokens: map[string]string{}}
+	if err := s.load(); err != nil {
+		log.Printf("Ignoring unreadable token store %q: %v", path, err)
+	}
+	return s, nil
+}
+
+func (s *FileTokenStore) load() error {
+	b, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ns := s.aead.NonceSize()
+	if len(b) < ns {
+		return fmt.Errorf("truncated file")
+	}
+	p, err := s.aead.Open(nil, b[:ns], b[ns:], nil)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(p, &s.tokens)
+}
+
+// save writes the tokens to a temporary file which replaces the store, to
+// never leave a partially written store behind. Must be called with mu held.
+func (s *FileTokenStore) save() error {
+	p, err := json.Marshal(s.tokens)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(s.aead.Seal(nonce, nonce, p, nil)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+func (s *FileTokenStore) Token(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens[key]
+}
+
+func (s *FileTokenStore) SetToken(key string, tok string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens[key] == tok {
+		return
+	}
+	s.tokens[key] = tok
+	if err := s.save(); err != nil {
+		log.Printf("Failed to save token store %q: %v", s.path, err)
+	}
+}
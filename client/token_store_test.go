@@ -0,0 +1,89 @@
+// Tests of the session token persistence
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tokens")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tokens")
+
+	logins := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/auth":
+			logins++
+			fmt.Fprintf(w, `{"token": "secret-tok%d"}`, logins)
+		case "/rest/lssystem":
+			fmt.Fprint(w, `{"name": "system"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each store simulates a restart of the exporter
+	for i := 0; i < 2; i++ {
+		ts, err := NewFileTokenStore(path, []byte("s3cret"))
+		if err != nil {
+			t.Fatalf("NewFileTokenStore: %v", err)
+		}
+		if _, err := NewPasswordClientWithOptions(context.Background(), *u, srv.Client(), nil, "user", "pass", LoginOptions{Tokens: ts}); err != nil {
+			t.Fatalf("NewPasswordClientWithOptions: %v", err)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("Expected 1 login, got %d", logins)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret-tok") {
+		t.Errorf("Token stored in plain text")
+	}
+
+	// A store with another secret cannot read the tokens
+	ts, err := NewFileTokenStore(path, []byte("other"))
+	if err != nil {
+		t.Fatalf("NewFileTokenStore: %v", err)
+	}
+	if tok := ts.Token("user@" + u.String()); tok != "" {
+		t.Errorf("Read token %q with the wrong secret", tok)
+	}
+}
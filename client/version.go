@@ -0,0 +1,100 @@
+// REST API version negotiation
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// APIVersionLatest requests the unversioned API, i.e. whatever schema
+	// the firmware considers current.
+	APIVersionLatest = ""
+	// APIVersionAuto selects the newest version in APIVersions that the
+	// device supports, falling back to the unversioned API.
+	APIVersionAuto = "auto"
+)
+
+// APIVersions lists the versioned REST APIs known to work with the
+// exporter, newest first.
+var APIVersions = []string{"v1"}
+
+// ValidAPIVersion tells whether version is one of APIVersions,
+// APIVersionAuto or APIVersionLatest. The version ends up in the request
+// path, so anything else must be rejected.
+func ValidAPIVersion(version string) bool {
+	if version == APIVersionLatest || version == APIVersionAuto {
+		return true
+	}
+	for _, v := range APIVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// VersionSetter is implemented by clients that can request a specific
+// version of the REST API.
+type VersionSetter interface {
+	SetAPIVersion(version string)
+}
+
+// versionedPath turns "rest/lsvdisk" into "rest/<version>/lsvdisk"
+func versionedPath(path string, version string) string {
+	if version == "" || !strings.HasPrefix(path, "rest/") {
+		return path
+	}
+	return "rest/" + version + "/" + strings.TrimPrefix(path, "rest/")
+}
+
+// NegotiateAPIVersion configures c to use the requested REST API version
+// and returns the version in use. With APIVersionAuto the known versions
+// are tried newest first by calling lssystem.
+func NegotiateAPIVersion(c SpectrumHTTP, version string) (string, error) {
+	vs, ok := c.(VersionSetter)
+	if !ok {
+		if version == APIVersionLatest || version == APIVersionAuto {
+			return APIVersionLatest, nil
+		}
+		return "", fmt.Errorf("Client does not support API version selection")
+	}
+	if !ValidAPIVersion(version) {
+		return "", fmt.Errorf("Unknown API version %q", version)
+	}
+	if version != APIVersionAuto {
+		vs.SetAPIVersion(version)
+		return version, nil
+	}
+	for _, v := range APIVersions {
+		var st json.RawMessage
+		vs.SetAPIVersion(v)
+		err := c.Get("rest/lssystem", "", &st)
+		if err == nil {
+			return v, nil
+		}
+		if !IsUnsupported(err) {
+			vs.SetAPIVersion(APIVersionLatest)
+			return "", err
+		}
+	}
+	vs.SetAPIVersion(APIVersionLatest)
+	return APIVersionLatest, nil
+}
@@ -0,0 +1,211 @@
+// Simulator of the Spectrum Virtualize REST API for testing
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	mrand "math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/go-jsonnet"
+)
+
+var (
+	listen    = flag.String("listen", ":7443", "address to listen on")
+	fixtures  = flag.String("fixtures", "collectors/testdata", "directory containing the <command>.jsonnet or <command>.json fixtures")
+	user      = flag.String("user", "monitor", "user accepted by /rest/auth")
+	password  = flag.String("password", "passw0rd", "password accepted by /rest/auth")
+	tlsCert   = flag.String("tls-cert", "", "serve HTTPS using this certificate")
+	tlsKey    = flag.String("tls-key", "", "private key of -tls-cert")
+	latency   = flag.Duration("latency", 0, "delay added to every response")
+	jitter    = flag.Duration("latency-jitter", 0, "random delay of up to this duration added on top of -latency")
+	errorRate = flag.Float64("error-rate", 0, "fraction of requests answered with 500 Internal Server Error")
+	tokenTTL  = flag.Duration("token-ttl", time.Hour, "lifetime of the tokens issued by /rest/auth, after which requests are rejected with 403")
+)
+
+// Faults configures the failures injected by the simulator
+type Faults struct {
+	Latency   time.Duration
+	Jitter    time.Duration
+	ErrorRate float64
+	TokenTTL  time.Duration
+}
+
+// simulator serves the fixtures in dir as REST API commands
+type simulator struct {
+	dir      string
+	user     string
+	password string
+	faults   Faults
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+func newSimulator(dir string, user string, password string, faults Faults) *simulator {
+	return &simulator{
+		dir:      dir,
+		user:     user,
+		password: password,
+		faults:   faults,
+		tokens:   map[string]time.Time{},
+	}
+}
+
+// commandRE matches e.g. /rest/lsdrive, /rest/lsdrive/17 and
+// /rest/v1/lsdrive
+var commandRE = regexp.MustCompile(`^/rest/(?:v[0-9]+/)?([a-z]+)(?:/([A-Za-z0-9_-]+))?$`)
+
+func (s *simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d := s.faults.Latency
+	if s.faults.Jitter > 0 {
+		d += time.Duration(mrand.Int63n(int64(s.faults.Jitter)))
+	}
+	if d > 0 {
+		time.Sleep(d)
+	}
+	if s.faults.ErrorRate > 0 && mrand.Float64() < s.faults.ErrorRate {
+		http.Error(w, "Injected failure", http.StatusInternalServerError)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Path == "/rest/auth" || r.URL.Path == "/rest/v1/auth" {
+		s.auth(w, r)
+		return
+	}
+	if !s.validToken(r.Header.Get("X-Auth-Token")) {
+		http.Error(w, "Invalid or expired token", http.StatusForbidden)
+		return
+	}
+
+	m := commandRE.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		http.NotFound(w, r)
+		return
+	}
+	name := m[1]
+	if m[2] != "" {
+		name += "-" + m[2]
+	}
+	b, err := s.evaluate(name)
+	if os.IsNotExist(err) {
+		http.Error(w, "CMMVC7205E The command failed because it is not supported.", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to evaluate fixture %q: %v", name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
+
+func (s *simulator) auth(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Auth-Username") != s.user || r.Header.Get("X-Auth-Password") != s.password {
+		http.Error(w, "Authentication failed", http.StatusForbidden)
+		return
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tok := hex.EncodeToString(b)
+	s.mu.Lock()
+	s.tokens[tok] = time.Now().Add(s.faults.TokenTTL)
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"token": %q}`, tok)
+}
+
+func (s *simulator) validToken(tok string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	exp, ok := s.tokens[tok]
+	if !ok {
+		return false
+	}
+	if time.Now().After(exp) {
+		delete(s.tokens, tok)
+		return false
+	}
+	return true
+}
+
+// evaluate returns the fixture of name, evaluating <name>.jsonnet or, for
+// responses jsonnet cannot express, serving <name>.json as-is
+func (s *simulator) evaluate(name string) ([]byte, error) {
+	path := filepath.Join(s.dir, name+".jsonnet")
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ioutil.ReadFile(filepath.Join(s.dir, name+".json"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	vm := jsonnet.MakeVM()
+	out, err := vm.EvaluateSnippet(path, string(b))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+func main() {
+	flag.Parse()
+
+	if *errorRate < 0 || *errorRate > 1 || math.IsNaN(*errorRate) {
+		log.Fatalf("Invalid -error-rate %v, expected a value between 0 and 1", *errorRate)
+	}
+	if _, err := os.Stat(*fixtures); err != nil {
+		log.Fatalf("Fixture directory: %v", err)
+	}
+
+	s := newSimulator(*fixtures, *user, *password, Faults{
+		Latency:   *latency,
+		Jitter:    *jitter,
+		ErrorRate: *errorRate,
+		TokenTTL:  *tokenTTL,
+	})
+	mrand.Seed(time.Now().UnixNano())
+
+	log.Printf("Spectrum Virtualize simulator serving %q, listening on %q", *fixtures, *listen)
+	var err error
+	if *tlsCert != "" {
+		err = http.ListenAndServeTLS(*listen, *tlsCert, *tlsKey, s)
+	} else {
+		err = http.ListenAndServe(*listen, s)
+	}
+	log.Fatalf("%v", err)
+}
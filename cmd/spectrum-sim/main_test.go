@@ -0,0 +1,96 @@
+// Tests of the Spectrum Virtualize REST API simulator
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+	"github.com/bluecmd/spectrum_virtualize_exporter/collectors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestClient(t *testing.T, faults Faults) (client.SpectrumHTTP, *simulator, func()) {
+	sim := newSimulator("../../collectors/testdata", "user", "pass", faults)
+	srv := httptest.NewServer(sim)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := client.NewPasswordClient(context.Background(), *u, srv.Client(), nil, "user", "pass")
+	if err != nil {
+		srv.Close()
+		t.Fatalf("NewPasswordClient: %v", err)
+	}
+	return c, sim, srv.Close
+}
+
+func TestSimulator(t *testing.T) {
+	c, _, done := newTestClient(t, Faults{TokenTTL: time.Hour})
+	defer done()
+
+	var sys struct {
+		Name string `json:"name"`
+	}
+	if err := c.Get("rest/lssystem", "", &sys); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if sys.Name != "V7000-1" {
+		t.Errorf("Unexpected system name %q", sys.Name)
+	}
+
+	var d struct {
+		FirmwareLevel string `json:"firmware_level"`
+	}
+	if err := c.Get("rest/lsdrive/17", "", &d); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if d.FirmwareLevel == "" {
+		t.Errorf("Expected firmware level of drive 17")
+	}
+
+	if err := c.Get("rest/lsnothing", "", &d); !client.IsUnsupported(err) {
+		t.Errorf("Expected unsupported error, got %v", err)
+	}
+
+	// The default collectors should all find their fixtures
+	if !collectors.Probe(c, prometheus.NewRegistry(), &collectors.Options{}) {
+		t.Errorf("Probe of the simulator failed")
+	}
+}
+
+func TestSimulatorFaults(t *testing.T) {
+	c, sim, done := newTestClient(t, Faults{TokenTTL: 50 * time.Millisecond})
+	defer done()
+
+	// The client logs in again when its token has expired
+	time.Sleep(60 * time.Millisecond)
+	var sys struct{}
+	if err := c.Get("rest/lssystem", "", &sys); err != nil {
+		t.Errorf("Get with expired token: %v", err)
+	}
+
+	sim.faults.ErrorRate = 1
+	if err := c.Get("rest/lssystem", "", &sys); err == nil {
+		t.Errorf("Expected injected error")
+	}
+}
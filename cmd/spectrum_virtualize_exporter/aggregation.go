@@ -0,0 +1,122 @@
+// Exporter-side aggregation of probe results
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var aggregationOps = map[string]func(acc float64, v float64) float64{
+	"sum":   func(acc float64, v float64) float64 { return acc + v },
+	"count": func(acc float64, v float64) float64 { return acc + 1 },
+	"min":   math.Min,
+	"max":   math.Max,
+	// Summed up and divided by the number of series at the end
+	"avg": func(acc float64, v float64) float64 { return acc + v },
+}
+
+func metricValue(m *dto.Metric) (float64, bool) {
+	if m.Gauge != nil {
+		return m.Gauge.GetValue(), true
+	} else if m.Counter != nil {
+		return m.Counter.GetValue(), true
+	} else if m.Untyped != nil {
+		return m.Untyped.GetValue(), true
+	}
+	return 0, false
+}
+
+// aggregate evaluates the rules against what has been collected into the
+// registry so far and registers the results as new gauges.
+func aggregate(registry *prometheus.Registry, rules []config.AggregationRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		return err
+	}
+	families := map[string]*dto.MetricFamily{}
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+
+	for _, r := range rules {
+		mf, ok := families[r.Metric]
+		if !ok {
+			continue
+		}
+		op := aggregationOps[r.Op]
+		type group struct {
+			labels []string
+			value  float64
+			n      int
+		}
+		groups := map[string]*group{}
+		for _, m := range mf.Metric {
+			v, ok := metricValue(m)
+			if !ok {
+				continue
+			}
+			lv := make([]string, len(r.By))
+			for i, l := range r.By {
+				for _, lp := range m.Label {
+					if lp.GetName() == l {
+						lv[i] = lp.GetValue()
+					}
+				}
+			}
+			key := strings.Join(lv, "\xff")
+			g, ok := groups[key]
+			if !ok {
+				g = &group{labels: lv}
+				groups[key] = g
+			}
+			if g.n == 0 && r.Op != "count" {
+				g.value = v
+			} else {
+				g.value = op(g.value, v)
+			}
+			g.n++
+		}
+
+		gv := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: r.Name,
+				Help: fmt.Sprintf("Aggregation (%s) of %s by %s", r.Op, r.Metric, strings.Join(r.By, ", ")),
+			},
+			r.By,
+		)
+		if err := registry.Register(gv); err != nil {
+			return fmt.Errorf("rule %q: %v", r.Name, err)
+		}
+		for _, g := range groups {
+			if r.Op == "avg" {
+				g.value /= float64(g.n)
+			}
+			gv.WithLabelValues(g.labels...).Set(g.value)
+		}
+	}
+	return nil
+}
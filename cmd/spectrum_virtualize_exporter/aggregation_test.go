@@ -0,0 +1,85 @@
+// Tests of exporter-side aggregation
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAggregate(t *testing.T) {
+	r := prometheus.NewPedanticRegistry()
+	mStatus := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "spectrum_drive_status",
+			Help: "Status of drive",
+		},
+		[]string{"id", "enclosure", "slot_id", "status"})
+	r.MustRegister(mStatus)
+	for i, st := range []string{"online", "online", "degraded"} {
+		id := strconv.Itoa(i)
+		for _, s := range []string{"online", "offline", "degraded"} {
+			v := 0.0
+			if s == st {
+				v = 1.0
+			}
+			mStatus.WithLabelValues(id, "1", id, s).Set(v)
+		}
+	}
+
+	rules := []config.AggregationRule{
+		{Name: "spectrum_drive_status_by_enclosure", Metric: "spectrum_drive_status", Op: "sum", By: []string{"enclosure", "status"}},
+		{Name: "spectrum_drive_count", Metric: "spectrum_drive_status", Op: "count", By: []string{"status"}},
+		{Name: "spectrum_drive_status_avg", Metric: "spectrum_drive_status", Op: "avg", By: []string{"status"}},
+	}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			t.Fatalf("validate: %v", err)
+		}
+	}
+	if err := aggregate(r, rules); err != nil {
+		t.Fatalf("aggregate: %v", err)
+	}
+
+	em := `
+	# HELP spectrum_drive_count Aggregation (count) of spectrum_drive_status by status
+	# TYPE spectrum_drive_count gauge
+	spectrum_drive_count{status="degraded"} 3
+	spectrum_drive_count{status="offline"} 3
+	spectrum_drive_count{status="online"} 3
+	# HELP spectrum_drive_status_avg Aggregation (avg) of spectrum_drive_status by status
+	# TYPE spectrum_drive_status_avg gauge
+	spectrum_drive_status_avg{status="degraded"} 0.3333333333333333
+	spectrum_drive_status_avg{status="offline"} 0
+	spectrum_drive_status_avg{status="online"} 0.6666666666666666
+	# HELP spectrum_drive_status_by_enclosure Aggregation (sum) of spectrum_drive_status by enclosure, status
+	# TYPE spectrum_drive_status_by_enclosure gauge
+	spectrum_drive_status_by_enclosure{enclosure="1",status="degraded"} 1
+	spectrum_drive_status_by_enclosure{enclosure="1",status="offline"} 0
+	spectrum_drive_status_by_enclosure{enclosure="1",status="online"} 2
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_drive_count", "spectrum_drive_status_avg", "spectrum_drive_status_by_enclosure"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
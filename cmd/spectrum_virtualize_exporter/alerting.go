@@ -0,0 +1,147 @@
+// Alerts sent to Alertmanager on targets failing their polls
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var mAlertsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "spectrum_alerts_sent_total",
+	Help: "Number of alerts about failing targets sent to Alertmanager, by whether they were accepted",
+}, []string{"result"})
+
+// alertTimeout bounds sending an alert, which must not hold up the poll
+const alertTimeout = 10 * time.Second
+
+// postableAlert is an alert in the format of the Alertmanager v2 API
+type postableAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// alerter sends an alert to Alertmanager once a target failed after
+// consecutive polls, for sites running the exporter without Prometheus.
+// The alert is sent again on every failed poll to keep it firing, and
+// resolved by the next successful poll.
+type alerter struct {
+	url   string
+	after int
+	// ttl is how long an alert fires without being sent again, a few
+	// poll intervals
+	ttl time.Duration
+	hc  *http.Client
+
+	mu     sync.Mutex
+	firing map[string]time.Time
+}
+
+func newAlerter(url string, after int, interval time.Duration, hc *http.Client) *alerter {
+	if after < 1 {
+		after = 1
+	}
+	return &alerter{
+		url:    strings.TrimSuffix(url, "/") + "/api/v2/alerts",
+		after:  after,
+		ttl:    3 * interval,
+		hc:     hc,
+		firing: map[string]time.Time{},
+	}
+}
+
+// observe is called after each poll of target with the number of
+// consecutive failed polls and the reason of the last failure
+func (a *alerter) observe(target string, failures int, reason string) {
+	a.mu.Lock()
+	startsAt, firing := a.firing[target]
+	now := time.Now()
+	var alert *postableAlert
+	switch {
+	case failures >= a.after:
+		if !firing {
+			startsAt = now
+			a.firing[target] = startsAt
+		}
+		alert = a.alert(target, startsAt, now.Add(a.ttl))
+		alert.Annotations["description"] = fmt.Sprintf("%d consecutive polls failed, the last with: %s", failures, reason)
+	case failures == 0 && firing:
+		delete(a.firing, target)
+		alert = a.alert(target, startsAt, now)
+	}
+	a.mu.Unlock()
+	if alert == nil {
+		return
+	}
+	if err := a.send(alert); err != nil {
+		log.Printf("Failed to send alert about %q to Alertmanager: %v", target, err)
+		mAlertsSent.WithLabelValues("failed").Inc()
+		return
+	}
+	mAlertsSent.WithLabelValues("accepted").Inc()
+}
+
+func (a *alerter) alert(target string, startsAt time.Time, endsAt time.Time) *postableAlert {
+	return &postableAlert{
+		Labels: map[string]string{
+			"alertname": "SpectrumProbeFailed",
+			"severity":  "critical",
+			"instance":  target,
+		},
+		Annotations: map[string]string{
+			"summary": fmt.Sprintf("Probe of Spectrum Virtualize target %s is failing", target),
+		},
+		StartsAt: startsAt,
+		EndsAt:   endsAt,
+	}
+}
+
+func (a *alerter) send(alert *postableAlert) error {
+	b, err := json.Marshal([]*postableAlert{alert})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", a.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Response code was %d, expected 200", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Tests of the alerts sent to Alertmanager
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlerter(t *testing.T) {
+	var received [][]postableAlert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/api/v2/alerts" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var alerts []postableAlert
+		if err := json.NewDecoder(r.Body).Decode(&alerts); err != nil {
+			t.Errorf("Failed to decode alerts: %v", err)
+		}
+		received = append(received, alerts)
+	}))
+	defer srv.Close()
+
+	a := newAlerter(srv.URL+"/", 2, time.Minute, srv.Client())
+	target := "https://v7000-1:7443"
+
+	a.observe(target, 1, "timeout")
+	if len(received) != 0 {
+		t.Fatalf("Alerted before reaching the failure threshold: %v", received)
+	}
+
+	a.observe(target, 2, "timeout")
+	a.observe(target, 3, "Response code was 403, expected 200")
+	if len(received) != 2 {
+		t.Fatalf("Expected an alert per failed poll, got %v", received)
+	}
+	first, second := received[0][0], received[1][0]
+	if first.Labels["alertname"] != "SpectrumProbeFailed" || first.Labels["instance"] != target {
+		t.Errorf("Unexpected labels %v", first.Labels)
+	}
+	if !first.EndsAt.After(time.Now()) {
+		t.Errorf("Firing alert ends at %v, expected in the future", first.EndsAt)
+	}
+	if !second.StartsAt.Equal(first.StartsAt) {
+		t.Errorf("Alert was restarted at %v, expected %v", second.StartsAt, first.StartsAt)
+	}
+	if !strings.Contains(second.Annotations["description"], "3 consecutive polls failed, the last with: Response code was 403") {
+		t.Errorf("Unexpected description %q", second.Annotations["description"])
+	}
+
+	// A successful poll resolves the alert, once
+	a.observe(target, 0, "")
+	a.observe(target, 0, "")
+	if len(received) != 3 {
+		t.Fatalf("Expected the alert to be resolved once, got %v", received)
+	}
+	if resolved := received[2][0]; resolved.EndsAt.After(time.Now()) {
+		t.Errorf("Resolved alert ends at %v, expected in the past", resolved.EndsAt)
+	}
+}
@@ -0,0 +1,71 @@
+// Catalog of the metrics the exporter can emit
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/collectors"
+)
+
+// exporterMetrics are the metrics of a probe not coming from a collector
+var exporterMetrics = []collectors.MetricInfo{
+	{Name: "probe_success", Help: "Whether or not the probe succeeded", Type: "gauge"},
+	{Name: "probe_duration_seconds", Help: "How many seconds the probe took to complete", Type: "gauge"},
+	{Name: "probe_failure_class", Help: "Class of the failure of the last probe of a target, all 0 if it succeeded; targets without credentials are exported with an empty target", Type: "gauge", Labels: []string{"target", "class"}},
+	{Name: "spectrum_probe_api_calls_total", Help: "Number of requests sent to the target by the probe, including logins", Type: "counter"},
+	{Name: "spectrum_api_response_bytes", Help: "Size of the REST API response payloads in bytes", Type: "histogram", Labels: []string{"endpoint"}},
+	{Name: "spectrum_api_decode_errors_total", Help: "Number of REST API responses that could not be decoded", Type: "counter", Labels: []string{"endpoint"}},
+	{Name: "spectrum_api_schema_info", Help: "Hash of the set of fields last returned by a REST API endpoint", Type: "gauge", Labels: []string{"endpoint", "view", "schema"}},
+	{Name: "spectrum_target_dns_lookup_seconds", Help: "Duration of the last DNS lookup of the target host name, cached lookups excluded", Type: "gauge"},
+	{Name: "spectrum_target_clock_offset_seconds", Help: "Offset of the target clock to the exporter clock estimated from the Date header of the last response, positive if the target is ahead", Type: "gauge"},
+	{Name: "spectrum_api_version_info", Help: "REST API version used to probe the target, empty for the unversioned API", Type: "gauge", Labels: []string{"version"}},
+	{Name: "spectrum_system_name_info", Help: "Name of the probed system", Type: "gauge", Labels: []string{"system_name"}},
+	{Name: "spectrum_collector_skipped", Help: "Whether an optional collector was skipped as the probe was running out of time", Type: "gauge", Labels: []string{"collector"}},
+	{Name: "spectrum_collector_unsupported", Help: "Whether a collector is skipped as the target does not support an endpoint it needs", Type: "gauge", Labels: []string{"collector"}},
+	{Name: "spectrum_collector_series_emitted", Help: "Number of series exported by a collector in this probe, after the metric filters", Type: "gauge", Labels: []string{"collector"}},
+	{Name: "spectrum_health_score", Help: "Weighted health score of the target between 0 (all components unhealthy) and 100 (all healthy)", Type: "gauge"},
+	{Name: "spectrum_health_component_degraded", Help: "Whether any object of the component is in an unhealthy state", Type: "gauge", Labels: []string{"component"}},
+	{Name: "spectrum_target_stale", Help: "Whether the served metrics are those of the last successful poll because the latest poll failed", Type: "gauge"},
+	{Name: "spectrum_target_last_success_age_seconds", Help: "Seconds since the last successful poll of the target", Type: "gauge"},
+	{Name: "spectrum_object_created_total", Help: "Number of objects that appeared on the target since the exporter started", Type: "counter", Labels: []string{"object"}},
+	{Name: "spectrum_object_deleted_total", Help: "Number of objects that disappeared from the target since the exporter started", Type: "counter", Labels: []string{"object"}},
+}
+
+type metricsCatalog struct {
+	Metrics []collectors.MetricInfo `json:"metrics"`
+}
+
+func catalog() metricsCatalog {
+	var c metricsCatalog
+	for _, mi := range exporterMetrics {
+		mi.Collector = "exporter"
+		mi.Endpoints = []string{}
+		c.Metrics = append(c.Metrics, mi)
+	}
+	c.Metrics = append(c.Metrics, collectors.Metrics()...)
+	return c
+}
+
+func catalogHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(catalog())
+}
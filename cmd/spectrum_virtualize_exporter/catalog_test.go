@@ -0,0 +1,60 @@
+// Tests of the metrics catalog
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	w := httptest.NewRecorder()
+	catalogHandler(w, httptest.NewRequest("GET", "/api/v1/metrics-catalog", nil))
+
+	var c struct {
+		Metrics []struct {
+			Name      string   `json:"name"`
+			Type      string   `json:"type"`
+			Labels    []string `json:"labels"`
+			Collector string   `json:"collector"`
+			Endpoints []string `json:"source_endpoints"`
+		} `json:"metrics"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &c); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, m := range c.Metrics {
+		if seen[m.Name] {
+			t.Errorf("Duplicate metric %q", m.Name)
+		}
+		seen[m.Name] = true
+		if m.Name == "spectrum_pool_capacity_bytes" {
+			if m.Collector != "pool" || m.Type != "gauge" || len(m.Endpoints) != 1 || m.Endpoints[0] != "rest/lsmdiskgrp" {
+				t.Errorf("Unexpected catalog entry %+v", m)
+			}
+		}
+	}
+	for _, n := range []string{"probe_success", "probe_failure_class", "spectrum_health_score", "spectrum_pool_capacity_bytes"} {
+		if !seen[n] {
+			t.Errorf("Metric %q missing from catalog", n)
+		}
+	}
+}
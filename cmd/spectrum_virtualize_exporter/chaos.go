@@ -0,0 +1,33 @@
+// Flags controlling failure injection, only built with the chaos tag
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build chaos
+// +build chaos
+
+package main
+
+import (
+	"flag"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+)
+
+func init() {
+	flag.Float64Var(&client.Chaos.ErrorRate, "chaos-error-rate", 0, "fraction of upstream requests to fail with 500")
+	flag.DurationVar(&client.Chaos.Latency, "chaos-latency", 0, "latency to add to every upstream request")
+	flag.DurationVar(&client.Chaos.Jitter, "chaos-latency-jitter", 0, "random latency of up to this duration to add on top of -chaos-latency")
+}
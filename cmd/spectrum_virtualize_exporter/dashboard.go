@@ -0,0 +1,181 @@
+// Generation of a Grafana dashboard for the exported metrics
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/collectors"
+)
+
+const (
+	dashboardColumns     = 3
+	dashboardPanelWidth  = 24 / dashboardColumns
+	dashboardPanelHeight = 8
+)
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type panelTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	RefID        string `json:"refId"`
+}
+
+type panel struct {
+	ID          int           `json:"id"`
+	Type        string        `json:"type"`
+	Title       string        `json:"title"`
+	Description string        `json:"description,omitempty"`
+	Datasource  string        `json:"datasource,omitempty"`
+	GridPos     gridPos       `json:"gridPos"`
+	Targets     []panelTarget `json:"targets,omitempty"`
+	Collapsed   *bool         `json:"collapsed,omitempty"`
+	Panels      []panel       `json:"panels,omitempty"`
+}
+
+type templateVar struct {
+	Name       string `json:"name"`
+	Label      string `json:"label,omitempty"`
+	Type       string `json:"type"`
+	Query      string `json:"query"`
+	Datasource string `json:"datasource,omitempty"`
+	Refresh    int    `json:"refresh,omitempty"`
+	Multi      bool   `json:"multi,omitempty"`
+	IncludeAll bool   `json:"includeAll,omitempty"`
+}
+
+type dashboard struct {
+	Title         string   `json:"title"`
+	UID           string   `json:"uid"`
+	Tags          []string `json:"tags"`
+	SchemaVersion int      `json:"schemaVersion"`
+	Refresh       string   `json:"refresh"`
+	Time          struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	} `json:"time"`
+	Templating struct {
+		List []templateVar `json:"list"`
+	} `json:"templating"`
+	Panels []panel `json:"panels"`
+}
+
+// legendFormat shows the labels identifying a series, leaving out the
+// instance which is selected through the dashboard variable
+func legendFormat(labels []string) string {
+	var l []string
+	for _, n := range labels {
+		l = append(l, fmt.Sprintf("{{%s}}", n))
+	}
+	return strings.Join(l, " ")
+}
+
+// generateDashboard builds a dashboard with one row per collector and one
+// panel per metric of the collector
+func generateDashboard() dashboard {
+	var d dashboard
+	d.Title = "Spectrum Virtualize"
+	d.UID = "spectrum-virtualize"
+	d.Tags = []string{"spectrum_virtualize_exporter"}
+	d.SchemaVersion = 27
+	d.Refresh = "1m"
+	d.Time.From = "now-6h"
+	d.Time.To = "now"
+	d.Templating.List = []templateVar{
+		{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+		{Name: "instance", Label: "Target", Type: "query", Query: "label_values(probe_success, instance)", Datasource: "$datasource", Refresh: 2, Multi: true, IncludeAll: true},
+	}
+
+	id := 1
+	y := 0
+	rows := map[string]*panel{}
+	var order []string
+	for _, mi := range collectors.Metrics() {
+		row, ok := rows[mi.Collector]
+		if !ok {
+			collapsed := false
+			row = &panel{
+				Type:      "row",
+				Title:     mi.Collector,
+				Collapsed: &collapsed,
+			}
+			rows[mi.Collector] = row
+			order = append(order, mi.Collector)
+		}
+		row.Panels = append(row.Panels, panel{
+			Type:        "timeseries",
+			Title:       mi.Name,
+			Description: mi.Help,
+			Datasource:  "$datasource",
+			Targets: []panelTarget{{
+				Expr:         fmt.Sprintf(`%s{instance=~"$instance"}`, mi.Name),
+				LegendFormat: legendFormat(append([]string{"instance"}, mi.Labels...)),
+				RefID:        "A",
+			}},
+		})
+	}
+
+	for _, name := range order {
+		row := rows[name]
+		panels := row.Panels
+		row.Panels = nil
+		row.ID = id
+		row.GridPos = gridPos{H: 1, W: 24, X: 0, Y: y}
+		id++
+		y++
+		d.Panels = append(d.Panels, *row)
+		for i, p := range panels {
+			p.ID = id
+			p.GridPos = gridPos{
+				H: dashboardPanelHeight,
+				W: dashboardPanelWidth,
+				X: (i % dashboardColumns) * dashboardPanelWidth,
+				Y: y + (i/dashboardColumns)*dashboardPanelHeight,
+			}
+			id++
+			d.Panels = append(d.Panels, p)
+		}
+		y += ((len(panels) + dashboardColumns - 1) / dashboardColumns) * dashboardPanelHeight
+	}
+	return d
+}
+
+// writeDashboard writes the generated dashboard as JSON to path, or to
+// stdout if path is "-"
+func writeDashboard(path string) error {
+	b, err := json.MarshalIndent(generateDashboard(), "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
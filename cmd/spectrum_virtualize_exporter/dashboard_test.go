@@ -0,0 +1,55 @@
+// Tests of the Grafana dashboard generation
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	d := generateDashboard()
+
+	ids := map[int]bool{}
+	row := ""
+	found := false
+	for _, p := range d.Panels {
+		if ids[p.ID] {
+			t.Errorf("Duplicate panel id %d", p.ID)
+		}
+		ids[p.ID] = true
+		if p.Type == "row" {
+			row = p.Title
+			continue
+		}
+		if p.GridPos.X+p.GridPos.W > 24 {
+			t.Errorf("Panel %q does not fit the grid: %+v", p.Title, p.GridPos)
+		}
+		if p.Title == "spectrum_drive_status" {
+			found = true
+			if row != "drive" {
+				t.Errorf("Expected spectrum_drive_status in row drive, got %q", row)
+			}
+			if e := `spectrum_drive_status{instance=~"$instance"}`; p.Targets[0].Expr != e {
+				t.Errorf("Expected expression %q, got %q", e, p.Targets[0].Expr)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("No panel for spectrum_drive_status")
+	}
+}
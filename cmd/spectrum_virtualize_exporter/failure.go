@@ -0,0 +1,136 @@
+// Classification of failed probes
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Classes of probe failures, telling misconfiguration of the exporter
+// apart from problems of the target
+const (
+	// failureConfig is a target without valid credentials in the
+	// configuration of the exporter
+	failureConfig = "config"
+	// failureLogin is a target rejecting the credentials
+	failureLogin = "login"
+	// failureConnection is a target not reachable, or not answering
+	failureConnection = "connection"
+	// failureCollector is a collector failing after the login
+	failureCollector = "collector"
+)
+
+var failureClasses = []string{failureConfig, failureLogin, failureConnection, failureCollector}
+
+var mFailureClass = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "probe_failure_class",
+	Help: "Class of the failure of the last probe of a target, all 0 if it succeeded; targets without credentials are exported with an empty target",
+}, []string{"target", "class"})
+
+// configError is a probe error caused by the configuration of the exporter
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string {
+	return e.err.Error()
+}
+
+func (e *configError) Unwrap() error {
+	return e.err
+}
+
+func configErrorf(format string, a ...interface{}) error {
+	return &configError{fmt.Errorf(format, a...)}
+}
+
+// failureClass returns the class of the error returned by probe
+func failureClass(err error) string {
+	var ce *configError
+	var le *client.LoginError
+	switch {
+	case errors.As(err, &ce):
+		return failureConfig
+	case errors.As(err, &le):
+		return failureLogin
+	}
+	return failureConnection
+}
+
+// failureStatus returns the HTTP status of a /probe request failing with
+// err: a bad request if the exporter lacks the configuration to probe the
+// target, a bad gateway if the target failed
+func failureStatus(err error) int {
+	if failureClass(err) == failureConfig {
+		return http.StatusBadRequest
+	}
+	return http.StatusBadGateway
+}
+
+// observeFailure exports the class of the last probe of target, empty if
+// it succeeded
+func observeFailure(target string, class string) {
+	// Anyone may probe any target, only the configured ones are told
+	// apart to bound the number of series
+	if _, ok := getAuth(target); !ok {
+		target = ""
+	}
+	for _, c := range failureClasses {
+		v := 0.0
+		if c == class {
+			v = 1.0
+		}
+		mFailureClass.WithLabelValues(target, c).Set(v)
+	}
+}
+
+// loginCheckClient tells whether any command failed as the target
+// rejected the credentials, e.g. an expired pre-shared token
+type loginCheckClient struct {
+	client.SpectrumHTTP
+	err error
+}
+
+func (c *loginCheckClient) check(err error) error {
+	var le *client.LoginError
+	var ae *client.APIError
+	if errors.As(err, &le) {
+		c.err = le
+	} else if errors.As(err, &ae) && ae.StatusCode == http.StatusUnauthorized {
+		c.err = &client.LoginError{Request: "Request", StatusCode: ae.StatusCode, Expected: http.StatusOK}
+	}
+	return err
+}
+
+func (c *loginCheckClient) Get(path string, query string, obj interface{}) error {
+	return c.check(c.SpectrumHTTP.Get(path, query, obj))
+}
+
+func (c *loginCheckClient) GetEach(path string, query string, obj interface{}, fn func()) error {
+	return c.check(c.SpectrumHTTP.GetEach(path, query, obj, fn))
+}
+
+func (c *loginCheckClient) String() string {
+	return fmt.Sprint(c.SpectrumHTTP)
+}
@@ -0,0 +1,106 @@
+// Tests of the classification of failed probes
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestFailureClass(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/rest/auth":
+			w.WriteHeader(http.StatusForbidden)
+		case r.Header.Get("X-Auth-Token") == "expired":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			fmt.Fprint(w, `{"name": "monitor", "role": "Monitor"}`)
+		}
+	}))
+	defer srv.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	defer setConfig(config.AuthMap{}, &config.Config{})
+
+	for _, tc := range []struct {
+		name   string
+		auth   *config.Auth
+		target string
+		class  string
+		status int
+	}{
+		{"no credentials", nil, srv.URL, failureConfig, http.StatusBadRequest},
+		{"invalid credentials", &config.Auth{User: "monitor"}, srv.URL, failureConfig, http.StatusBadRequest},
+		{"login rejected", &config.Auth{User: "monitor", Password: "wrong"}, srv.URL, failureLogin, http.StatusBadGateway},
+		{"token rejected", &config.Auth{Token: "expired"}, srv.URL, failureLogin, http.StatusBadGateway},
+		{"unreachable", &config.Auth{User: "monitor", Password: "secret"}, closed.URL, failureConnection, http.StatusBadGateway},
+		{"success", &config.Auth{Token: "tok"}, srv.URL, "", 0},
+	} {
+		am := config.AuthMap{}
+		if tc.auth != nil {
+			am[tc.target] = *tc.auth
+		}
+		setConfig(am, &config.Config{})
+		_, success, err := runProbe(context.Background(), tc.target, probeOptions{module: builtinModules["ping"]}, srv.Client())
+		if tc.class == "" {
+			if err != nil || !success {
+				t.Errorf("%s: expected the probe to succeed, got success %v, err %v", tc.name, success, err)
+			}
+		} else if err == nil {
+			t.Errorf("%s: expected the probe to fail", tc.name)
+		} else {
+			if class := failureClass(err); class != tc.class {
+				t.Errorf("%s: expected failure class %q, got %q (%v)", tc.name, tc.class, class, err)
+			}
+			if status := failureStatus(err); status != tc.status {
+				t.Errorf("%s: expected status %d, got %d", tc.name, tc.status, status)
+			}
+		}
+
+		target := tc.target
+		if tc.auth == nil {
+			target = ""
+		}
+		for _, c := range failureClasses {
+			want := 0.0
+			if c == tc.class {
+				want = 1.0
+			}
+			if got := testutil.ToFloat64(mFailureClass.WithLabelValues(target, c)); got != want {
+				t.Errorf("%s: expected probe_failure_class{class=%q} to be %v, got %v", tc.name, c, want, got)
+			}
+		}
+	}
+}
+
+func TestSingleTargetFailureStatus(t *testing.T) {
+	defer func(t string) { *singleTarget = t }(*singleTarget)
+	*singleTarget = "https://unconfigured.invalid"
+	w := httptest.NewRecorder()
+	singleTargetHandler(w, httptest.NewRequest("GET", "/metrics", nil), &http.Transport{})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a target without credentials, got %d", w.Code)
+	}
+}
@@ -0,0 +1,169 @@
+// History of the recent probes of each target
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// probeRecord is the outcome of one probe
+type probeRecord struct {
+	Time     time.Time `json:"time"`
+	Success  bool      `json:"success"`
+	Duration float64   `json:"duration_seconds"`
+	// Class is the failure class of a failed probe, see failureClass
+	Class string `json:"failure_class,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// probeRing keeps the last probes of a target, overwriting the oldest
+type probeRing struct {
+	records []probeRecord
+	next    int
+	full    bool
+}
+
+func (r *probeRing) add(rec probeRecord) {
+	r.records[r.next] = rec
+	r.next++
+	if r.next == len(r.records) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// newest returns the records, the newest first
+func (r *probeRing) newest() []probeRecord {
+	n := r.next
+	if r.full {
+		n = len(r.records)
+	}
+	l := make([]probeRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		l = append(l, r.records[(r.next-i+len(r.records))%len(r.records)])
+	}
+	return l
+}
+
+// probeHistory keeps the last probes of every configured target in memory,
+// to tell when a target started failing without going through Prometheus.
+// A nil probeHistory records nothing.
+type probeHistory struct {
+	size int
+
+	mu      sync.Mutex
+	targets map[string]*probeRing
+}
+
+func newProbeHistory(size int) *probeHistory {
+	if size <= 0 {
+		return nil
+	}
+	return &probeHistory{size: size, targets: map[string]*probeRing{}}
+}
+
+// history is nil unless -history-size is set
+var history *probeHistory
+
+func (h *probeHistory) record(target string, rec probeRecord) {
+	if h == nil {
+		return
+	}
+	// Anyone may probe any target, only the configured ones are kept to
+	// bound the memory used
+	if _, ok := getAuth(target); !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r, ok := h.targets[target]
+	if !ok {
+		r = &probeRing{records: make([]probeRecord, h.size)}
+		h.targets[target] = r
+	}
+	r.add(rec)
+}
+
+// get returns the records of target, or of all targets if empty, the newest
+// first
+func (h *probeHistory) get(target string) map[string][]probeRecord {
+	res := map[string][]probeRecord{}
+	if h == nil {
+		return res
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for t, r := range h.targets {
+		if target == "" || t == target {
+			res[t] = r.newest()
+		}
+	}
+	return res
+}
+
+// historyAPIHandler serves the probe history as JSON, keyed on target.
+// The target parameter restricts it to a single target.
+func historyAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(history.get(r.URL.Query().Get("target")))
+}
+
+var historyTemplate = template.Must(template.New("history").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Probe history</title></head>
+<body>
+<h1>Probe history</h1>
+{{- range .}}
+<h2>{{.Target}}</h2>
+<table>
+<tr><th>Time</th><th>Result</th><th>Duration</th><th>Error</th></tr>
+{{- range .Records}}
+<tr><td>{{.Time.Format "2006-01-02 15:04:05 MST"}}</td><td>{{if .Success}}success{{else}}failed ({{.Class}}){{end}}</td><td>{{printf "%.3fs" .Duration}}</td><td>{{.Error}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No probes recorded.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// historyHandler serves the probe history as a page, the targets sorted
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	type targetHistory struct {
+		Target  string
+		Records []probeRecord
+	}
+	var l []targetHistory
+	for t, recs := range history.get(r.URL.Query().Get("target")) {
+		l = append(l, targetHistory{t, recs})
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Target < l[j].Target })
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := historyTemplate.Execute(w, l); err != nil {
+		log.Printf("Failed to render the probe history: %v", err)
+	}
+}
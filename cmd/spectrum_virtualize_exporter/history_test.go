@@ -0,0 +1,107 @@
+// Tests of the history of the recent probes
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+)
+
+func TestProbeRing(t *testing.T) {
+	r := &probeRing{records: make([]probeRecord, 3)}
+	if got := r.newest(); len(got) != 0 {
+		t.Errorf("Expected no records, got %v", got)
+	}
+	for i := 1; i <= 5; i++ {
+		r.add(probeRecord{Duration: float64(i)})
+		want := []float64{}
+		for j := i; j > 0 && j > i-3; j-- {
+			want = append(want, float64(j))
+		}
+		got := []float64{}
+		for _, rec := range r.newest() {
+			got = append(got, rec.Duration)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("After %d records: expected durations %v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestProbeHistory(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") == "expired" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"name": "monitor", "role": "Monitor"}`)
+	}))
+	defer srv.Close()
+	defer setConfig(config.AuthMap{}, &config.Config{})
+	history = newProbeHistory(2)
+	defer func() { history = nil }()
+
+	po := probeOptions{module: builtinModules["ping"]}
+	// Recorded under the target as in the auth file, whatever the path
+	for _, probe := range []struct{ token, target string }{
+		{"tok", srv.URL},
+		{"expired", srv.URL + "/"},
+		{"tok", srv.URL + "/probe"},
+	} {
+		setConfig(config.AuthMap{srv.URL: {Token: probe.token}}, &config.Config{})
+		runProbe(context.Background(), probe.target, po, srv.Client())
+	}
+	// Not configured, so not recorded
+	runProbe(context.Background(), "http://unknown.invalid", po, srv.Client())
+
+	rec := httptest.NewRecorder()
+	historyAPIHandler(rec, httptest.NewRequest("GET", "/api/v1/history?target="+srv.URL, nil))
+	var got map[string][]probeRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode %q: %v", rec.Body.String(), err)
+	}
+	if len(got) != 1 || len(got[srv.URL]) != 2 {
+		t.Fatalf("Expected the last 2 probes of %s, got %v", srv.URL, got)
+	}
+	newest, oldest := got[srv.URL][0], got[srv.URL][1]
+	if !newest.Success || newest.Error != "" {
+		t.Errorf("Expected the newest probe to succeed, got %+v", newest)
+	}
+	if oldest.Success || oldest.Class != failureLogin || oldest.Error == "" {
+		t.Errorf("Expected the oldest probe to fail to log in, got %+v", oldest)
+	}
+	if oldest.Time.After(newest.Time) {
+		t.Errorf("Expected the newest probe first, got %v before %v", newest.Time, oldest.Time)
+	}
+	if all := history.get(""); len(all) != 1 {
+		t.Errorf("Expected only the configured target to be recorded, got %v", all)
+	}
+
+	rec = httptest.NewRecorder()
+	historyHandler(rec, httptest.NewRequest("GET", "/history", nil))
+	if body := rec.Body.String(); !strings.Contains(body, srv.URL) || !strings.Contains(body, "failed (login)") {
+		t.Errorf("Expected the page to list the failed login of %s, got %q", srv.URL, body)
+	}
+}
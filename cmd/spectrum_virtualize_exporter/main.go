@@ -0,0 +1,443 @@
+// Server executable of spectrum_virtualize_exporter
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+	"github.com/bluecmd/spectrum_virtualize_exporter/collectors"
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+var (
+	authMapFile    = flag.String("auth-file", "", "file containing the authentication map to use when connecting to a Spectrum Virtualize device")
+	listen         = flag.String("listen", ":9747", "address to listen on, or unix:///path/to/socket")
+	socketMode     = flag.String("unix-socket-mode", "0660", "permissions of the unix socket listened on, in octal")
+	timeoutSeconds = flag.Int("scrape-timeout", 30, "max seconds to allow a scrape to take")
+	insecure       = flag.Bool("insecure", false, "Allow insecure certificates")
+	extraCAs       = flag.String("extra-ca-cert", "", "file containing extra PEMs to add to the CA trust store")
+	mbUnit         = flag.String("mb-unit", "MiB", "unit of the *_mb statistics reported by the device, either MiB or MB")
+	configFile     = flag.String("config-file", "", "optional file containing the exporter configuration")
+	singleTarget   = flag.String("target", "", "if set, include the metrics of this target on /metrics")
+	once           = flag.Bool("once", false, "probe the -target once, print its metrics to stdout and exit, failing if the probe fails")
+	watchConfig    = flag.Bool("watch-config", false, "reload the configuration automatically when the auth or config file changes")
+	driveFirmware  = flag.Bool("drive-firmware", false, "export the drive firmware census, requires one API call per drive")
+	volumes        = flag.Bool("volumes", false, "export the capacity and status of every volume, mind the series of systems with thousands of volumes")
+	alertRulesFile = flag.String("write-alert-rules", "", "write Prometheus alerting rules for the exported metrics to this file, or - for stdout, and exit")
+	dashboardFile  = flag.String("write-dashboard", "", "write a Grafana dashboard for the exported metrics to this file, or - for stdout, and exit")
+	scrapeFile     = flag.String("write-scrape-config", "", "write a Prometheus scrape configuration probing the targets of the -auth-file to this file, or - for stdout, and exit")
+	scrapeExporter = flag.String("scrape-config-exporter", "localhost:9747", "address of the exporter in the scrape configuration written by -write-scrape-config")
+	dnsCacheTTL    = flag.Duration("dns-cache-ttl", time.Minute, "how long the resolved addresses of the targets are cached, 0 to resolve on every connection")
+	dnsTimeout     = flag.Duration("dns-timeout", 5*time.Second, "timeout for resolving the host name of a target")
+	dnsPreferIPv4  = flag.Bool("dns-prefer-ipv4", false, "connect to the IPv4 addresses of a target before its IPv6 addresses")
+	dialTimeout    = flag.Duration("dial-timeout", 10*time.Second, "timeout for establishing TCP connections to the targets")
+	tlsTimeout     = flag.Duration("tls-handshake-timeout", 10*time.Second, "timeout for the TLS handshake with the targets")
+	headerTimeout  = flag.Duration("response-header-timeout", 0, "timeout waiting for the response headers of a request, 0 for none besides -scrape-timeout")
+	idleTimeout    = flag.Duration("idle-conn-timeout", 90*time.Second, "how long idle connections to the targets are kept open")
+	maxIdlePerHost = flag.Int("max-idle-conns-per-host", 2, "maximum number of idle connections kept per target")
+	http2          = flag.Bool("http2", false, "attempt to use HTTP/2 when connecting to the targets")
+	apiVersion     = flag.String("api-version", "", "REST API version to request, e.g. v1, or auto to negotiate; empty for the unversioned API")
+	memSoftLimit   = flag.String("memory.soft-limit", "", "soft memory limit of the exporter, e.g. 512MiB, overriding GOMEMLIMIT; empty for none")
+	tokenStoreFile = flag.String("token-store", "", "file to persist the session tokens in across restarts, encrypted with -token-store-secret-file")
+	tokenSecret    = flag.String("token-store-secret-file", "", "file containing the secret to encrypt the -token-store with")
+	keepLastGood   = flag.Duration("poll-keep-last-good", 0, "with -poll-interval, keep serving the last successful poll of a target for up to this long while its polls fail")
+	shardFlag      = flag.String("shard", "", "poll only shard i/n of the targets in the auth file, e.g. 0/3, to share the polling between several exporters")
+	compress       = flag.Bool("compress-responses", true, "compress the responses of /probe and /metrics with gzip if the scraper accepts it")
+	minScrape      = flag.Duration("min-scrape-interval", 0, "shortest interval the probe results are scraped at, announced to caching proxies as the max age of the /probe responses; 0 to forbid caching")
+	debugLog       = flag.Bool("debug", false, "log a redacted snippet of every API response that fails to decode")
+	validateCreds  = flag.Bool("validate-credentials", false, "validate the credentials of all targets at startup, reporting not ready on /-/ready until done")
+	validateConc   = flag.Int("validate-concurrency", 4, "number of targets to validate the credentials of at the same time")
+	pollInterval   = flag.Duration("poll-interval", 0, "probe the configured targets in the background at this interval and serve the last result, 0 to probe on each scrape")
+	pollJitter     = flag.Duration("poll-jitter", 0, "with -poll-interval, spread the polls of the targets over this much of each interval, less than the interval")
+	alertmanager   = flag.String("alertmanager-url", "", "with -poll-interval, send an alert to the Alertmanager at this URL when a target fails -alert-after-failures consecutive polls")
+	alertAfter     = flag.Int("alert-after-failures", 3, "number of consecutive failed polls of a target before alerting the -alertmanager-url")
+	historySize    = flag.Int("history-size", 20, "number of recent probes of each configured target to keep for /history, 0 to disable")
+
+	// Guards authMap and config which are replaced on reload
+	configMu sync.RWMutex
+	authMap  = config.AuthMap{}
+	cfg      = &config.Config{}
+
+	// tokenStore is nil unless -token-store is set
+	tokenStore client.TokenStore
+)
+
+// loadConfig reads and validates the configuration files given on the
+// command line.
+func loadConfig() (config.AuthMap, *config.Config, error) {
+	am, c, err := config.Load(*authMapFile, *configFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateCollectors(c); err != nil {
+		return nil, nil, fmt.Errorf("Invalid config file: %v", err)
+	}
+	return am, c, nil
+}
+
+func setConfig(am config.AuthMap, c *config.Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	authMap = am
+	cfg = c
+}
+
+func getConfig() *config.Config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return cfg
+}
+
+func getAuth(target string) (config.Auth, bool) {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	auth, ok := authMap[target]
+	return auth, ok
+}
+
+// probeOptions are the per-probe settings, which may be overridden by the
+// parameters of the probe request
+type probeOptions struct {
+	apiVersion string
+	// module is nil unless selected in the probe request
+	module *config.Module
+}
+
+func defaultProbeOptions() probeOptions {
+	return probeOptions{
+		apiVersion: *apiVersion,
+	}
+}
+
+// runProbe probes target and returns a registry containing the results and
+// whether the probe succeeded
+func runProbe(ctx context.Context, target string, po probeOptions, hc *http.Client) (*prometheus.Registry, bool, error) {
+	probeSuccessGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Whether or not the probe succeeded",
+	})
+	probeDurationGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "How many seconds the probe took to complete",
+	})
+	timeout := time.Duration(*timeoutSeconds) * time.Second
+	if po.module != nil && po.module.Timeout > 0 && po.module.Timeout < timeout {
+		timeout = po.module.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(probeSuccessGauge)
+	registry.MustRegister(probeDurationGauge)
+	start := time.Now()
+	success, err := probe(ctx, target, po, registry, hc)
+	// Recorded the way the target is looked up in the auth file
+	key := targetKey(target)
+	if err != nil {
+		class := failureClass(err)
+		observeFailure(key, class)
+		history.record(key, probeRecord{Time: start, Duration: time.Since(start).Seconds(), Class: class, Error: err.Error()})
+		return nil, false, err
+	}
+	if err := collectors.Health(registry, registry); err != nil {
+		log.Printf("Health scoring of %q failed: %v", target, err)
+	}
+	if err := aggregate(registry, getConfig().Aggregations); err != nil {
+		log.Printf("Aggregation of %q failed: %v", target, err)
+	}
+	duration := time.Since(start).Seconds()
+	probeDurationGauge.Set(duration)
+	if success {
+		probeSuccessGauge.Set(1)
+		observeFailure(key, "")
+		history.record(key, probeRecord{Time: start, Success: true, Duration: duration})
+		log.Printf("Probe of %q succeeded, took %.3f seconds", target, duration)
+	} else {
+		// probeSuccessGauge default is 0
+		observeFailure(key, failureCollector)
+		history.record(key, probeRecord{Time: start, Duration: duration, Class: failureCollector, Error: "a collector failed, see the log"})
+		log.Printf("Probe of %q failed, took %.3f seconds", target, duration)
+	}
+	return registry, success, nil
+}
+
+// metricsHandler serves the metrics of g, compressed with gzip unless
+// disabled by -compress-responses. The pages of systems with thousands of
+// ports are large, and compress well for scrapes over slow links.
+func metricsHandler(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{DisableCompression: !*compress})
+}
+
+// cacheControl returns the Cache-Control header of the probe results,
+// letting caching proxies at remote sites answer scrapes more frequent than
+// -min-scrape-interval from the last result instead of probing again
+func cacheControl(minInterval time.Duration) string {
+	if minInterval < time.Second {
+		return "no-store"
+	}
+	return fmt.Sprintf("max-age=%d", int(minInterval.Seconds()))
+}
+
+func probeHandler(w http.ResponseWriter, r *http.Request, tr *http.Transport) {
+	params := r.URL.Query()
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "Target parameter missing or empty", http.StatusBadRequest)
+		return
+	}
+	po := defaultProbeOptions()
+	if _, ok := params["api_version"]; ok {
+		po.apiVersion = params.Get("api_version")
+		if !client.ValidAPIVersion(po.apiVersion) {
+			http.Error(w, fmt.Sprintf("Unknown API version %q", po.apiVersion), http.StatusBadRequest)
+			return
+		}
+	}
+	module, err := moduleFor(params.Get("module"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	po.module = module
+	registry, ok := polledResult(target, po)
+	if !ok {
+		registry, _, err = runProbe(r.Context(), target, po, &http.Client{Transport: tr})
+		if err != nil {
+			log.Printf("Probe request rejected; error is: %v", err)
+			http.Error(w, fmt.Sprintf("probe: %v", err), failureStatus(err))
+			return
+		}
+	}
+	w.Header().Set("Cache-Control", cacheControl(*minScrape))
+	metricsHandler(registry).ServeHTTP(w, r)
+}
+
+// singleTargetHandler serves the exporter's own metrics together with the
+// probe results of the -target device.
+func singleTargetHandler(w http.ResponseWriter, r *http.Request, tr *http.Transport) {
+	registry, ok := polledResult(*singleTarget, defaultProbeOptions())
+	if !ok {
+		var err error
+		registry, _, err = runProbe(r.Context(), *singleTarget, defaultProbeOptions(), &http.Client{Transport: tr})
+		if err != nil {
+			log.Printf("Probe request rejected; error is: %v", err)
+			http.Error(w, fmt.Sprintf("probe: %v", err), failureStatus(err))
+			return
+		}
+	}
+	w.Header().Set("Cache-Control", cacheControl(*minScrape))
+	metricsHandler(prometheus.Gatherers{prometheus.DefaultGatherer, registry}).ServeHTTP(w, r)
+}
+
+// probeOnce probes target and writes the metrics to w in the text
+// exposition format, returning whether the probe succeeded
+func probeOnce(w io.Writer, target string, po probeOptions, hc *http.Client) (bool, error) {
+	registry, success, err := runProbe(context.Background(), target, po, hc)
+	if err != nil {
+		return false, err
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		return false, err
+	}
+	enc := expfmt.NewEncoder(w, expfmt.FmtText)
+	for _, mf := range mfs {
+		if err := enc.Encode(mf); err != nil {
+			return false, err
+		}
+	}
+	return success, nil
+}
+
+func main() {
+	flag.Parse()
+	// Listing the metrics runs every collector with the log muted, which
+	// must happen before anything else logs, not on the first request to
+	// the metrics catalog
+	collectors.Metrics()
+
+	if *alertRulesFile != "" {
+		if err := writeAlertRules(*alertRulesFile); err != nil {
+			log.Fatalf("Failed to write alert rules: %v", err)
+		}
+		return
+	}
+	if *dashboardFile != "" {
+		if err := writeDashboard(*dashboardFile); err != nil {
+			log.Fatalf("Failed to write dashboard: %v", err)
+		}
+		return
+	}
+	if *scrapeFile != "" {
+		if err := writeScrapeConfig(*scrapeFile, *scrapeExporter, time.Duration(*timeoutSeconds)*time.Second); err != nil {
+			log.Fatalf("Failed to write scrape configuration: %v", err)
+		}
+		return
+	}
+
+	client.Debug = *debugLog
+	if _, ok := collectors.MBUnits[*mbUnit]; !ok {
+		log.Fatalf("Invalid -mb-unit %q, expected MiB or MB", *mbUnit)
+	}
+	if !client.ValidAPIVersion(*apiVersion) {
+		log.Fatalf("Invalid -api-version %q, expected one of %v, auto or empty", *apiVersion, client.APIVersions)
+	}
+	if err := applyMemorySoftLimit(*memSoftLimit); err != nil {
+		log.Fatalf("%v", err)
+	}
+	sh, err := parseShard(*shardFlag)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	am, c, err := loadConfig()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	setConfig(am, c)
+	mReloadSuccess.Set(1)
+	mReloadTime.SetToCurrentTime()
+	prometheus.MustRegister(collectors.ParseErrors)
+	prometheus.MustRegister(collectors.UnknownEnums)
+	prometheus.MustRegister(collectors.FilterErrors)
+
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		log.Fatalf("Unable to fetch system CA store: %v", err)
+	}
+	if *extraCAs != "" {
+		certs, err := ioutil.ReadFile(*extraCAs)
+		if err != nil {
+			log.Fatalf("Failed to read extra CA file: %v", err)
+		}
+
+		if ok := roots.AppendCertsFromPEM(certs); !ok {
+			log.Fatalf("Failed to append certs from PEM, unknown error")
+		}
+	}
+	// Resume TLS sessions to save handshakes with targets probed often
+	tc := &tls.Config{RootCAs: roots, ClientSessionCache: tls.NewLRUClientSessionCache(0)}
+	if *insecure {
+		tc.InsecureSkipVerify = true
+	}
+	res := newResolver(&net.Dialer{
+		Timeout:   *dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}, *dnsCacheTTL, *dnsTimeout, *dnsPreferIPv4)
+	tr := &http.Transport{
+		DialContext:           res.DialContext,
+		TLSClientConfig:       tc,
+		TLSHandshakeTimeout:   *tlsTimeout,
+		ResponseHeaderTimeout: *headerTimeout,
+		IdleConnTimeout:       *idleTimeout,
+		MaxIdleConnsPerHost:   *maxIdlePerHost,
+		ForceAttemptHTTP2:     *http2,
+	}
+
+	if *tokenStoreFile != "" {
+		secret, err := ioutil.ReadFile(*tokenSecret)
+		if err != nil {
+			log.Fatalf("Failed to read token store secret: %v", err)
+		}
+		ts, err := client.NewFileTokenStore(*tokenStoreFile, secret)
+		if err != nil {
+			log.Fatalf("Failed to open token store: %v", err)
+		}
+		tokenStore = ts
+	}
+
+	log.Printf("Loaded %d API credentials", len(am))
+
+	if *once {
+		if *singleTarget == "" {
+			log.Fatalf("-once requires -target")
+		}
+		success, err := probeOnce(os.Stdout, *singleTarget, defaultProbeOptions(), &http.Client{Transport: tr})
+		if err != nil {
+			log.Fatalf("Probe failed: %v", err)
+		}
+		if !success {
+			os.Exit(1)
+		}
+		return
+	}
+
+	history = newProbeHistory(*historySize)
+	go reloadOnSignal()
+	if *watchConfig {
+		go watchConfigFiles()
+	}
+	if *pollInterval > 0 {
+		if *pollJitter >= *pollInterval {
+			log.Fatalf("-poll-jitter must be less than -poll-interval")
+		}
+		bgPoller = newPoller(*pollInterval, *keepLastGood, &http.Client{Transport: tr}, sh)
+		bgPoller.jitter = *pollJitter
+		if *alertmanager != "" {
+			bgPoller.alerter = newAlerter(*alertmanager, *alertAfter, *pollInterval, &http.Client{})
+		}
+		go bgPoller.run()
+	}
+
+	if *singleTarget != "" {
+		http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+			singleTargetHandler(w, r, tr)
+		})
+	} else {
+		http.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, metricsHandler(prometheus.DefaultGatherer)))
+	}
+	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		probeHandler(w, r, tr)
+	})
+	http.Handle("/precheck", &prechecker{res: res, tc: tc, tlsTimeout: *tlsTimeout, timeout: time.Duration(*timeoutSeconds) * time.Second})
+	http.HandleFunc("/api/v1/metrics-catalog", catalogHandler)
+	http.HandleFunc("/history", historyHandler)
+	http.HandleFunc("/api/v1/history", historyAPIHandler)
+	http.HandleFunc("/-/ready", readyHandler)
+	if *validateCreds {
+		go func() {
+			validateCredentials(pollTargets(sh), &http.Client{Transport: tr}, *validateConc, time.Duration(*timeoutSeconds)*time.Second)
+			log.Printf("Credentials validated, ready")
+			setReady()
+		}()
+	} else {
+		setReady()
+	}
+	webServer = newServer(http.DefaultServeMux)
+	if err := webServer.apply(c.Web); err != nil {
+		log.Fatalf("Failed to start HTTP server: %v", err)
+	}
+	log.Printf("Spectrum Virtualize exporter running")
+	select {}
+}
@@ -0,0 +1,129 @@
+// Tests of the exporter HTTP handlers
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsHandlerCompression(t *testing.T) {
+	defer func(c bool) { *compress = c }(*compress)
+
+	registry := prometheus.NewRegistry()
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "spectrum_test", Help: "Test"})
+	registry.MustRegister(g)
+
+	for _, c := range []bool{true, false} {
+		*compress = c
+		req := httptest.NewRequest("GET", "/probe", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		metricsHandler(registry).ServeHTTP(w, req)
+
+		body := w.Body.Bytes()
+		if gz := w.Header().Get("Content-Encoding") == "gzip"; gz != c {
+			t.Fatalf("Expected gzip encoding to be %v, got %v", c, gz)
+		}
+		if c {
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatalf("gzip.NewReader: %v", err)
+			}
+			if body, err = ioutil.ReadAll(zr); err != nil {
+				t.Fatalf("Failed to decompress response: %v", err)
+			}
+		}
+		if !strings.Contains(string(body), "spectrum_test 0") {
+			t.Errorf("Expected the test gauge in the response, got %q", body)
+		}
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	for _, tc := range []struct {
+		interval time.Duration
+		want     string
+	}{
+		{0, "no-store"},
+		{500 * time.Millisecond, "no-store"},
+		{30 * time.Second, "max-age=30"},
+		{90 * time.Second, "max-age=90"},
+	} {
+		if got := cacheControl(tc.interval); got != tc.want {
+			t.Errorf("cacheControl(%v) = %q, want %q", tc.interval, got, tc.want)
+		}
+	}
+}
+
+func TestProbeHandlerAPIVersion(t *testing.T) {
+	for _, v := range []string{"../x", "v1?id=0", "v99"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/probe", nil)
+		r.URL.RawQuery = url.Values{"target": {"https://v7000-1"}, "api_version": {v}}.Encode()
+		probeHandler(w, r, nil)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("api_version %q: expected 400, got %d", v, w.Code)
+		}
+	}
+}
+
+func TestProbeOnce(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/lscurrentuser" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, `{"name": "monitor", "role": "Monitor"}`)
+	}))
+	defer srv.Close()
+	setConfig(config.AuthMap{srv.URL: {Token: "tok"}}, &config.Config{})
+	defer setConfig(config.AuthMap{}, &config.Config{})
+
+	var out bytes.Buffer
+	success, err := probeOnce(&out, srv.URL, probeOptions{module: builtinModules["ping"]}, srv.Client())
+	if err != nil || !success {
+		t.Fatalf("probeOnce: success %v, err %v", success, err)
+	}
+	for _, l := range []string{"# TYPE spectrum_api_ping_seconds gauge", "probe_success 1"} {
+		if !strings.Contains(out.String(), l+"\n") {
+			t.Errorf("Expected %q in the output, got %q", l, out.String())
+		}
+	}
+
+	// The metrics of a failed probe are still printed
+	out.Reset()
+	success, err = probeOnce(&out, srv.URL, probeOptions{module: builtinModules["light"]}, srv.Client())
+	if err != nil || success {
+		t.Fatalf("probeOnce of failing module: success %v, err %v", success, err)
+	}
+	if !strings.Contains(out.String(), "probe_success 0\n") {
+		t.Errorf("Expected failed probe in the output, got %q", out.String())
+	}
+}
@@ -0,0 +1,71 @@
+// Soft memory limit and memory metrics of the exporter itself
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime/metrics"
+
+	"github.com/alecthomas/units"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "spectrum_exporter_memory_soft_limit_bytes",
+		Help: "Soft memory limit of the exporter, 0 if none is set",
+	}, func() float64 {
+		l := memoryLimit()
+		if l == math.MaxInt64 {
+			return 0
+		}
+		return float64(l)
+	})
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "spectrum_exporter_memory_bytes",
+		Help: "Memory mapped by the Go runtime and not returned to the OS, which is what the soft memory limit applies to",
+	}, runtimeMemory)
+)
+
+// runtimeMemory returns the memory counted against the soft memory limit
+func runtimeMemory() float64 {
+	s := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(s)
+	return float64(s[0].Value.Uint64() - s[1].Value.Uint64())
+}
+
+// applyMemorySoftLimit sets the soft memory limit given as e.g. 512MiB,
+// leaving the limit from GOMEMLIMIT, if any, in place when empty
+func applyMemorySoftLimit(limit string) error {
+	if limit == "" {
+		return nil
+	}
+	b, err := units.ParseBase2Bytes(limit)
+	if err != nil {
+		return fmt.Errorf("Invalid -memory.soft-limit %q: %v", limit, err)
+	}
+	if b <= 0 {
+		return fmt.Errorf("Invalid -memory.soft-limit %q: must be positive", limit)
+	}
+	return setMemoryLimit(int64(b))
+}
@@ -0,0 +1,34 @@
+// Tests of the soft memory limit
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import "testing"
+
+func TestApplyMemorySoftLimit(t *testing.T) {
+	for _, l := range []string{"lots", "-1MiB", "0"} {
+		if err := applyMemorySoftLimit(l); err == nil {
+			t.Errorf("applyMemorySoftLimit(%q) accepted an invalid limit", l)
+		}
+	}
+	if err := applyMemorySoftLimit(""); err != nil {
+		t.Errorf("applyMemorySoftLimit(\"\"): %v", err)
+	}
+	if memoryLimit() <= 0 {
+		t.Errorf("memoryLimit() = %d, expected the default or GOMEMLIMIT", memoryLimit())
+	}
+}
@@ -0,0 +1,82 @@
+// Probe modules selecting the collectors to run
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/collectors"
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+)
+
+// builtinModules are available without configuration, modules of the
+// same name in the config file take precedence
+var builtinModules = map[string]*config.Module{
+	"full": {Collectors: allCollectors()},
+	"light": {Collectors: []string{
+		"enclosure_psu", "pool", "drive", "fc_port", "ip_port", "node_info",
+	}},
+	"capacity-only": {Collectors: []string{
+		"pool", "object_limits", "capacity_warning",
+	}},
+	"ping": {Collectors: []string{"ping"}},
+}
+
+func allCollectors() []string {
+	var r []string
+	for _, c := range collectors.All {
+		r = append(r, c.Name)
+	}
+	return r
+}
+
+// validateCollectors checks that the modules and metric filters of cfg
+// only refer to known collectors
+func validateCollectors(cfg *config.Config) error {
+	known := map[string]bool{}
+	for _, c := range collectors.All {
+		known[c.Name] = true
+	}
+	for name, m := range cfg.Modules {
+		for _, c := range m.Collectors {
+			if !known[c] {
+				return fmt.Errorf("module %q: unknown collector %q", name, c)
+			}
+		}
+	}
+	for c := range cfg.Metrics {
+		if !known[c] {
+			return fmt.Errorf("metrics: unknown collector %q", c)
+		}
+	}
+	return nil
+}
+
+// moduleFor returns the module called name, or nil for the empty name
+func moduleFor(name string) (*config.Module, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if m, ok := getConfig().Modules[name]; ok {
+		return m, nil
+	}
+	if m, ok := builtinModules[name]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("Unknown module %q", name)
+}
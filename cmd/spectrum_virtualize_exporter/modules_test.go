@@ -0,0 +1,72 @@
+// Tests of the probe modules
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+)
+
+func TestModules(t *testing.T) {
+	setConfig(config.AuthMap{
+		"https://rest": {User: "u", Password: "p"},
+		"https://cim":  {User: "u", Password: "p", Backend: "cim"},
+		"https://si":   {Backend: "storage_insights", StorageInsights: &config.StorageInsights{Tenant: "t", System: "s", APIKey: "k"}},
+	}, &config.Config{
+		Modules: map[string]*config.Module{
+			"capacity-only": {Collectors: []string{"pool"}},
+			"inventory":     {Collectors: []string{"node_info", "drive_firmware"}},
+		},
+	})
+	defer setConfig(config.AuthMap{}, &config.Config{})
+
+	for _, tc := range []struct {
+		target string
+		module string
+		only   []string
+	}{
+		{"https://rest", "", nil},
+		// Configured modules take precedence over the built-in ones
+		{"https://rest", "capacity-only", []string{"pool"}},
+		{"https://rest", "light", builtinModules["light"].Collectors},
+		{"https://rest", "inventory", []string{"node_info", "drive_firmware"}},
+		{"https://rest", "ping", []string{"ping"}},
+		{"https://cim", "", []string{"pool", "drive"}},
+		{"https://cim", "light", []string{"pool", "drive"}},
+		{"https://cim", "inventory", []string{}},
+		{"https://si", "capacity-only", []string{"pool"}},
+	} {
+		m, err := moduleFor(tc.module)
+		if err != nil {
+			t.Fatalf("moduleFor(%q): %v", tc.module, err)
+		}
+		opts := collectorOptions(tc.target, probeOptions{module: m})
+		if !reflect.DeepEqual(opts.Only, tc.only) {
+			t.Errorf("%s with module %q: expected collectors %v, got %v", tc.target, tc.module, tc.only, opts.Only)
+		}
+	}
+
+	if _, err := moduleFor("unknown"); err == nil {
+		t.Errorf("Expected error for unknown module")
+	}
+	if err := validateCollectors(&config.Config{Modules: map[string]*config.Module{"bad": {Collectors: []string{"nope"}}}}); err == nil {
+		t.Errorf("Expected error for unknown collector")
+	}
+}
@@ -0,0 +1,34 @@
+// Missing soft memory limit support of Go versions before 1.19
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !go1.19
+// +build !go1.19
+
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+func setMemoryLimit(limit int64) error {
+	return fmt.Errorf("-memory.soft-limit requires the exporter to be built with Go 1.19 or later")
+}
+
+func memoryLimit() int64 {
+	return math.MaxInt64
+}
@@ -0,0 +1,309 @@
+// Background polling of the configured targets
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/collectors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	staleDesc = prometheus.NewDesc(
+		"spectrum_target_stale",
+		"Whether the served metrics are those of the last successful poll because the latest poll failed",
+		nil, nil)
+	lastSuccessAgeDesc = prometheus.NewDesc(
+		"spectrum_target_last_success_age_seconds",
+		"Seconds since the last successful poll of the target",
+		nil, nil)
+)
+
+// polledTarget holds the state of a target across polls
+type polledTarget struct {
+	lifecycle *collectors.Lifecycle
+	// status holds the staleness metrics served with every result
+	status *prometheus.Registry
+
+	mu       sync.Mutex
+	running  bool
+	registry *prometheus.Registry
+	// good is the result of the last successful poll
+	good        *prometheus.Registry
+	lastSuccess time.Time
+	stale       bool
+	// failures counts the consecutive failed polls
+	failures int
+}
+
+func newPolledTarget() *polledTarget {
+	pt := &polledTarget{
+		lifecycle: collectors.NewLifecycle(),
+		status:    prometheus.NewRegistry(),
+	}
+	pt.status.MustRegister(pt)
+	return pt
+}
+
+// start marks a poll as running, returning false if one already is
+func (pt *polledTarget) start() bool {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if pt.running {
+		return false
+	}
+	pt.running = true
+	return true
+}
+
+// finish records the result of a poll and returns the number of
+// consecutive failed polls. A failed poll replaces the result of the last
+// successful one only once that is older than keepLastGood. The registry of
+// a poll failing with an error is nil.
+func (pt *polledTarget) finish(registry *prometheus.Registry, success bool, keepLastGood time.Duration) int {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	pt.running = false
+	if success {
+		pt.failures = 0
+	} else {
+		pt.failures++
+	}
+	now := time.Now()
+	if success {
+		pt.registry = registry
+		pt.good = registry
+		pt.lastSuccess = now
+		pt.stale = false
+		return pt.failures
+	}
+	if pt.good != nil && now.Sub(pt.lastSuccess) <= keepLastGood {
+		pt.registry = pt.good
+		pt.stale = true
+		return pt.failures
+	}
+	// A probe failing before collecting anything, e.g. as the target is
+	// down, has no registry. Dropping the last result makes the next scrape
+	// probe live and report the failure instead of serving it as fresh.
+	pt.registry = registry
+	pt.stale = false
+	return pt.failures
+}
+
+func (pt *polledTarget) Describe(ch chan<- *prometheus.Desc) {
+	ch <- staleDesc
+	ch <- lastSuccessAgeDesc
+}
+
+func (pt *polledTarget) Collect(ch chan<- prometheus.Metric) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	stale := 0.0
+	if pt.stale {
+		stale = 1
+	}
+	ch <- prometheus.MustNewConstMetric(staleDesc, prometheus.GaugeValue, stale)
+	if !pt.lastSuccess.IsZero() {
+		ch <- prometheus.MustNewConstMetric(lastSuccessAgeDesc, prometheus.GaugeValue, time.Since(pt.lastSuccess).Seconds())
+	}
+}
+
+// poller probes the configured targets in the background, so that scrapes
+// are served from the last result instead of waiting on the device.
+type poller struct {
+	interval     time.Duration
+	keepLastGood time.Duration
+	hc           *http.Client
+	shard        shard
+	// alerter is notified about the failing targets, if set
+	alerter *alerter
+	// jitter spreads the polls of the targets over the start of each
+	// interval, see offset
+	jitter time.Duration
+
+	mu      sync.Mutex
+	targets map[string]*polledTarget
+}
+
+func newPoller(interval time.Duration, keepLastGood time.Duration, hc *http.Client, sh shard) *poller {
+	return &poller{
+		interval:     interval,
+		keepLastGood: keepLastGood,
+		hc:           hc,
+		shard:        sh,
+		targets:      map[string]*polledTarget{},
+	}
+}
+
+// shard selects the targets polled by this exporter out of those
+// configured, so that several exporters can share the polling
+type shard struct {
+	index int
+	count int
+}
+
+// parseShard parses a shard given as i/n, with i counting from 0
+func parseShard(s string) (shard, error) {
+	if s == "" {
+		return shard{0, 1}, nil
+	}
+	var sh shard
+	if _, err := fmt.Sscanf(s, "%d/%d", &sh.index, &sh.count); err != nil {
+		return shard{}, fmt.Errorf("Invalid shard %q, expected i/n: %v", s, err)
+	}
+	if sh.count < 1 || sh.index < 0 || sh.index >= sh.count {
+		return shard{}, fmt.Errorf("Invalid shard %q, expected 0 <= i < n", s)
+	}
+	return sh, nil
+}
+
+// contains tells whether target belongs to the shard, which is decided by
+// a hash of the target to be the same across all exporters
+func (sh shard) contains(target string) bool {
+	h := fnv.New32a()
+	h.Write([]byte(target))
+	return int(h.Sum32()%uint32(sh.count)) == sh.index
+}
+
+// pollTargets returns the targets to poll, which are the -target device in
+// single-target mode and the targets of the auth file in sh otherwise
+func pollTargets(sh shard) []string {
+	if *singleTarget != "" {
+		return []string{*singleTarget}
+	}
+	configMu.RLock()
+	defer configMu.RUnlock()
+	var r []string
+	for t := range authMap {
+		if sh.contains(t) {
+			r = append(r, t)
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+func (p *poller) run() {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+	for {
+		p.pollAll(pollTargets(p.shard))
+		<-t.C
+	}
+}
+
+// pollAll starts a poll of each of targets and forgets about targets no
+// longer configured
+func (p *poller) pollAll(targets []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	current := map[string]bool{}
+	for _, target := range targets {
+		current[target] = true
+		pt, ok := p.targets[target]
+		if !ok {
+			pt = newPolledTarget()
+			p.targets[target] = pt
+		}
+		if !pt.start() {
+			log.Printf("Previous poll of %q is still running, skipping", target)
+			continue
+		}
+		if d := p.offset(target); d > 0 {
+			target, pt := target, pt
+			time.AfterFunc(d, func() { p.poll(target, pt) })
+		} else {
+			go p.poll(target, pt)
+		}
+	}
+	for target := range p.targets {
+		if !current[target] {
+			delete(p.targets, target)
+		}
+	}
+}
+
+// offset returns the delay of the polls of target after the start of each
+// interval, spread over the jitter by a hash of the target. Polling all
+// arrays of a datacenter at the same second loads their management planes
+// at once. The offset of a target stays the same, also across restarts and
+// exporters, so that its polls stay an interval apart.
+func (p *poller) offset(target string) time.Duration {
+	if p.jitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(target))
+	return time.Duration(h.Sum64() % uint64(p.jitter))
+}
+
+func (p *poller) poll(target string, pt *polledTarget) {
+	registry, success, err := runProbe(context.Background(), target, defaultProbeOptions(), p.hc)
+	if err != nil {
+		log.Printf("Poll of %q failed: %v", target, err)
+		failures := pt.finish(nil, false, p.keepLastGood)
+		if p.alerter != nil {
+			p.alerter.observe(target, failures, err.Error())
+		}
+		return
+	}
+	if err := pt.lifecycle.Update(registry); err != nil {
+		log.Printf("Object lifecycle tracking of %q failed: %v", target, err)
+	}
+	registry.MustRegister(pt.lifecycle)
+	failures := pt.finish(registry, success, p.keepLastGood)
+	if p.alerter != nil {
+		p.alerter.observe(target, failures, "a collector failed, see the exporter log")
+	}
+}
+
+// result returns the metrics of the last poll of target to serve, if any
+func (p *poller) result(target string) (prometheus.Gatherer, bool) {
+	p.mu.Lock()
+	pt, ok := p.targets[target]
+	p.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	if pt.registry == nil {
+		return nil, false
+	}
+	return prometheus.Gatherers{pt.registry, pt.status}, true
+}
+
+// bgPoller is the background poller, nil unless -poll-interval is set
+var bgPoller *poller
+
+// polledResult returns the last background poll of target, if polling is
+// enabled and the probe uses the default options
+func polledResult(target string, po probeOptions) (prometheus.Gatherer, bool) {
+	if bgPoller == nil || po.module != nil || po.apiVersion != *apiVersion {
+		return nil, false
+	}
+	return bgPoller.result(target)
+}
@@ -0,0 +1,175 @@
+// Tests of the background poller
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPollerTargets(t *testing.T) {
+	p := newPoller(time.Minute, 0, &http.Client{}, shard{0, 1})
+	p.pollAll([]string{"https://a:7443", "https://b:7443"})
+	if len(p.targets) != 2 {
+		t.Fatalf("Expected 2 polled targets, got %d", len(p.targets))
+	}
+	p.pollAll([]string{"https://b:7443"})
+	if _, ok := p.targets["https://a:7443"]; ok {
+		t.Errorf("Removed target still polled")
+	}
+	// Without credentials the poll fails and there is no result to serve
+	time.Sleep(10 * time.Millisecond)
+	if _, ok := p.result("https://b:7443"); ok {
+		t.Errorf("Failed poll produced a result")
+	}
+}
+
+func TestKeepLastGood(t *testing.T) {
+	p := newPoller(time.Minute, time.Hour, &http.Client{}, shard{0, 1})
+	pt := newPolledTarget()
+	p.targets["https://a:7443"] = pt
+
+	result := func(stale string) {
+		t.Helper()
+		g, ok := p.result("https://a:7443")
+		if !ok {
+			t.Fatalf("No result to serve")
+		}
+		expected := `
+# HELP spectrum_target_stale Whether the served metrics are those of the last successful poll because the latest poll failed
+# TYPE spectrum_target_stale gauge
+spectrum_target_stale ` + stale + `
+`
+		if err := testutil.GatherAndCompare(g, strings.NewReader(expected), "spectrum_target_stale"); err != nil {
+			t.Error(err)
+		}
+	}
+
+	good := prometheus.NewRegistry()
+	failed := prometheus.NewRegistry()
+	pt.finish(good, true, p.keepLastGood)
+	result("0")
+
+	// The failed poll is within the grace period, keep serving the good one
+	pt.finish(failed, false, p.keepLastGood)
+	result("1")
+	if pt.registry != good {
+		t.Errorf("Failed poll replaced the last good result within the grace period")
+	}
+
+	// Once the grace period is over the failed poll is served
+	pt.lastSuccess = time.Now().Add(-2 * time.Hour)
+	pt.finish(failed, false, p.keepLastGood)
+	result("0")
+	if pt.registry != failed {
+		t.Errorf("Last good result served after the grace period")
+	}
+
+	// The same goes for polls failing with an error, e.g. as the target
+	// is down
+	pt.finish(good, true, p.keepLastGood)
+	pt.finish(nil, false, p.keepLastGood)
+	result("1")
+	if pt.registry != good {
+		t.Errorf("Poll failing with an error replaced the last good result within the grace period")
+	}
+	pt.lastSuccess = time.Now().Add(-2 * time.Hour)
+	pt.finish(nil, false, p.keepLastGood)
+	if _, ok := p.result("https://a:7443"); ok {
+		t.Errorf("Last good result served after the grace period")
+	}
+}
+
+func TestPollError(t *testing.T) {
+	p := newPoller(time.Minute, 0, &http.Client{}, shard{0, 1})
+	pt := newPolledTarget()
+	p.targets["https://a:7443"] = pt
+
+	pt.finish(prometheus.NewRegistry(), true, p.keepLastGood)
+	if _, ok := p.result("https://a:7443"); !ok {
+		t.Fatalf("No result to serve after a successful poll")
+	}
+	// A poll failing with an error, e.g. connection refused, leaves
+	// nothing to serve so that the scrape probes live
+	if failures := pt.finish(nil, false, p.keepLastGood); failures != 1 {
+		t.Errorf("Expected 1 failed poll, got %d", failures)
+	}
+	if _, ok := p.result("https://a:7443"); ok {
+		t.Errorf("Last result still served after a poll failing with an error")
+	}
+}
+
+func TestShard(t *testing.T) {
+	for _, s := range []string{"1", "3/3", "-1/2", "0/0", "a/b"} {
+		if _, err := parseShard(s); err == nil {
+			t.Errorf("parseShard(%q) accepted an invalid shard", s)
+		}
+	}
+
+	var shards []shard
+	for i := 0; i < 3; i++ {
+		sh, err := parseShard(fmt.Sprintf("%d/3", i))
+		if err != nil {
+			t.Fatalf("parseShard: %v", err)
+		}
+		shards = append(shards, sh)
+	}
+	// Every target is polled by exactly one exporter
+	for i := 0; i < 100; i++ {
+		target := fmt.Sprintf("https://v7000-%d:7443", i)
+		n := 0
+		for _, sh := range shards {
+			if sh.contains(target) {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Errorf("Target %q is in %d shards", target, n)
+		}
+	}
+}
+
+func TestPollOffset(t *testing.T) {
+	p := newPoller(time.Minute, 0, &http.Client{}, shard{0, 1})
+	if d := p.offset("https://v7000-0:7443"); d != 0 {
+		t.Errorf("Expected no offset without jitter, got %v", d)
+	}
+
+	p.jitter = 30 * time.Second
+	offsets := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		target := fmt.Sprintf("https://v7000-%d:7443", i)
+		d := p.offset(target)
+		if d < 0 || d >= p.jitter {
+			t.Errorf("Offset %v of %q is not within the jitter", d, target)
+		}
+		if d2 := p.offset(target); d2 != d {
+			t.Errorf("Offset of %q changed from %v to %v", target, d, d2)
+		}
+		offsets[d] = true
+	}
+	if len(offsets) < 90 {
+		t.Errorf("Expected the offsets to be spread, got %d distinct offsets of 100 targets", len(offsets))
+	}
+}
@@ -0,0 +1,202 @@
+// Checks of the network path to a target without logging in
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// precheckPorts are checked for targets without a port: the REST API
+// listens on 7443, the management GUI on 443
+var precheckPorts = []string{"7443", "443"}
+
+// portCheck is the outcome of checking one port of a target
+type portCheck struct {
+	Port         string `json:"port"`
+	TCPReachable bool   `json:"tcp_reachable"`
+	// TLSNegotiated is not set for http targets
+	TLSNegotiated *bool   `json:"tls_negotiated,omitempty"`
+	TLSVersion    string  `json:"tls_version,omitempty"`
+	Seconds       float64 `json:"seconds"`
+	Error         string  `json:"error,omitempty"`
+}
+
+// precheckReport tells how far a connection to a target gets
+type precheckReport struct {
+	Target      string      `json:"target"`
+	Host        string      `json:"host"`
+	DNSResolved bool        `json:"dns_resolved"`
+	Addresses   []string    `json:"addresses,omitempty"`
+	Error       string      `json:"error,omitempty"`
+	Ports       []portCheck `json:"ports"`
+}
+
+// prechecker checks whether the ports of a target are reachable and
+// negotiate TLS, without logging in. It tells network and certificate
+// problems apart from credential and REST API problems.
+type prechecker struct {
+	res        *resolver
+	tc         *tls.Config
+	tlsTimeout time.Duration
+	timeout    time.Duration
+}
+
+// parsePrecheckTarget returns the scheme, host and ports to check of
+// target, which is a probe target URL or a bare host name
+func parsePrecheckTarget(target string) (string, string, []string, error) {
+	if !strings.Contains(target, "://") {
+		target = "https://" + target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if u.Hostname() == "" {
+		return "", "", nil, fmt.Errorf("no host in target %q", target)
+	}
+	ports := precheckPorts
+	if u.Port() != "" {
+		ports = []string{u.Port()}
+	}
+	return u.Scheme, u.Hostname(), ports, nil
+}
+
+func (p *prechecker) checkPort(ctx context.Context, scheme string, host string, port string) portCheck {
+	pc := portCheck{Port: port}
+	start := time.Now()
+
+	conn, err := p.res.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		pc.Error = err.Error()
+		pc.Seconds = time.Since(start).Seconds()
+		return pc
+	}
+	defer conn.Close()
+	pc.TCPReachable = true
+	if scheme != "https" {
+		pc.Seconds = time.Since(start).Seconds()
+		return pc
+	}
+
+	tc := p.tc.Clone()
+	tc.ServerName = host
+	tconn := tls.Client(conn, tc)
+	deadline := time.Now().Add(p.tlsTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	err = tconn.Handshake()
+	negotiated := err == nil
+	if err != nil {
+		pc.Error = err.Error()
+	} else {
+		pc.TLSVersion = tlsVersionName(tconn.ConnectionState().Version)
+	}
+	pc.TLSNegotiated = &negotiated
+	pc.Seconds = time.Since(start).Seconds()
+	return pc
+}
+
+func tlsVersionName(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return "unknown"
+}
+
+// check resolves target and checks its ports concurrently
+func (p *prechecker) check(ctx context.Context, target string) precheckReport {
+	rep := precheckReport{Target: target, Ports: []portCheck{}}
+	scheme, host, ports, err := parsePrecheckTarget(target)
+	if err != nil {
+		rep.Error = err.Error()
+		return rep
+	}
+	rep.Host = host
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+	addrs, err := p.res.resolve(ctx, host)
+	if err != nil {
+		rep.Error = err.Error()
+		return rep
+	}
+	rep.DNSResolved = true
+	rep.Addresses = addrs
+
+	rep.Ports = make([]portCheck, len(ports))
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		go func(i int, port string) {
+			defer wg.Done()
+			rep.Ports[i] = p.checkPort(ctx, scheme, host, port)
+		}(i, port)
+	}
+	wg.Wait()
+	return rep
+}
+
+// configuredHost tells whether host is the host of a target in the auth
+// file. Only those are checked, as anyone may call /precheck and it would
+// otherwise scan any host and port the exporter can reach.
+func configuredHost(host string) bool {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	for t := range authMap {
+		if u, err := url.Parse(t); err == nil && strings.EqualFold(u.Hostname(), host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *prechecker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "Target parameter missing or empty", http.StatusBadRequest)
+		return
+	}
+	if _, host, _, err := parsePrecheckTarget(target); err != nil || !configuredHost(host) {
+		http.Error(w, "Target is not in the auth file", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(p.check(r.Context(), target))
+}
@@ -0,0 +1,135 @@
+// Tests of the network path checks of the targets
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+)
+
+func TestPrecheck(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Precheck sent a request to %s", r.URL)
+	}))
+	defer srv.Close()
+	_, tlsPort, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	// Accepts connections but does not speak TLS
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	go func() {
+		for {
+			c, err := plain.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, plainPort, _ := net.SplitHostPort(plain.Addr().String())
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, closedPort, _ := net.SplitHostPort(closed.Addr().String())
+	closed.Close()
+
+	r := newResolver(&net.Dialer{}, 0, time.Second, false)
+	r.lookup = func(ctx context.Context, host string) ([]string, error) {
+		if host == "example.com" {
+			return []string{"127.0.0.1"}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	p := &prechecker{res: r, tc: &tls.Config{RootCAs: roots}, tlsTimeout: time.Second, timeout: 5 * time.Second}
+
+	rep := p.check(context.Background(), "https://example.com:"+tlsPort)
+	if !rep.DNSResolved || len(rep.Ports) != 1 {
+		t.Fatalf("Expected one port checked, got %+v", rep)
+	}
+	if pc := rep.Ports[0]; !pc.TCPReachable || pc.TLSNegotiated == nil || !*pc.TLSNegotiated || pc.Error != "" {
+		t.Errorf("Expected TLS to be negotiated, got %+v", pc)
+	}
+
+	rep = p.check(context.Background(), "example.com:"+plainPort)
+	if pc := rep.Ports[0]; !pc.TCPReachable || pc.TLSNegotiated == nil || *pc.TLSNegotiated || pc.Error == "" {
+		t.Errorf("Expected TLS to fail on a plain port, got %+v", pc)
+	}
+
+	rep = p.check(context.Background(), "http://example.com:"+plainPort)
+	if pc := rep.Ports[0]; !pc.TCPReachable || pc.TLSNegotiated != nil {
+		t.Errorf("Expected no TLS check of an http target, got %+v", pc)
+	}
+
+	rep = p.check(context.Background(), "https://example.com:"+closedPort)
+	if pc := rep.Ports[0]; pc.TCPReachable || pc.Error == "" {
+		t.Errorf("Expected a closed port to be unreachable, got %+v", pc)
+	}
+
+	rep = p.check(context.Background(), "unknown.example.com")
+	if rep.DNSResolved || rep.Error == "" || len(rep.Ports) != 0 {
+		t.Errorf("Expected the DNS resolution to fail, got %+v", rep)
+	}
+
+	// Both the REST API and the GUI port are checked without a port
+	if _, _, ports, _ := parsePrecheckTarget("v7000-1"); len(ports) != 2 {
+		t.Errorf("Expected both ports to be checked, got %v", ports)
+	}
+
+	// Only the hosts of configured targets are checked
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/precheck?target=https://example.com:"+tlsPort, nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a target not in the auth file, got %d", w.Code)
+	}
+	setConfig(config.AuthMap{"https://example.com:7443": {Token: "tok"}}, &config.Config{})
+	defer setConfig(config.AuthMap{}, &config.Config{})
+
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/precheck?target=https://example.com:"+tlsPort, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	var got precheckReport
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Failed to decode the report: %v", err)
+	}
+	if got.Host != "example.com" || len(got.Ports) != 1 || !got.Ports[0].TCPReachable {
+		t.Errorf("Unexpected report %+v", got)
+	}
+	w = httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest("GET", "/precheck", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without target, got %d", w.Code)
+	}
+}
@@ -0,0 +1,494 @@
+// Probing of a single target
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+	"github.com/bluecmd/spectrum_virtualize_exporter/collectors"
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// targetMetrics holds metrics that live for the lifetime of the exporter
+// and are exposed together with the probe results of a target.
+type targetMetrics struct {
+	responseBytes *prometheus.HistogramVec
+	// clockOffset has no labels, it is only exported once observed
+	clockOffset  *prometheus.GaugeVec
+	decodeErrors *prometheus.CounterVec
+	schemaInfo   *prometheus.GaugeVec
+	// dnsLookup has no labels, it is only exported once a lookup was done
+	dnsLookup *prometheus.GaugeVec
+
+	// schemas holds the last schema hash observed per endpoint and view,
+	// to replace its series when the firmware changes
+	schemasMu sync.Mutex
+	schemas   map[schemaKey]string
+
+	// unsupported are the collectors skipped for the target until the
+	// configuration is reloaded
+	unsupported collectors.UnsupportedCollectors
+}
+
+// resetUnsupported probes all collectors of all targets again, in case the
+// firmware was upgraded
+func resetUnsupported() {
+	targetMetricsMu.Lock()
+	defer targetMetricsMu.Unlock()
+	for _, m := range targetMetricsMap {
+		m.unsupported.Reset()
+	}
+}
+
+var (
+	targetMetricsMu  sync.Mutex
+	targetMetricsMap = map[string]*targetMetrics{}
+)
+
+func metricsForTarget(target string) *targetMetrics {
+	targetMetricsMu.Lock()
+	defer targetMetricsMu.Unlock()
+	m, ok := targetMetricsMap[target]
+	if !ok {
+		m = &targetMetrics{
+			responseBytes: prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Name:    "spectrum_api_response_bytes",
+					Help:    "Size of the REST API response payloads in bytes",
+					Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+				},
+				[]string{"endpoint"},
+			),
+			clockOffset: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: "spectrum_target_clock_offset_seconds",
+					Help: "Offset of the target clock to the exporter clock estimated from the Date header of the last response, positive if the target is ahead",
+				},
+				[]string{},
+			),
+			decodeErrors: prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "spectrum_api_decode_errors_total",
+					Help: "Number of REST API responses that could not be decoded",
+				},
+				[]string{"endpoint"},
+			),
+			schemaInfo: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: "spectrum_api_schema_info",
+					Help: "Hash of the set of fields last returned by a REST API endpoint",
+				},
+				[]string{"endpoint", "view", "schema"},
+			),
+			dnsLookup: prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: "spectrum_target_dns_lookup_seconds",
+					Help: "Duration of the last DNS lookup of the target host name, cached lookups excluded",
+				},
+				[]string{},
+			),
+			schemas: map[schemaKey]string{},
+		}
+		targetMetricsMap[target] = m
+	}
+	return m
+}
+
+func (m *targetMetrics) register(registry *prometheus.Registry) {
+	registry.MustRegister(m.responseBytes)
+	registry.MustRegister(m.clockOffset)
+	registry.MustRegister(m.decodeErrors)
+	registry.MustRegister(m.schemaInfo)
+	registry.MustRegister(m.dnsLookup)
+}
+
+// apiEndpoint returns the command of the REST API path, e.g. lsdrive for
+// both rest/lsdrive and rest/lsdrive/5, and whether it is the list of
+// objects or the detailed view of one. The object IDs are left out of the
+// labels, which would otherwise grow with every object of every target.
+func apiEndpoint(path string) (string, string) {
+	cmd := strings.TrimPrefix(path, "rest/")
+	if i := strings.Index(cmd, "/"); i >= 0 {
+		return cmd[:i], "detail"
+	}
+	return cmd, "list"
+}
+
+func (m *targetMetrics) ObserveDNSLookup(d time.Duration) {
+	m.dnsLookup.WithLabelValues().Set(d.Seconds())
+}
+
+func (m *targetMetrics) ObserveClockOffset(offset time.Duration) {
+	m.clockOffset.WithLabelValues().Set(offset.Seconds())
+}
+
+func (m *targetMetrics) ObserveDecodeError(path string) {
+	endpoint, _ := apiEndpoint(path)
+	m.decodeErrors.WithLabelValues(endpoint).Inc()
+}
+
+type schemaKey struct {
+	endpoint string
+	view     string
+}
+
+// ObserveSchema records the schema of list responses only, the detailed
+// views are left to probeObserver which sees all objects of a probe
+func (m *targetMetrics) ObserveSchema(path string, fields []string) {
+	if endpoint, view := apiEndpoint(path); view == "list" {
+		m.setSchema(schemaKey{endpoint, view}, fields)
+	}
+}
+
+func (m *targetMetrics) setSchema(key schemaKey, fields []string) {
+	schema := client.SchemaHash(fields)
+	m.schemasMu.Lock()
+	defer m.schemasMu.Unlock()
+	if old, ok := m.schemas[key]; ok && old != schema {
+		m.schemaInfo.DeleteLabelValues(key.endpoint, key.view, old)
+	}
+	m.schemas[key] = schema
+	m.schemaInfo.WithLabelValues(key.endpoint, key.view, schema).Set(1)
+}
+
+// probeObserver is the observer of a single probe. The detailed views of
+// different objects may differ in their fields, e.g. a volume with two
+// copies reports more than one with a single copy, so their schema is the
+// intersection of the fields of all objects seen by the probe.
+type probeObserver struct {
+	*targetMetrics
+
+	mu     sync.Mutex
+	detail map[string][]string
+}
+
+func newProbeObserver(m *targetMetrics) *probeObserver {
+	return &probeObserver{targetMetrics: m, detail: map[string][]string{}}
+}
+
+func (p *probeObserver) ObserveSchema(path string, fields []string) {
+	endpoint, view := apiEndpoint(path)
+	if view == "list" {
+		p.setSchema(schemaKey{endpoint, view}, fields)
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if prev, ok := p.detail[endpoint]; ok {
+		fields = intersectFields(prev, fields)
+	}
+	p.detail[endpoint] = fields
+}
+
+// flush records the schemas of the detailed views, once all objects were
+// seen
+func (p *probeObserver) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for endpoint, fields := range p.detail {
+		p.setSchema(schemaKey{endpoint, "detail"}, fields)
+	}
+}
+
+// intersectFields returns the fields in both of the sorted a and b
+func intersectFields(a, b []string) []string {
+	res := []string{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			res = append(res, a[i])
+			i++
+			j++
+		}
+	}
+	return res
+}
+
+func (m *targetMetrics) ObserveResponse(path string, size int64) {
+	endpoint, _ := apiEndpoint(path)
+	m.responseBytes.WithLabelValues(endpoint).Observe(float64(size))
+}
+
+// countingClient counts the requests sent to a target
+type countingClient struct {
+	hc    client.HTTPClient
+	calls prometheus.Counter
+}
+
+func (c *countingClient) Do(req *http.Request) (*http.Response, error) {
+	c.calls.Inc()
+	return c.hc.Do(req)
+}
+
+// headerClient adds headers to all requests
+type headerClient struct {
+	hc      client.HTTPClient
+	headers map[string]string
+}
+
+func (c *headerClient) Do(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	return c.hc.Do(req)
+}
+
+func newSpectrumClient(ctx context.Context, tgt url.URL, httpClient client.HTTPClient, m client.Observer) (client.SpectrumHTTP, error) {
+	var hc client.HTTPClient = httpClient
+	if h := getConfig().RequestHeaders; len(h) > 0 {
+		hc = &headerClient{hc: httpClient, headers: h}
+	}
+	auth, ok := getAuth(tgt.String())
+	if !ok {
+		return nil, configErrorf("No API authentication registered for %q", tgt.String())
+	}
+
+	password, err := auth.GetPassword()
+	if err != nil {
+		return nil, configErrorf("Failed to read password of %q: %v", tgt.String(), err)
+	}
+	if auth.Backend == "cim" {
+		if auth.User == "" || password == "" {
+			return nil, configErrorf("CIM backend of %q requires user and password", tgt.String())
+		}
+		return client.NewCIMClient(ctx, tgt, hc, m, auth.User, password), nil
+	}
+	if auth.Backend == "storage_insights" {
+		// The target only names the device, it is read from Storage Insights
+		si := auth.StorageInsights
+		base := client.DefaultInsightsURL
+		if si.URL != "" {
+			base = si.URL
+		}
+		u, err := url.Parse(base)
+		if err != nil {
+			return nil, configErrorf("Invalid Storage Insights URL of %q: %v", tgt.String(), err)
+		}
+		return client.NewInsightsClient(ctx, *u, hc, m, si.Tenant, si.System, si.APIKey)
+	}
+	if auth.Token != "" {
+		return client.NewTokenClient(ctx, tgt, hc, m, auth.Token), nil
+	}
+	if auth.User != "" && password != "" {
+		c, err := client.NewPasswordClientWithOptions(ctx, tgt, hc, m, auth.User, password, client.LoginOptions{
+			UserHeader:     auth.UserHeader,
+			PasswordHeader: auth.PasswordHeader,
+			TokenHeader:    auth.TokenHeader,
+			UserFormat:     auth.UserFormat,
+			Tokens:         tokenStore,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+	return nil, configErrorf("Invalid authentication data for %q", tgt.String())
+}
+
+// defaultMinRemaining is the time left of a probe below which the optional
+// collectors are skipped, unless configured
+const defaultMinRemaining = 5 * time.Second
+
+func collectorOptions(target string, po probeOptions) *collectors.Options {
+	cfg := getConfig()
+	opts := &collectors.Options{
+		Filters:      cfg.Filters,
+		BytesPerMB:   collectors.MBUnits[*mbUnit],
+		Metrics:      cfg.Metrics,
+		Optional:     cfg.Priorities.Optional(),
+		MinRemaining: cfg.Priorities.MinRemaining,
+	}
+	if opts.MinRemaining == 0 {
+		opts.MinRemaining = defaultMinRemaining
+	}
+	if *driveFirmware {
+		opts.Enable = append(opts.Enable, "drive_firmware")
+	}
+	if *volumes {
+		opts.Enable = append(opts.Enable, "volume")
+	}
+	if po.module != nil && len(po.module.Collectors) > 0 {
+		opts.Only = po.module.Collectors
+		opts.Enable = append(opts.Enable, po.module.Collectors...)
+	}
+	switch auth, _ := getAuth(target); auth.Backend {
+	case "cim":
+		opts.Only = intersect(opts.Only, client.CIMCollectors)
+	case "storage_insights":
+		opts.Only = intersect(opts.Only, client.InsightsCollectors)
+	}
+	return opts
+}
+
+// intersect returns the elements of b also in a, or b if a is empty
+func intersect(a []string, b []string) []string {
+	if len(a) == 0 {
+		return b
+	}
+	in := map[string]bool{}
+	for _, s := range a {
+		in[s] = true
+	}
+	r := []string{}
+	for _, s := range b {
+		if in[s] {
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+// systemName returns the name of the system, or an empty name if the
+// backend cannot tell
+func systemName(c client.SpectrumHTTP) (string, error) {
+	type system struct {
+		Name string
+	}
+	var sys system
+	if err := c.Get("rest/lssystem", "", &sys); err != nil {
+		if client.IsUnsupported(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return sys.Name, nil
+}
+
+// targetKey returns target reduced to the scheme and host, as the auth file
+// is keyed on, e.g. https://v7000-1:7443 for https://v7000-1:7443/
+func targetKey(target string) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+	return (&url.URL{Scheme: u.Scheme, Host: u.Host}).String()
+}
+
+func probe(ctx context.Context, target string, po probeOptions, registry *prometheus.Registry, hc *http.Client) (bool, error) {
+	tgt, err := url.Parse(target)
+	if err != nil {
+		return false, configErrorf("url.Parse failed: %v", err)
+	}
+
+	if tgt.Scheme != "https" && tgt.Scheme != "http" {
+		return false, configErrorf("Unsupported scheme %q", tgt.Scheme)
+	}
+
+	// Filter anything else than scheme and hostname
+	u := url.URL{
+		Scheme: tgt.Scheme,
+		Host:   tgt.Host,
+	}
+	// The per-target metrics are kept across probes, so only for the
+	// configured targets as anyone may probe any target
+	if _, ok := getAuth(u.String()); !ok {
+		return false, configErrorf("No API authentication registered for %q", u.String())
+	}
+	m := metricsForTarget(u.String())
+	m.register(registry)
+	obs := newProbeObserver(m)
+	ctx = withDNSObserver(ctx, m)
+	// Counted from the login on, to show the load a probe puts on the
+	// target
+	mCalls := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "spectrum_probe_api_calls_total",
+		Help: "Number of requests sent to the target by the probe, including logins",
+	})
+	registry.MustRegister(mCalls)
+	c, err := newSpectrumClient(ctx, u, &countingClient{hc: hc, calls: mCalls}, obs)
+	if err != nil {
+		return false, err
+	}
+
+	// Rejected credentials fail the probe like a failed login, not like a
+	// failed collector
+	lc := &loginCheckClient{SpectrumHTTP: c}
+	if po.apiVersion != client.APIVersionLatest {
+		v, err := client.NegotiateAPIVersion(c, po.apiVersion)
+		if err != nil {
+			log.Printf("Error: API version negotiation with %q failed: %v", u.String(), err)
+			lc.check(err)
+			return false, lc.err
+		}
+		mVersion := prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_api_version_info",
+				Help: "REST API version used to probe the target, empty for the unversioned API",
+			},
+			[]string{"version"})
+		registry.MustRegister(mVersion)
+		mVersion.WithLabelValues(v).Set(1)
+	}
+
+	labels := prometheus.Labels{}
+	if po.module != nil {
+		for k, v := range po.module.Labels {
+			labels[k] = v
+		}
+	}
+	if mode := getConfig().SystemName; mode != "" {
+		name, err := systemName(lc)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			return false, lc.err
+		}
+		if name != "" && mode == config.SystemNameLabel {
+			labels["system_name"] = name
+		}
+		if name != "" && mode == config.SystemNameInfo {
+			mName := prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: "spectrum_system_name_info",
+					Help: "Name of the probed system",
+				},
+				[]string{"system_name"})
+			registry.MustRegister(mName)
+			mName.WithLabelValues(name).Set(1)
+		}
+	}
+
+	var reg prometheus.Registerer = registry
+	if len(labels) > 0 {
+		reg = prometheus.WrapRegistererWith(labels, registry)
+	}
+	opts := collectorOptions(u.String(), po)
+	opts.Deadline, _ = ctx.Deadline()
+	opts.Unsupported = &m.unsupported
+	success := collectors.Probe(lc, reg, opts)
+	// A failed probe may have missed some objects
+	if success {
+		obs.flush()
+	}
+	if !success && lc.err != nil {
+		return false, lc.err
+	}
+	return success, nil
+}
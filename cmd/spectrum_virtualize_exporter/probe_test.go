@@ -0,0 +1,202 @@
+// Tests of probing a single target
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSystemName(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/lssystem":
+			fmt.Fprint(w, `{"id": "0000020420A0C1D2", "name": "v7k-prod"}`)
+		case "/rest/lscurrentuser":
+			fmt.Fprint(w, `{"name": "monitor", "role": "Monitor"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	defer setConfig(config.AuthMap{}, &config.Config{})
+	module := builtinModules["ping"]
+
+	for _, tc := range []struct {
+		mode   string
+		metric string
+		labels map[string]string
+	}{
+		{config.SystemNameLabel, "spectrum_api_ping_seconds", map[string]string{"system_name": "v7k-prod"}},
+		{config.SystemNameInfo, "spectrum_api_ping_seconds", map[string]string{}},
+		{config.SystemNameInfo, "spectrum_system_name_info", map[string]string{"system_name": "v7k-prod"}},
+		{"", "spectrum_api_ping_seconds", map[string]string{}},
+	} {
+		setConfig(config.AuthMap{srv.URL: {Token: "tok"}}, &config.Config{SystemName: tc.mode})
+		registry, success, err := runProbe(context.Background(), srv.URL, probeOptions{module: module}, srv.Client())
+		if err != nil || !success {
+			t.Fatalf("runProbe with system_name %q: success %v, err %v", tc.mode, success, err)
+		}
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, mf := range mfs {
+			if mf.GetName() != tc.metric {
+				continue
+			}
+			found = true
+			labels := map[string]string{}
+			for _, lp := range mf.GetMetric()[0].GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			if fmt.Sprint(labels) != fmt.Sprint(tc.labels) {
+				t.Errorf("system_name %q: expected %s labels %v, got %v", tc.mode, tc.metric, tc.labels, labels)
+			}
+		}
+		if !found {
+			t.Errorf("system_name %q: %s not exported", tc.mode, tc.metric)
+		}
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Requested-By") != "prometheus-exporter" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"name": "monitor", "role": "Monitor"}`)
+	}))
+	defer srv.Close()
+	defer setConfig(config.AuthMap{}, &config.Config{})
+
+	for _, headers := range []map[string]string{nil, {"X-Requested-By": "prometheus-exporter"}} {
+		setConfig(config.AuthMap{srv.URL: {Token: "tok"}}, &config.Config{RequestHeaders: headers})
+		_, success, err := runProbe(context.Background(), srv.URL, probeOptions{module: builtinModules["ping"]}, srv.Client())
+		if err != nil {
+			t.Fatalf("runProbe: %v", err)
+		}
+		if want := headers != nil; success != want {
+			t.Errorf("With headers %v expected success %v, got %v", headers, want, success)
+		}
+	}
+}
+
+func TestProbeAPICalls(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/lssystem":
+			fmt.Fprint(w, `{"id": "0000020420A0C1D2", "name": "v7k-prod"}`)
+		default:
+			fmt.Fprint(w, `{"name": "monitor", "role": "Monitor"}`)
+		}
+	}))
+	defer srv.Close()
+	defer setConfig(config.AuthMap{}, &config.Config{})
+
+	for _, tc := range []struct {
+		mode  string
+		calls float64
+	}{
+		{"", 1},
+		// The system name takes a call of its own
+		{config.SystemNameLabel, 2},
+	} {
+		setConfig(config.AuthMap{srv.URL: {Token: "tok"}}, &config.Config{SystemName: tc.mode})
+		registry, success, err := runProbe(context.Background(), srv.URL, probeOptions{module: builtinModules["ping"]}, srv.Client())
+		if err != nil || !success {
+			t.Fatalf("runProbe with system_name %q: success %v, err %v", tc.mode, success, err)
+		}
+		mfs, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := -1.0
+		for _, mf := range mfs {
+			if mf.GetName() == "spectrum_probe_api_calls_total" {
+				calls = mf.GetMetric()[0].GetCounter().GetValue()
+			}
+		}
+		if calls != tc.calls {
+			t.Errorf("system_name %q: expected %v API calls, got %v", tc.mode, tc.calls, calls)
+		}
+	}
+}
+
+func TestProbeUnconfiguredTarget(t *testing.T) {
+	target := "https://unconfigured.invalid:7443"
+	_, _, err := runProbe(context.Background(), target+"/x?y", probeOptions{module: builtinModules["ping"]}, &http.Client{})
+	if failureClass(err) != failureConfig {
+		t.Errorf("Expected a config error, got %v", err)
+	}
+	targetMetricsMu.Lock()
+	defer targetMetricsMu.Unlock()
+	if _, ok := targetMetricsMap[target]; ok {
+		t.Errorf("Per-target metrics kept for a target not in the auth file")
+	}
+}
+
+func TestResponseBytesEndpoint(t *testing.T) {
+	m := metricsForTarget("https://endpoint-test:7443")
+	m.ObserveResponse("rest/lsdrive", 1000)
+	m.ObserveResponse("rest/lsdrive/0", 100)
+	m.ObserveResponse("rest/lsdrive/1", 100)
+	if n := testutil.CollectAndCount(m.responseBytes); n != 1 {
+		t.Errorf("Expected a single series for lsdrive, got %d", n)
+	}
+	if _, err := m.responseBytes.GetMetricWithLabelValues("lsdrive"); err != nil {
+		t.Errorf("Expected the lsdrive series: %v", err)
+	}
+}
+
+func TestSchemaDetailIntersection(t *testing.T) {
+	m := metricsForTarget("https://schema-test:7443")
+	obs := newProbeObserver(m)
+	obs.ObserveSchema("rest/lsvdisk", []string{"id", "name"})
+	obs.ObserveSchema("rest/lsvdisk/0", []string{"copy_id", "id", "name"})
+	obs.ObserveSchema("rest/lsvdisk/1", []string{"id", "name", "type"})
+	if n := testutil.CollectAndCount(m.schemaInfo); n != 1 {
+		t.Fatalf("Expected only the list schema before the flush, got %d series", n)
+	}
+	obs.flush()
+	if n := testutil.CollectAndCount(m.schemaInfo); n != 2 {
+		t.Fatalf("Expected a list and a detail schema, got %d series", n)
+	}
+	want := client.SchemaHash([]string{"id", "name"})
+	if v := testutil.ToFloat64(m.schemaInfo.WithLabelValues("lsvdisk", "detail", want)); v != 1 {
+		t.Errorf("Expected the detail schema to be the common fields")
+	}
+}
+
+func TestDecodeErrorEndpoint(t *testing.T) {
+	m := metricsForTarget("https://decode-test:7443")
+	m.ObserveDecodeError("rest/lsdrive/0")
+	m.ObserveDecodeError("rest/lsdrive/1")
+	if v := testutil.ToFloat64(m.decodeErrors.WithLabelValues("lsdrive")); v != 2 {
+		t.Errorf("Expected both decode errors on the lsdrive series, got %v", v)
+	}
+}
@@ -0,0 +1,121 @@
+// Reloading of the configuration at runtime
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	mReloadSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "spectrum_config_last_reload_success",
+		Help: "Whether the last configuration reload attempt was successful",
+	})
+	mReloadTime = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "spectrum_config_last_reload_success_timestamp_seconds",
+		Help: "Timestamp of the last successful configuration reload",
+	})
+)
+
+// reloadConfig loads the configuration and replaces the active one if it
+// is valid. On failure the previous configuration is kept.
+func reloadConfig() {
+	am, c, err := loadConfig()
+	if err != nil {
+		log.Printf("Configuration reload failed, keeping previous configuration: %v", err)
+		mReloadSuccess.Set(0)
+		return
+	}
+	if webServer != nil {
+		if err := webServer.apply(c.Web); err != nil {
+			log.Printf("Configuration reload failed, keeping previous configuration: %v", err)
+			mReloadSuccess.Set(0)
+			return
+		}
+	}
+	setConfig(am, c)
+	resetUnsupported()
+	mReloadSuccess.Set(1)
+	mReloadTime.SetToCurrentTime()
+	log.Printf("Configuration reloaded, loaded %d API credentials", len(am))
+}
+
+func reloadOnSignal() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		reloadConfig()
+	}
+}
+
+// watchConfigFiles reloads the configuration when the auth or config file
+// changes. The parent directories are watched rather than the files, as
+// Kubernetes and most editors replace files instead of writing to them.
+func watchConfigFiles() {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("Failed to create config file watcher: %v", err)
+		return
+	}
+	defer w.Close()
+
+	files := map[string]bool{}
+	for _, f := range []string{*authMapFile, *configFile} {
+		if f == "" {
+			continue
+		}
+		f = filepath.Clean(f)
+		files[f] = true
+		if err := w.Add(filepath.Dir(f)); err != nil {
+			log.Printf("Failed to watch %q: %v", f, err)
+			return
+		}
+	}
+
+	// Changes usually come in bursts, wait for them to settle
+	var pending <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			// Kubernetes updates mounted volumes by swapping the ..data symlink
+			if files[filepath.Clean(ev.Name)] || filepath.Base(ev.Name) == "..data" {
+				pending = time.After(time.Second)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Config file watcher error: %v", err)
+		case <-pending:
+			pending = nil
+			reloadConfig()
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Resolution of the target host names
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// DNSError is returned when dialing a target fails to resolve its host
+// name, to tell DNS problems apart from the target being unreachable
+type DNSError struct {
+	Host string
+	Err  error
+}
+
+func (e *DNSError) Error() string {
+	return fmt.Sprintf("DNS resolution of %q failed: %v", e.Host, e.Err)
+}
+
+func (e *DNSError) Unwrap() error {
+	return e.Err
+}
+
+// dnsObserver is notified about the duration of the DNS lookups done for
+// a probe, found in the context of the dial
+type dnsObserver interface {
+	ObserveDNSLookup(d time.Duration)
+}
+
+type dnsObserverKey struct{}
+
+// withDNSObserver returns a context whose dials report their DNS lookups
+// to obs
+func withDNSObserver(ctx context.Context, obs dnsObserver) context.Context {
+	return context.WithValue(ctx, dnsObserverKey{}, obs)
+}
+
+type cachedAddrs struct {
+	addrs   []string
+	expires time.Time
+}
+
+// resolver resolves the target host names before dialing, caching the
+// addresses of the configured targets for ttl. Slow name servers
+// otherwise take their share of every probe without showing up anywhere.
+type resolver struct {
+	// lookup is net.DefaultResolver.LookupHost unless testing
+	lookup     func(ctx context.Context, host string) ([]string, error)
+	dialer     *net.Dialer
+	ttl        time.Duration
+	timeout    time.Duration
+	preferIPv4 bool
+
+	mu    sync.Mutex
+	cache map[string]cachedAddrs
+}
+
+func newResolver(dialer *net.Dialer, ttl time.Duration, timeout time.Duration, preferIPv4 bool) *resolver {
+	return &resolver{
+		lookup:     net.DefaultResolver.LookupHost,
+		dialer:     dialer,
+		ttl:        ttl,
+		timeout:    timeout,
+		preferIPv4: preferIPv4,
+		cache:      map[string]cachedAddrs{},
+	}
+}
+
+// resolve returns the addresses of host, IPv4 first if preferred
+func (r *resolver) resolve(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+	r.mu.Lock()
+	c, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.addrs, nil
+	}
+
+	lctx := ctx
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		lctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	addrs, err := r.lookup(lctx, host)
+	if obs, ok := ctx.Value(dnsObserverKey{}).(dnsObserver); ok {
+		obs.ObserveDNSLookup(time.Since(start))
+	}
+	if err != nil {
+		if errors.Is(lctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			err = fmt.Errorf("timed out after %v", r.timeout)
+		}
+		return nil, &DNSError{Host: host, Err: err}
+	}
+	if r.preferIPv4 {
+		var v4, v6 []string
+		for _, a := range addrs {
+			if ip := net.ParseIP(a); ip != nil && ip.To4() != nil {
+				v4 = append(v4, a)
+			} else {
+				v6 = append(v6, a)
+			}
+		}
+		addrs = append(v4, v6...)
+	}
+	// Only the configured targets are cached, which bounds the cache
+	if r.ttl > 0 && configuredHost(host) {
+		r.mu.Lock()
+		now := time.Now()
+		for h, c := range r.cache {
+			if now.After(c.expires) {
+				delete(r.cache, h)
+			}
+		}
+		r.cache[host] = cachedAddrs{addrs: addrs, expires: now.Add(r.ttl)}
+		r.mu.Unlock()
+	}
+	return addrs, nil
+}
+
+// DialContext resolves the host of addr and dials its addresses in turn
+func (r *resolver) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := r.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var firstErr error
+	for _, a := range addrs {
+		conn, err := r.dialer.DialContext(ctx, network, net.JoinHostPort(a, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if firstErr == nil {
+		firstErr = &DNSError{Host: host, Err: errors.New("no addresses")}
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,111 @@
+// Tests of the resolution of the target host names
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+)
+
+type lookupObserver struct {
+	lookups int
+}
+
+func (o *lookupObserver) ObserveDNSLookup(d time.Duration) {
+	o.lookups++
+}
+
+func TestResolver(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	setConfig(config.AuthMap{"https://v7k.example.com:7443": {Token: "tok"}}, &config.Config{})
+	defer setConfig(config.AuthMap{}, &config.Config{})
+	r := newResolver(&net.Dialer{}, time.Minute, 50*time.Millisecond, true)
+	r.lookup = func(ctx context.Context, host string) ([]string, error) {
+		switch host {
+		case "v7k.example.com", "other.example.com":
+			return []string{"::2", "127.0.0.1"}, nil
+		case "slow.example.com":
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	obs := &lookupObserver{}
+	ctx := withDNSObserver(context.Background(), obs)
+
+	addrs, err := r.resolve(ctx, "v7k.example.com")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if want := []string{"127.0.0.1", "::2"}; !reflect.DeepEqual(addrs, want) {
+		t.Errorf("Expected IPv4 first %v, got %v", want, addrs)
+	}
+	c, err := r.DialContext(ctx, "tcp", net.JoinHostPort("v7k.example.com", port))
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	c.Close()
+	if obs.lookups != 1 {
+		t.Errorf("Expected the dial to use the cached addresses, got %d lookups", obs.lookups)
+	}
+
+	// Hosts not in the auth file are not cached, and expired entries are
+	// dropped
+	r.cache = map[string]cachedAddrs{
+		"expired.example.com": {addrs: []string{"127.0.0.1"}, expires: time.Now().Add(-time.Second)},
+	}
+	if _, err := r.resolve(ctx, "other.example.com"); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if _, err := r.resolve(ctx, "v7k.example.com"); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if len(r.cache) != 1 {
+		t.Errorf("Expected only v7k.example.com to be cached, got %v", r.cache)
+	}
+
+	for host, msg := range map[string]string{"slow.example.com": "timed out", "missing.example.com": "no such host"} {
+		_, err := r.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+		var de *DNSError
+		if !errors.As(err, &de) || !strings.Contains(err.Error(), msg) {
+			t.Errorf("Expected DNS error %q for %s, got %v", msg, host, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Generation of Prometheus alerting rules matching the exported metrics
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/collectors"
+	"gopkg.in/yaml.v2"
+)
+
+type alertRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type ruleGroup struct {
+	Name  string      `yaml:"name"`
+	Rules []alertRule `yaml:"rules"`
+}
+
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+// camelCase turns "fc_port" into "FcPort"
+func camelCase(s string) string {
+	var r string
+	for _, p := range strings.Split(s, "_") {
+		if p == "" {
+			continue
+		}
+		r += strings.ToUpper(p[:1]) + p[1:]
+	}
+	return r
+}
+
+// alertRules generates the alerting rules for the metrics currently
+// exported by the collectors
+func alertRules() ruleFile {
+	known := map[string]bool{}
+	for _, mi := range collectors.Metrics() {
+		known[mi.Name] = true
+	}
+
+	rules := []alertRule{
+		{
+			Alert:  "SpectrumProbeFailed",
+			Expr:   "probe_success == 0",
+			For:    "10m",
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary": "Probe of Spectrum Virtualize target {{ $labels.instance }} is failing",
+			},
+		},
+	}
+
+	for _, sm := range collectors.StatusMetrics {
+		if !known[sm.Metric] {
+			continue
+		}
+		object := strings.ReplaceAll(sm.Object, "_", " ")
+		rules = append(rules, alertRule{
+			Alert:  "Spectrum" + camelCase(sm.Object) + "Unhealthy",
+			Expr:   fmt.Sprintf("%s{%s=~%q} == 1", sm.Metric, sm.Label, strings.Join(sm.Unhealthy(), "|")),
+			For:    "5m",
+			Labels: map[string]string{"severity": "critical"},
+			Annotations: map[string]string{
+				"summary": fmt.Sprintf("A %s of {{ $labels.instance }} is {{ $labels.%s }}", object, sm.Label),
+			},
+		})
+	}
+
+	if known["spectrum_pool_free_bytes"] && known["spectrum_pool_capacity_bytes"] {
+		rules = append(rules, alertRule{
+			Alert:  "SpectrumPoolAlmostFull",
+			Expr:   "spectrum_pool_free_bytes / spectrum_pool_capacity_bytes < 0.1",
+			For:    "30m",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": "Pool {{ $labels.name }} of {{ $labels.instance }} has less than 10% free capacity",
+			},
+		})
+	}
+	if known["spectrum_volume_copy_synchronized"] {
+		rules = append(rules, alertRule{
+			Alert:  "SpectrumVolumeCopyNotSynchronized",
+			Expr:   "spectrum_volume_copy_synchronized == 0",
+			For:    "1h",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": "Copy {{ $labels.copy_id }} of volume {{ $labels.volume_name }} of {{ $labels.instance }} is not synchronized",
+			},
+		})
+	}
+	if known["spectrum_keyserver_certificate_expiry_timestamp_seconds"] {
+		rules = append(rules, alertRule{
+			Alert:  "SpectrumKeyServerCertificateExpiring",
+			Expr:   "spectrum_keyserver_certificate_expiry_timestamp_seconds - time() < 30 * 86400",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": "Certificate of key server {{ $labels.name }} of {{ $labels.instance }} expires within 30 days",
+			},
+		})
+	}
+	if known["spectrum_feature_trial_days_remaining"] {
+		rules = append(rules, alertRule{
+			Alert:  "SpectrumFeatureTrialExpiring",
+			Expr:   "spectrum_feature_trial_days_remaining < 14",
+			Labels: map[string]string{"severity": "warning"},
+			Annotations: map[string]string{
+				"summary": "Trial of feature {{ $labels.name }} of {{ $labels.instance }} expires within 14 days",
+			},
+		})
+	}
+
+	return ruleFile{Groups: []ruleGroup{{Name: "spectrum_virtualize_exporter", Rules: rules}}}
+}
+
+// writeAlertRules writes the generated rules as YAML to path, or to stdout
+// if path is "-"
+func writeAlertRules(path string) error {
+	b, err := yaml.Marshal(alertRules())
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
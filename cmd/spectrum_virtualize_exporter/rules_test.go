@@ -0,0 +1,50 @@
+// Tests of the alerting rules generation
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestAlertRules(t *testing.T) {
+	b, err := yaml.Marshal(alertRules())
+	if err != nil {
+		t.Fatalf("yaml.Marshal: %v", err)
+	}
+	var rf ruleFile
+	if err := yaml.Unmarshal(b, &rf); err != nil {
+		t.Fatalf("yaml.Unmarshal: %v", err)
+	}
+
+	exprs := map[string]string{}
+	for _, r := range rf.Groups[0].Rules {
+		exprs[r.Alert] = r.Expr
+	}
+	for alert, expr := range map[string]string{
+		"SpectrumDriveUnhealthy":            `spectrum_drive_status{status=~"offline|degraded|other"} == 1`,
+		"SpectrumFcPortUnhealthy":           `spectrum_fc_port_status{status=~"inactive_configured|other"} == 1`,
+		"SpectrumPoolAlmostFull":            `spectrum_pool_free_bytes / spectrum_pool_capacity_bytes < 0.1`,
+		"SpectrumVolumeCopyNotSynchronized": `spectrum_volume_copy_synchronized == 0`,
+	} {
+		if exprs[alert] != expr {
+			t.Errorf("Expected %s to be %q, got %q", alert, expr, exprs[alert])
+		}
+	}
+}
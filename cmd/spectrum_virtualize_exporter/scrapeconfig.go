@@ -0,0 +1,98 @@
+// Generation of the Prometheus scrape configuration for the targets
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v2"
+)
+
+type staticConfig struct {
+	Targets []string `yaml:"targets"`
+}
+
+type relabelConfig struct {
+	SourceLabels []string `yaml:"source_labels,omitempty"`
+	TargetLabel  string   `yaml:"target_label"`
+	Replacement  string   `yaml:"replacement,omitempty"`
+}
+
+type scrapeConfig struct {
+	JobName        string          `yaml:"job_name"`
+	ScrapeInterval string          `yaml:"scrape_interval"`
+	ScrapeTimeout  string          `yaml:"scrape_timeout"`
+	MetricsPath    string          `yaml:"metrics_path"`
+	StaticConfigs  []staticConfig  `yaml:"static_configs"`
+	RelabelConfigs []relabelConfig `yaml:"relabel_configs"`
+}
+
+type scrapeConfigFile struct {
+	ScrapeConfigs []scrapeConfig `yaml:"scrape_configs"`
+}
+
+// scrapeConfigs generates a scrape job probing every target of am through
+// the exporter listening on exporter. The scrape timeout matches the probe
+// timeout, as Prometheus gives up after 10s by default.
+func scrapeConfigs(am config.AuthMap, exporter string, timeout time.Duration) scrapeConfigFile {
+	targets := []string{}
+	for t := range am {
+		targets = append(targets, t)
+	}
+	sort.Strings(targets)
+
+	interval := time.Minute
+	if timeout > interval {
+		interval = timeout
+	}
+	return scrapeConfigFile{ScrapeConfigs: []scrapeConfig{{
+		JobName:        "spectrum_virtualize",
+		ScrapeInterval: model.Duration(interval).String(),
+		ScrapeTimeout:  model.Duration(timeout).String(),
+		MetricsPath:    "/probe",
+		StaticConfigs:  []staticConfig{{Targets: targets}},
+		RelabelConfigs: []relabelConfig{
+			{SourceLabels: []string{"__address__"}, TargetLabel: "__param_target"},
+			{SourceLabels: []string{"__param_target"}, TargetLabel: "instance"},
+			{TargetLabel: "__address__", Replacement: exporter},
+		},
+	}}}
+}
+
+// writeScrapeConfig writes the scrape configuration for the targets of the
+// -auth-file as YAML to path, or to stdout if path is "-"
+func writeScrapeConfig(path string, exporter string, timeout time.Duration) error {
+	am, _, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(scrapeConfigs(am, exporter, timeout))
+	if err != nil {
+		return err
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
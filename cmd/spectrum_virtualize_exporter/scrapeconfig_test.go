@@ -0,0 +1,65 @@
+// Tests of the generated Prometheus scrape configuration
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"gopkg.in/yaml.v2"
+)
+
+func TestScrapeConfigs(t *testing.T) {
+	am := config.AuthMap{
+		"https://v7000-2:7443": {Token: "tok"},
+		"https://v7000-1:7443": {Token: "tok"},
+	}
+	b, err := yaml.Marshal(scrapeConfigs(am, "exporter:9747", 30*time.Second))
+	if err != nil {
+		t.Fatalf("yaml.Marshal: %v", err)
+	}
+	want := `scrape_configs:
+- job_name: spectrum_virtualize
+  scrape_interval: 1m
+  scrape_timeout: 30s
+  metrics_path: /probe
+  static_configs:
+  - targets:
+    - https://v7000-1:7443
+    - https://v7000-2:7443
+  relabel_configs:
+  - source_labels:
+    - __address__
+    target_label: __param_target
+  - source_labels:
+    - __param_target
+    target_label: instance
+  - target_label: __address__
+    replacement: exporter:9747
+`
+	if string(b) != want {
+		t.Errorf("Expected scrape configuration:\n%s\ngot:\n%s", want, b)
+	}
+
+	// Prometheus rejects a timeout longer than the interval
+	sc := scrapeConfigs(am, "exporter:9747", 2*time.Minute).ScrapeConfigs[0]
+	if sc.ScrapeInterval != "2m" {
+		t.Errorf("Expected the interval to cover the timeout, got %s", sc.ScrapeInterval)
+	}
+}
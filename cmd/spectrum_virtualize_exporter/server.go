@@ -0,0 +1,211 @@
+// HTTP server rebinding on configuration changes
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+)
+
+// server serves the exporter's endpoints according to the web section of
+// the configuration. A new listen address is bound before the previous
+// listener is shut down, letting probes in flight complete. Turning TLS on
+// or off binds the same address again right after closing the listener,
+// the probes in flight still complete. Certificates are reloaded without
+// rebinding.
+type server struct {
+	handler http.Handler
+	// listen is listenOn unless testing
+	listen func(addr string) (net.Listener, error)
+
+	mu   sync.Mutex
+	srv  *http.Server
+	addr string
+	tls  bool
+	ln   net.Listener
+	// cert holds the current *tls.Certificate
+	cert atomic.Value
+}
+
+func newServer(handler http.Handler) *server {
+	return &server{handler: handler, listen: listenOn}
+}
+
+func (s *server) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.cert.Load().(*tls.Certificate), nil
+}
+
+// apply starts serving as configured by w. On error the previous server, if
+// any, keeps serving.
+func (s *server) apply(w config.Web) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addr := w.Listen
+	if addr == "" {
+		addr = *listen
+	}
+	useTLS := w.TLSCertFile != ""
+	if useTLS {
+		cert, err := tls.LoadX509KeyPair(w.TLSCertFile, w.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+		s.cert.Store(&cert)
+	}
+	if s.srv != nil && addr == s.addr && useTLS == s.tls {
+		return nil
+	}
+
+	old := s.srv
+	if old != nil && addr == s.addr {
+		// Only turning TLS on or off, the address has to be released
+		// first. The requests in flight complete in the background.
+		log.Printf("Restarting listener on %q", addr)
+		s.ln.Close()
+		go s.shutdown(old)
+		if err := s.serve(addr, useTLS); err != nil {
+			if rerr := s.serve(s.addr, s.tls); rerr != nil {
+				log.Printf("Failed to listen on %q again: %v", s.addr, rerr)
+			}
+			return err
+		}
+		return nil
+	}
+	if err := s.serve(addr, useTLS); err != nil {
+		return err
+	}
+	if old != nil {
+		go s.shutdown(old)
+	}
+	return nil
+}
+
+// serve starts a server listening on addr, replacing the current one
+func (s *server) serve(addr string, useTLS bool) error {
+	ln, err := s.listen(addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.handler}
+	if useTLS {
+		srv.TLSConfig = &tls.Config{GetCertificate: s.getCertificate}
+		ln = tls.NewListener(ln, srv.TLSConfig)
+	}
+	go func() {
+		// The listener is closed ahead of the shutdown when restarted
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed && !errors.Is(err, net.ErrClosed) {
+			log.Printf("HTTP server on %q failed: %v", addr, err)
+		}
+	}()
+	s.srv, s.addr, s.tls, s.ln = srv, addr, useTLS, ln
+	log.Printf("Listening on %q", addr)
+	return nil
+}
+
+// unixPrefix selects a unix socket as listen address, e.g.
+// unix:///run/spectrum_virtualize_exporter.sock
+const unixPrefix = "unix://"
+
+// listenOn listens on the TCP address or unix socket addr. A socket file
+// left behind by a previous run is replaced.
+func listenOn(addr string) (net.Listener, error) {
+	if !strings.HasPrefix(addr, unixPrefix) {
+		return net.Listen("tcp", addr)
+	}
+	path := strings.TrimPrefix(addr, unixPrefix)
+	mode, err := strconv.ParseUint(*socketMode, 8, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -unix-socket-mode %q: %v", *socketMode, err)
+	}
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("unix socket %q is in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	// The socket is created with the umask applied. It is only moved into
+	// place once its mode is set, so that it is never accessible to more
+	// than -unix-socket-mode allows.
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".socket")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, filepath.Base(path))
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// The socket is removed by unixListener instead, tmp is gone by then
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, os.FileMode(mode)); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return &unixListener{Listener: ln, path: path}, nil
+}
+
+// unixListener removes the socket file when closed
+type unixListener struct {
+	net.Listener
+	path string
+	once sync.Once
+}
+
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	l.once.Do(func() {
+		os.Remove(l.path)
+	})
+	return err
+}
+
+// shutdown stops srv after the requests in flight have completed, which
+// take at most -scrape-timeout
+func (s *server) shutdown(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(*timeoutSeconds)*time.Second+5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown of previous HTTP server failed: %v", err)
+	}
+}
+
+// webServer is nil until the exporter starts listening
+var webServer *server
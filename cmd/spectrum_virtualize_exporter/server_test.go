@@ -0,0 +1,255 @@
+// Tests of the rebinding HTTP server
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+)
+
+func TestServerRebind(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	s := newServer(mux)
+	if err := s.apply(config.Web{Listen: "127.0.0.1:0"}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	oldAddr := s.ln.Addr().String()
+
+	get := func(url string) error {
+		resp, err := http.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if string(b) != "ok" {
+			t.Errorf("Unexpected response %q", b)
+		}
+		return nil
+	}
+
+	slow := make(chan error)
+	go func() {
+		slow <- get("http://" + oldAddr + "/slow")
+	}()
+	<-started
+
+	if err := s.apply(config.Web{Listen: "localhost:0"}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if err := get("http://" + s.ln.Addr().String() + "/fast"); err != nil {
+		t.Errorf("Request to new listener failed: %v", err)
+	}
+
+	// The request in flight on the previous listener completes
+	close(release)
+	if err := <-slow; err != nil {
+		t.Errorf("Request in flight failed: %v", err)
+	}
+
+	for i := 0; ; i++ {
+		resp, err := http.Get("http://" + oldAddr + "/fast")
+		if err != nil {
+			break
+		}
+		resp.Body.Close()
+		if i == 100 {
+			t.Fatalf("Previous listener still serving")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// writeTestCert writes a self-signed certificate for localhost to dir
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServerTLSRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeTestCert(t, dir)
+
+	// The address stays the same, only TLS is turned on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	s := newServer(mux)
+	if err := s.apply(config.Web{Listen: addr}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	hc := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	get := func(url string) error {
+		resp, err := hc.Get(url)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	// Failing to listen again, the previous server is restored
+	fail := true
+	s.listen = func(addr string) (net.Listener, error) {
+		if fail {
+			fail = false
+			return nil, errors.New("injected failure")
+		}
+		return listenOn(addr)
+	}
+	tlsWeb := config.Web{Listen: addr, TLSCertFile: certFile, TLSKeyFile: keyFile}
+	if err := s.apply(tlsWeb); err == nil {
+		t.Fatalf("apply succeeded despite the failure to listen")
+	}
+	if err := get("http://" + addr + "/fast"); err != nil {
+		t.Errorf("Previous server not restored: %v", err)
+	}
+
+	if err := s.apply(tlsWeb); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	defer s.shutdown(s.srv)
+	if err := get("https://" + addr + "/fast"); err != nil {
+		t.Errorf("Request over TLS failed: %v", err)
+	}
+}
+
+func TestServerUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "exporter.sock")
+	// A socket left behind by a previous run is replaced
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	s := newServer(mux)
+	if err := s.apply(config.Web{Listen: "unix://" + path}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0660 {
+		t.Errorf("Expected socket mode 0660, got %v", fi.Mode().Perm())
+	}
+	// A socket in use is not taken over
+	if _, err := listenOn("unix://" + path); err == nil {
+		t.Errorf("Expected error listening on a socket in use")
+	}
+
+	hc := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := hc.Get("http://exporter/fast")
+	if err != nil {
+		t.Fatalf("Request over unix socket failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "ok" {
+		t.Errorf("Unexpected response %q", b)
+	}
+
+	// The socket is removed on shutdown, and nothing else is left behind
+	s.shutdown(s.srv)
+	if fis, err := ioutil.ReadDir(dir); err != nil || len(fis) != 0 {
+		t.Errorf("Expected %s to be empty, got %v, %v", dir, fis, err)
+	}
+}
@@ -0,0 +1,121 @@
+// Validation of the configured credentials at startup
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	mCredentialsValid = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "spectrum_credentials_valid",
+		Help: "Whether the credentials of the target were accepted when validated at startup, unset if the target could not be reached",
+	}, []string{"target"})
+
+	// ready is set once the exporter is ready to serve probes
+	ready int32
+)
+
+// validateTarget logs in to target and runs a trivial command
+func validateTarget(ctx context.Context, target string, hc *http.Client) error {
+	tgt, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	u := url.URL{Scheme: tgt.Scheme, Host: tgt.Host}
+	c, err := newSpectrumClient(ctx, u, hc, metricsForTarget(u.String()))
+	if err != nil {
+		return err
+	}
+	type currentUser struct {
+		Name string
+	}
+	var cu currentUser
+	// Tokens are only checked by a command. Backends emulating the REST
+	// API check the credentials when the client is created, if at all.
+	err = c.Get("rest/lscurrentuser", "", &cu)
+	var ae *client.APIError
+	if errors.As(err, &ae) && (ae.StatusCode == http.StatusUnauthorized || ae.StatusCode == http.StatusForbidden) {
+		// Any user may run lscurrentuser, so the token was rejected
+		return &client.LoginError{Request: "lscurrentuser", StatusCode: ae.StatusCode, Expected: http.StatusOK}
+	}
+	if err != nil && !client.IsUnsupported(err) {
+		return err
+	}
+	return nil
+}
+
+// validateCredentials validates the credentials of targets, at most
+// concurrency at a time, and exports the results
+func validateCredentials(targets []string, hc *http.Client, concurrency int, timeout time.Duration) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(t string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			if err := validateTarget(ctx, t, hc); err != nil {
+				// An unreachable target says nothing about its
+				// credentials
+				if c := failureClass(err); c != failureLogin && c != failureConfig {
+					log.Printf("Credentials of %q could not be validated: %v", t, err)
+					return
+				}
+				log.Printf("Credentials of %q failed validation: %v", t, err)
+				mCredentialsValid.WithLabelValues(t).Set(0)
+				return
+			}
+			mCredentialsValid.WithLabelValues(t).Set(1)
+		}(t)
+	}
+	wg.Wait()
+}
+
+// setReady marks the exporter as ready to serve probes
+func setReady() {
+	atomic.StoreInt32(&ready, 1)
+}
+
+// readyHandler tells whether the exporter is ready to serve probes, which
+// is once the credentials have been validated if asked to
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&ready) == 0 {
+		http.Error(w, "Validating credentials", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "Ready")
+}
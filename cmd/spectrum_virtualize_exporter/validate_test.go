@@ -0,0 +1,74 @@
+// Tests of the validation of the credentials
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestValidateCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "good" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"name": "monitor", "role": "Monitor"}`)
+	}))
+	defer srv.Close()
+	// Both targets reach the same server on different host names
+	other := fmt.Sprintf("http://localhost:%d", srv.Listener.Addr().(*net.TCPAddr).Port)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	setConfig(config.AuthMap{srv.URL: {Token: "good"}, other: {Token: "bad"}, closed.URL: {Token: "good"}}, &config.Config{})
+	defer setConfig(config.AuthMap{}, &config.Config{})
+
+	defer atomic.StoreInt32(&ready, atomic.LoadInt32(&ready))
+	atomic.StoreInt32(&ready, 0)
+	w := httptest.NewRecorder()
+	readyHandler(w, httptest.NewRequest("GET", "/-/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected not ready before validation, got %d", w.Code)
+	}
+
+	validateCredentials([]string{srv.URL, other, "https://unknown:7443", closed.URL}, srv.Client(), 2, time.Second)
+	setReady()
+	for target, want := range map[string]float64{srv.URL: 1, other: 0, "https://unknown:7443": 0} {
+		if v := testutil.ToFloat64(mCredentialsValid.WithLabelValues(target)); v != want {
+			t.Errorf("Expected %s to be %v, got %v", target, want, v)
+		}
+	}
+	// An unreachable target is not reported as invalid credentials
+	if mCredentialsValid.DeleteLabelValues(closed.URL) {
+		t.Errorf("Expected no validation result of the unreachable %s", closed.URL)
+	}
+
+	w = httptest.NewRecorder()
+	readyHandler(w, httptest.NewRequest("GET", "/-/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected ready after validation, got %d", w.Code)
+	}
+}
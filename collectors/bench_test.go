@@ -0,0 +1,124 @@
+// Benchmarks of the collectors on large systems
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	largeVolumes = 10000
+	largeDrives  = 2000
+)
+
+var (
+	largeOnce   sync.Once
+	largeClient *fakeClient
+)
+
+// newLargeClient returns a client serving a system with largeVolumes
+// volumes and largeDrives drives. Evaluating the fixtures takes seconds,
+// so they are evaluated once and shared, the client is only read.
+func newLargeClient() *fakeClient {
+	largeOnce.Do(func() {
+		largeClient = newFakeClient()
+		largeClient.prepare("rest/lsvdisk", "testdata/lsvdisk-large.jsonnet")
+		largeClient.prepare("rest/lsdrive", "testdata/lsdrive-large.jsonnet")
+		largeClient.prepare("rest/lshostvdiskmap", "testdata/lshostvdiskmap-large.jsonnet")
+		largeClient.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp.jsonnet")
+	})
+	return largeClient
+}
+
+// probeLarge runs the given collectors through Probe and gathers their
+// metrics, as a scrape of the exporter does
+func probeLarge(c *fakeClient, collectors ...string) error {
+	r := prometheus.NewRegistry()
+	if !Probe(c, r, &Options{Only: collectors, Enable: collectors}) {
+		return fmt.Errorf("Probe() of %v returned non-success", collectors)
+	}
+	_, err := r.Gather()
+	return err
+}
+
+func benchmarkProbe(b *testing.B, collectors ...string) {
+	c := newLargeClient()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := probeLarge(c, collectors...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDrives(b *testing.B) {
+	benchmarkProbe(b, "drive")
+}
+
+func BenchmarkUnmappedVolumes(b *testing.B) {
+	benchmarkProbe(b, "unmapped_volume")
+}
+
+func BenchmarkVolumes(b *testing.B) {
+	benchmarkProbe(b, "volume")
+}
+
+func BenchmarkVolumeProvisioning(b *testing.B) {
+	benchmarkProbe(b, "volume_provisioning")
+}
+
+func BenchmarkProbeLarge(b *testing.B) {
+	benchmarkProbe(b, "drive", "unmapped_volume", "volume", "volume_provisioning")
+}
+
+// TestLargeProbeAllocations fails when a collector allocates more per
+// object than it used to, to catch performance regressions without
+// running the benchmarks. Raise a budget only knowingly.
+func TestLargeProbeAllocations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping the evaluation of the large fixtures in short mode")
+	}
+	c := newLargeClient()
+	for _, tc := range []struct {
+		collector string
+		objects   int
+		// budget is the number of allocations allowed per object
+		budget float64
+	}{
+		{"drive", largeDrives, 200},
+		{"unmapped_volume", largeVolumes, 8},
+		{"volume", largeVolumes, 300},
+		{"volume_provisioning", largeVolumes, 60},
+	} {
+		var err error
+		allocs := testing.AllocsPerRun(2, func() {
+			err = probeLarge(c, tc.collector)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perObject := allocs / float64(tc.objects); perObject > tc.budget {
+			t.Errorf("Collector %q allocated %.1f times per object, the budget is %.0f", tc.collector, perObject, tc.budget)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+// Fuzz test of the capacity parser, seeded with the corpus
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build go1.18
+// +build go1.18
+
+package collectors
+
+import (
+	"strconv"
+	"testing"
+)
+
+func FuzzParseCapacity(f *testing.F) {
+	for _, c := range readCapacityCorpus(f) {
+		f.Add(c.input)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		b, err := parseCapacity(s)
+		if err != nil {
+			return
+		}
+		if b < 0 {
+			t.Errorf("parseCapacity(%q) = %d, expected a non-negative capacity", s, b)
+		}
+		// The capacity in bytes must parse to itself
+		if b2, err := parseCapacity(strconv.FormatInt(b, 10)); err != nil || b2 != b {
+			t.Errorf("parseCapacity(%q) = %d, but %d parses to %d, %v", s, b, b, b2, err)
+		}
+	})
+}
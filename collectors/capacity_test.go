@@ -0,0 +1,80 @@
+// Tests of the capacity parser against the corpus of observed capacities
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type capacityCase struct {
+	input string
+	want  int64
+	err   bool
+}
+
+func readCapacityCorpus(t testing.TB) []capacityCase {
+	f, err := os.Open("testdata/capacity-corpus.txt")
+	if err != nil {
+		t.Fatalf("Failed to open corpus: %v", err)
+	}
+	defer f.Close()
+	var r []capacityCase
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		l := s.Text()
+		if strings.HasPrefix(l, "#") {
+			continue
+		}
+		i := strings.LastIndex(l, "\t")
+		if i < 0 {
+			t.Fatalf("Invalid corpus line %q", l)
+		}
+		c := capacityCase{input: l[:i]}
+		if l[i+1:] == "error" {
+			c.err = true
+		} else if c.want, err = strconv.ParseInt(l[i+1:], 10, 64); err != nil {
+			t.Fatalf("Invalid corpus line %q: %v", l, err)
+		}
+		r = append(r, c)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("Failed to read corpus: %v", err)
+	}
+	return r
+}
+
+func TestParseCapacityCorpus(t *testing.T) {
+	for _, c := range readCapacityCorpus(t) {
+		got, err := parseCapacity(c.input)
+		if c.err {
+			if err == nil {
+				t.Errorf("parseCapacity(%q) = %d, expected an error", c.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCapacity(%q): %v", c.input, err)
+		} else if got != c.want {
+			t.Errorf("parseCapacity(%q) = %d, expected %d", c.input, got, c.want)
+		}
+	}
+}
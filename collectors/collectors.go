@@ -0,0 +1,417 @@
+// Registry of collectors and helpers shared between them
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector probes one aspect of a device and registers the resulting
+// metrics.
+type Collector struct {
+	Name string
+	// OptIn collectors are expensive and only run when listed in
+	// Options.Enable
+	OptIn bool
+	Probe func(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool
+}
+
+// All lists the collectors in the order they are run
+var All = []Collector{
+	{Name: "enclosure_stats", Probe: probeEnclosureStats},
+	{Name: "enclosure_psu", Probe: probeEnclosurePSUs},
+	{Name: "enclosure_fan", Probe: probeEnclosureFans},
+	{Name: "enclosure_slot", Probe: probeEnclosureSlots},
+	{Name: "pool", Probe: probePool},
+	{Name: "drive", Probe: probeDrives},
+	{Name: "node_stats", Probe: probeNodeStats},
+	{Name: "system_stats", Probe: probeSystemStats},
+	{Name: "io_group_cache", Probe: probeIOGroupCache},
+	{Name: "host", Probe: probeHost},
+	{Name: "host_cluster", Probe: probeHostClusters},
+	{Name: "host_port", OptIn: true, Probe: probeHostPorts},
+	{Name: "host_mapping", OptIn: true, Probe: probeHostMappings},
+	{Name: "fc_port", Probe: probeFCPorts},
+	{Name: "ip_port", Probe: probeIPPorts},
+	{Name: "object_limits", OptIn: true, Probe: probeObjectLimits},
+	{Name: "license", Probe: probeLicense},
+	{Name: "encryption", Probe: probeEncryption},
+	{Name: "vasa_provider", Probe: probeVASAProvider},
+	{Name: "system_time", Probe: probeSystemTime},
+	{Name: "node_info", Probe: probeNodeInfo},
+	{Name: "drive_firmware", OptIn: true, Probe: probeDriveFirmware},
+	{Name: "port_stats", Probe: probePortStats},
+	{Name: "capacity_warning", Probe: probeCapacityWarning},
+	{Name: "config_backup", OptIn: true, Probe: probeConfigBackup},
+	{Name: "partnership", Probe: probePartnerships},
+	{Name: "network", Probe: probeNetwork},
+	{Name: "throttle", Probe: probeThrottles},
+	{Name: "volume_copy", Probe: probeVolumeCopies},
+	{Name: "volume_copy_detail", OptIn: true, Probe: probeVolumeCopyDetails},
+	{Name: "unmapped_volume", OptIn: true, Probe: probeUnmappedVolumes},
+	{Name: "volume", OptIn: true, Probe: probeVolumes},
+	{Name: "volume_provisioning", OptIn: true, Probe: probeVolumeProvisioning},
+	{Name: "volume_preferred_node", OptIn: true, Probe: probeVolumePreferredNodes},
+	{Name: "migration", Probe: probeMigrations},
+	{Name: "node_hardware", Probe: probeNodeHardware},
+	{Name: "npiv", Probe: probeNPIV},
+	{Name: "easy_tier", OptIn: true, Probe: probePoolTiers},
+	{Name: "ping", OptIn: true, Probe: probePing},
+}
+
+// Options tune the behaviour of the collectors. The zero value is valid
+// and gives the defaults.
+type Options struct {
+	// Filters select the exported objects by name, keyed on collector name
+	Filters map[string]*config.ObjectFilter
+	// BytesPerMB is used to convert the *_mb statistics, defaults to MiB
+	BytesPerMB float64
+	// Enable lists the OptIn collectors to run
+	Enable []string
+	// Only restricts the collectors to run to those listed, if not nil
+	Only []string
+	// Metrics suppress individual metrics, keyed on collector name
+	Metrics map[string]*config.MetricFilter
+	// Optional collectors run after the others, and are skipped once less
+	// than MinRemaining is left until Deadline, if set
+	Optional     []string
+	Deadline     time.Time
+	MinRemaining time.Duration
+	// Unsupported, if set, collects the collectors failing on an endpoint
+	// the target does not support and skips them in later probes sharing it
+	Unsupported *UnsupportedCollectors
+
+	// names caches the object names of the probe by kind, see objectName
+	names map[string]map[string]string
+}
+
+func (o *Options) enabled(c Collector) bool {
+	if o.Only != nil && !contains(o.Only, c.Name) {
+		return false
+	}
+	if !c.OptIn {
+		return true
+	}
+	return contains(o.Enable, c.Name)
+}
+
+// outOfTime tells whether the optional collectors should be skipped
+func (o *Options) outOfTime() bool {
+	if o.Deadline.IsZero() {
+		return false
+	}
+	return timeNow().Add(o.MinRemaining).After(o.Deadline)
+}
+
+func contains(l []string, s string) bool {
+	for _, n := range l {
+		if n == s {
+			return true
+		}
+	}
+	return false
+}
+
+// boolValue returns 1 for true and 0 for false
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (o *Options) filter(collector string) *config.ObjectFilter {
+	return o.Filters[collector]
+}
+
+// MBUnits maps the accepted units of the *_mb statistics to bytes. The
+// statistics documentation only talks about "MB", while the values observed
+// on the devices so far match MiB.
+var MBUnits = map[string]float64{
+	"MiB": 1024 * 1024,
+	"MB":  1000 * 1000,
+}
+
+func (o *Options) mbToBytes(mb int) float64 {
+	if o.BytesPerMB == 0 {
+		return float64(mb) * MBUnits["MiB"]
+	}
+	return float64(mb) * o.BytesPerMB
+}
+
+// Probe runs all enabled collectors, stopping at the first one that fails.
+// The optional collectors run last, see Options.
+func Probe(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	mSkipped := newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "spectrum_collector_skipped",
+			Help: "Whether an optional collector was skipped as the probe was running out of time",
+		},
+		[]string{"collector"},
+	)
+	mSeries := newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "spectrum_collector_series_emitted",
+			Help: "Number of series exported by a collector in this probe, after the metric filters",
+		},
+		[]string{"collector"},
+	)
+	mUnsupported := newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "spectrum_collector_unsupported",
+			Help: "Whether a collector is skipped as the target does not support an endpoint it needs",
+		},
+		[]string{"collector"},
+	)
+	registry.MustRegister(mSkipped)
+	registry.MustRegister(mSeries)
+	registry.MustRegister(mUnsupported)
+
+	// TODO: Make parallel
+	for _, optional := range []bool{false, true} {
+		for _, col := range All {
+			if !opts.enabled(col) || contains(opts.Optional, col.Name) != optional {
+				continue
+			}
+			if optional && opts.outOfTime() {
+				log.Printf("Skipping optional collector %q, the probe is running out of time", col.Name)
+				mSkipped.WithLabelValues(col.Name).Set(1)
+				continue
+			}
+			if opts.Unsupported.has(col.Name) {
+				mUnsupported.WithLabelValues(col.Name).Set(1)
+				continue
+			}
+			sr := &seriesRegisterer{Registerer: registry}
+			var reg prometheus.Registerer = sr
+			if f, ok := opts.Metrics[col.Name]; ok {
+				reg = &filterRegisterer{Registerer: sr, filter: f}
+			}
+			uc := &unsupportedClient{SpectrumHTTP: c}
+			if !col.Probe(uc, reg, opts) {
+				// Older firmware lacks some endpoints, which would
+				// otherwise fail every probe of the target
+				if !uc.unsupported || opts.Unsupported == nil {
+					return false
+				}
+				log.Printf("Collector %q needs an endpoint not supported by %v, skipping it from now on", col.Name, c)
+				for _, m := range sr.collectors {
+					registry.Unregister(m)
+				}
+				opts.Unsupported.add(col.Name)
+				mUnsupported.WithLabelValues(col.Name).Set(1)
+				continue
+			}
+			mSeries.WithLabelValues(col.Name).Set(float64(sr.series()))
+		}
+	}
+	return true
+}
+
+// filterRegisterer only registers the metrics matching filter. The others
+// are silently dropped, leaving the collectors unaware.
+type filterRegisterer struct {
+	prometheus.Registerer
+	filter *config.MetricFilter
+}
+
+func (r *filterRegisterer) Register(c prometheus.Collector) error {
+	if v, ok := c.(*gaugeVec); ok {
+		if !r.filter.Match(v.name) {
+			return nil
+		}
+		return r.Registerer.Register(c)
+	}
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	keep := true
+	for d := range ch {
+		mi, ok := parseDesc(d)
+		if !ok {
+			log.Printf("Failed to tell the name of metric %v, exporting it regardless of the metric filter", d)
+			FilterErrors.Inc()
+			continue
+		}
+		if !r.filter.Match(mi.Name) {
+			keep = false
+		}
+	}
+	if !keep {
+		return nil
+	}
+	return r.Registerer.Register(c)
+}
+
+func (r *filterRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// seriesRegisterer keeps the collectors registered through it to count the
+// series they export
+type seriesRegisterer struct {
+	prometheus.Registerer
+	collectors []prometheus.Collector
+}
+
+func (r *seriesRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+func (r *seriesRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// series returns the number of series the registered collectors export
+func (r *seriesRegisterer) series() int {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for _, c := range r.collectors {
+			c.Collect(ch)
+		}
+		close(ch)
+	}()
+	n := 0
+	for range ch {
+		n++
+	}
+	return n
+}
+
+var (
+	timeNow = time.Now
+
+	// ParseErrors counts values that could not be parsed. It is not
+	// registered by default as it is meant for the exporter's own metrics.
+	ParseErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "spectrum_parse_errors_total",
+			Help: "Number of values returned by the API that could not be parsed",
+		},
+		[]string{"collector", "field"},
+	)
+)
+
+// FilterErrors counts the metrics exported regardless of the metric
+// filters as their name could not be told. Like ParseErrors it is not
+// registered by default.
+var FilterErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "spectrum_metric_filter_errors_total",
+	Help: "Number of metrics exported regardless of the metric filters as their name could not be told",
+})
+
+func logParseError(collector string, field string, value string, err error) {
+	log.Printf("Failed to parse %s %q: %v", field, value, err)
+	ParseErrors.WithLabelValues(collector, field).Inc()
+}
+
+// parseCapacity parses a capacity as reported by the API, e.g. "9.74TB"
+// where TB means TiB, into bytes. Capacities are also seen with whitespace,
+// thousands separators, lower case units and, when the CLI is told to
+// report bytes, without any unit.
+func parseCapacity(s string) (int64, error) {
+	c := strings.ToUpper(strings.NewReplacer(" ", "", ",", "").Replace(s))
+	if c != "" && strings.IndexFunc(c, func(r rune) bool { return r < '0' || r > '9' }) < 0 {
+		c += "B"
+	}
+	b, err := units.ParseBase2Bytes(strings.Replace(c, "IB", "iB", 1))
+	if err != nil {
+		return 0, err
+	}
+	if b < 0 {
+		return 0, fmt.Errorf("negative capacity %q", s)
+	}
+	return int64(b), nil
+}
+
+// linkSpeedUnits maps the unit of a port speed to bits per second
+var linkSpeedUnits = map[string]int64{
+	"MB": 1000 * 1000,
+	"GB": 1000 * 1000 * 1000,
+}
+
+// parseLinkSpeed parses a port speed as reported by the API, e.g. "16Gb"
+// for Fibre Channel and "10Gb/s" for Ethernet, into bits per second. Ports
+// without a link report "N/A", which is returned as 0. Auto-negotiating
+// ports report "auto", or the negotiated speed along with it as in
+// "auto (16Gb)", for which auto is returned as true.
+func parseLinkSpeed(s string) (bps int64, auto bool, err error) {
+	c := strings.ToUpper(strings.NewReplacer(" ", "", "(", "", ")", "", "-", "").Replace(s))
+	if strings.Contains(c, "AUTO") {
+		auto = true
+		c = strings.Replace(c, "AUTO", "", 1)
+	}
+	if c == "" || c == "N/A" {
+		return 0, auto, nil
+	}
+	c = strings.TrimSuffix(strings.TrimSuffix(c, "PS"), "/S")
+	i := strings.IndexFunc(c, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i <= 0 {
+		return 0, auto, fmt.Errorf("unknown port speed %q", s)
+	}
+	m, ok := linkSpeedUnits[c[i:]]
+	if !ok {
+		return 0, auto, fmt.Errorf("unknown port speed unit %q", s)
+	}
+	x, err := strconv.ParseFloat(c[:i], 64)
+	if err != nil {
+		return 0, auto, err
+	}
+	return int64(x * float64(m)), auto, nil
+}
+
+// spectrumTimeLayouts are the timestamp formats used by the Spectrum
+// Virtualize CLI and REST API, most commonly YYMMDDHHMMSS.
+var spectrumTimeLayouts = []string{
+	"060102150405",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	time.UnixDate,
+}
+
+func parseSpectrumTime(s string) (time.Time, error) {
+	for _, l := range spectrumTimeLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown time format %q", s)
+}
@@ -0,0 +1,71 @@
+// Tests of the gauges exported as constant metrics
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGaugeVec(t *testing.T) {
+	g := newGaugeVec(
+		prometheus.GaugeOpts{
+			Name:        "spectrum_test",
+			Help:        "Test",
+			ConstLabels: prometheus.Labels{"site": "dc1"},
+		},
+		[]string{"id", "state"},
+	)
+	r := prometheus.NewPedanticRegistry()
+	r.MustRegister(g)
+
+	// Appending to a slice with spare capacity reuses its array
+	labels := make([]string, 1, 2)
+	labels[0] = "1"
+	g.WithLabelValues(append(labels, "online")...).Set(1)
+	g.WithLabelValues(append(labels, "offline")...).Set(0)
+	g.WithLabelValues("2", "online").Inc()
+	g.WithLabelValues("2", "online").Add(2)
+	g.WithLabelValues("2", "offline").Dec()
+
+	em := `
+	# HELP spectrum_test Test
+	# TYPE spectrum_test gauge
+	spectrum_test{id="1",site="dc1",state="offline"} 0
+	spectrum_test{id="1",site="dc1",state="online"} 1
+	spectrum_test{id="2",site="dc1",state="offline"} -1
+	spectrum_test{id="2",site="dc1",state="online"} 3
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestGaugeVecInvalidLabel(t *testing.T) {
+	g := newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_test", Help: "Test"}, []string{"name"})
+	r := prometheus.NewRegistry()
+	r.MustRegister(g)
+	g.WithLabelValues("\xff").Set(1)
+	if _, err := r.Gather(); err == nil {
+		t.Errorf("Expected gathering a label value of invalid UTF-8 to fail")
+	}
+}
@@ -0,0 +1,148 @@
+// Health score computed from the component status metrics
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// healthComponent is a status metric contributing to the health score
+type healthComponent struct {
+	Name   string
+	Metric string
+	Weight float64
+}
+
+var healthComponents = []healthComponent{
+	{Name: "node", Metric: "spectrum_node_status", Weight: 4},
+	{Name: "pool", Metric: "spectrum_pool_status", Weight: 3},
+	{Name: "drive", Metric: "spectrum_drive_status", Weight: 2},
+	{Name: "fc_port", Metric: "spectrum_fc_port_status", Weight: 1},
+}
+
+// objectKey returns the identity of the object of the status metric m,
+// made up of all labels but the state label, and the state of m
+func objectKey(sm StatusMetric, m *dto.Metric) (string, string) {
+	var key []string
+	status := ""
+	for _, lp := range m.Label {
+		if lp.GetName() == sm.Label {
+			status = lp.GetValue()
+		} else {
+			key = append(key, lp.GetName()+"="+lp.GetValue())
+		}
+	}
+	sort.Strings(key)
+	return strings.Join(key, ","), status
+}
+
+// healthyRatio returns the ratio of objects in mf that have one of the
+// healthy statuses set, and false if mf contains no objects.
+func healthyRatio(sm StatusMetric, mf *dto.MetricFamily) (float64, bool) {
+	healthy := map[string]bool{}
+	for _, m := range mf.Metric {
+		k, status := objectKey(sm, m)
+		if _, ok := healthy[k]; !ok {
+			healthy[k] = false
+		}
+		if m.Gauge.GetValue() != 1 {
+			continue
+		}
+		for _, h := range sm.Healthy {
+			if status == h {
+				healthy[k] = true
+			}
+		}
+	}
+	if len(healthy) == 0 {
+		return 0, false
+	}
+	n := 0
+	for _, h := range healthy {
+		if h {
+			n++
+		}
+	}
+	return float64(n) / float64(len(healthy)), true
+}
+
+// Health computes a health score of the target from the component status
+// metrics gathered from g, and registers the results into registry.
+// Components without any collected objects do not affect the score.
+func Health(g prometheus.Gatherer, registry prometheus.Registerer) error {
+	var (
+		mScore = prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "spectrum_health_score",
+				Help: "Weighted health score of the target between 0 (all components unhealthy) and 100 (all healthy)",
+			},
+		)
+		mDegraded = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_health_component_degraded",
+				Help: "Whether any object of the component is in an unhealthy state",
+			},
+			[]string{"component"},
+		)
+	)
+
+	mfs, err := g.Gather()
+	if err != nil {
+		return err
+	}
+	families := map[string]*dto.MetricFamily{}
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+
+	registry.MustRegister(mDegraded)
+
+	var score, weights float64
+	for _, hc := range healthComponents {
+		mf, ok := families[hc.Metric]
+		if !ok {
+			continue
+		}
+		sm, ok := statusMetric(hc.Metric)
+		if !ok {
+			continue
+		}
+		r, ok := healthyRatio(sm, mf)
+		if !ok {
+			continue
+		}
+		score += r * hc.Weight
+		weights += hc.Weight
+		degraded := 0.0
+		if r < 1 {
+			degraded = 1.0
+		}
+		mDegraded.WithLabelValues(hc.Name).Set(degraded)
+	}
+	if weights == 0 {
+		// Nothing known about the target, neither healthy nor unhealthy
+		return nil
+	}
+	registry.MustRegister(mScore)
+	mScore.Set(100 * score / weights)
+	return nil
+}
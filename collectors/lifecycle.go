@@ -0,0 +1,111 @@
+// Tracking of objects appearing and disappearing between probes
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Lifecycle counts the objects created and deleted on a target by
+// comparing the objects of the status metrics between probes. It must
+// outlive the probes, and is registered as a collector together with them.
+type Lifecycle struct {
+	mu sync.Mutex
+	// seen holds the objects of the last probe by object kind
+	seen map[string]map[string]bool
+
+	mCreated *prometheus.CounterVec
+	mDeleted *prometheus.CounterVec
+}
+
+// NewLifecycle returns a Lifecycle for one target, without any objects
+// seen yet
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{
+		seen: map[string]map[string]bool{},
+		mCreated: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "spectrum_object_created_total",
+				Help: "Number of objects that appeared on the target since the exporter started",
+			},
+			[]string{"object"},
+		),
+		mDeleted: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "spectrum_object_deleted_total",
+				Help: "Number of objects that disappeared from the target since the exporter started",
+			},
+			[]string{"object"},
+		),
+	}
+}
+
+// Update compares the objects gathered from g with those of the previous
+// update. Object kinds missing from g, e.g. due to a failed collector, keep
+// their previous objects, and the first update of a kind only records the
+// objects as the baseline.
+func (l *Lifecycle) Update(g prometheus.Gatherer) error {
+	mfs, err := g.Gather()
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, mf := range mfs {
+		sm, ok := statusMetric(mf.GetName())
+		if !ok {
+			continue
+		}
+		objects := map[string]bool{}
+		for _, m := range mf.Metric {
+			k, _ := objectKey(sm, m)
+			objects[k] = true
+		}
+		prev, ok := l.seen[sm.Object]
+		l.seen[sm.Object] = objects
+		// Initialize the counters so that the first change is an increase
+		created := l.mCreated.WithLabelValues(sm.Object)
+		deleted := l.mDeleted.WithLabelValues(sm.Object)
+		if !ok {
+			continue
+		}
+		for k := range objects {
+			if !prev[k] {
+				created.Inc()
+			}
+		}
+		for k := range prev {
+			if !objects[k] {
+				deleted.Inc()
+			}
+		}
+	}
+	return nil
+}
+
+func (l *Lifecycle) Describe(ch chan<- *prometheus.Desc) {
+	l.mCreated.Describe(ch)
+	l.mDeleted.Describe(ch)
+}
+
+func (l *Lifecycle) Collect(ch chan<- prometheus.Metric) {
+	l.mCreated.Collect(ch)
+	l.mDeleted.Collect(ch)
+}
@@ -0,0 +1,171 @@
+// Metadata about the metrics exported by the collectors
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricInfo describes a metric exported by a collector
+type MetricInfo struct {
+	Name      string   `json:"name"`
+	Help      string   `json:"help"`
+	Type      string   `json:"type"`
+	Labels    []string `json:"labels"`
+	Collector string   `json:"collector"`
+	// Endpoints are the API commands called by the collector
+	Endpoints []string `json:"source_endpoints"`
+}
+
+type capturedDesc struct {
+	desc *prometheus.Desc
+	typ  string
+}
+
+// captureRegisterer records the descriptors of everything registered
+type captureRegisterer struct {
+	descs []capturedDesc
+}
+
+func metricType(c prometheus.Collector) string {
+	switch c.(type) {
+	case prometheus.Gauge, *prometheus.GaugeVec, *gaugeVec:
+		return "gauge"
+	case prometheus.Counter, *prometheus.CounterVec:
+		return "counter"
+	case prometheus.Histogram, *prometheus.HistogramVec:
+		return "histogram"
+	case prometheus.Summary, *prometheus.SummaryVec:
+		return "summary"
+	}
+	return "untyped"
+}
+
+func (r *captureRegisterer) Register(c prometheus.Collector) error {
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+	for d := range ch {
+		r.descs = append(r.descs, capturedDesc{d, metricType(c)})
+	}
+	return nil
+}
+
+func (r *captureRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		r.Register(c)
+	}
+}
+
+func (r *captureRegisterer) Unregister(c prometheus.Collector) bool {
+	return false
+}
+
+// recordingClient records the requested paths and returns empty responses
+type recordingClient struct {
+	paths []string
+}
+
+func (c *recordingClient) record(path string) {
+	for _, p := range c.paths {
+		if p == path {
+			return
+		}
+	}
+	c.paths = append(c.paths, path)
+}
+
+func (c *recordingClient) Get(path string, query string, obj interface{}) error {
+	c.record(path)
+	return nil
+}
+
+func (c *recordingClient) GetEach(path string, query string, obj interface{}, fn func()) error {
+	c.record(path)
+	return nil
+}
+
+// descRE matches the string representation of a *prometheus.Desc, which
+// is the only way to get at its contents
+var descRE = regexp.MustCompile(`^Desc{fqName: ("(?:[^"\\]|\\.)*"), help: ("(?:[^"\\]|\\.)*"), constLabels: {.*}, variableLabels: \[(.*)\]}$`)
+
+func parseDesc(d *prometheus.Desc) (MetricInfo, bool) {
+	m := descRE.FindStringSubmatch(d.String())
+	if m == nil {
+		return MetricInfo{}, false
+	}
+	name, err := strconv.Unquote(m[1])
+	if err != nil {
+		return MetricInfo{}, false
+	}
+	help, err := strconv.Unquote(m[2])
+	if err != nil {
+		return MetricInfo{}, false
+	}
+	return MetricInfo{Name: name, Help: help, Labels: strings.Fields(m[3])}, true
+}
+
+var (
+	metricsOnce sync.Once
+	metrics     []MetricInfo
+)
+
+// Metrics lists the metrics registered by all collectors, including the
+// OptIn ones, found by running them against a client returning empty
+// responses. The first call mutes the standard logger while the collectors
+// run, so programs logging concurrently should call it at startup.
+func Metrics() []MetricInfo {
+	metricsOnce.Do(func() {
+		metrics = describeAll()
+	})
+	return metrics
+}
+
+func describeAll() []MetricInfo {
+	// The collectors log their failures, which are expected here
+	w := log.Writer()
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(w)
+
+	var r []MetricInfo
+	for _, col := range All {
+		reg := &captureRegisterer{}
+		c := &recordingClient{}
+		col.Probe(c, reg, &Options{})
+		for _, d := range reg.descs {
+			mi, ok := parseDesc(d.desc)
+			if !ok {
+				continue
+			}
+			mi.Type = d.typ
+			mi.Collector = col.Name
+			mi.Endpoints = c.paths
+			r = append(r, mi)
+		}
+	}
+	return r
+}
@@ -0,0 +1,70 @@
+// Resolution of object names for metrics of APIs reporting only IDs
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"log"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+)
+
+// nameCommands are the commands listing the objects of each kind whose
+// names can be resolved
+var nameCommands = map[string]string{
+	"node":   "rest/lsnodecanister",
+	"pool":   "rest/lsmdiskgrp",
+	"volume": "rest/lsvdisk",
+	"host":   "rest/lshost",
+}
+
+// objectName returns the name of the object of the given kind and ID, so
+// that metrics of APIs reporting only the ID can carry the name as well.
+// The objects of a kind are listed at most once per probe and only when
+// first needed. Unknown objects have an empty name.
+func (o *Options) objectName(c client.SpectrumHTTP, kind string, id string) string {
+	if o.names == nil {
+		o.names = map[string]map[string]string{}
+	}
+	names, ok := o.names[kind]
+	if !ok {
+		names = map[string]string{}
+		o.names[kind] = names
+		type object struct {
+			ID   string
+			Name string
+		}
+		var obj object
+		// There may be tens of thousands of volumes
+		err := c.GetEach(nameCommands[kind], "", &obj, func() {
+			names[obj.ID] = obj.Name
+		})
+		if err != nil {
+			log.Printf("Error: Failed to resolve %s names: %v", kind, err)
+		}
+	}
+	return names[id]
+}
+
+// nodeName returns name if set, and otherwise the name of the node with
+// the given ID, so that all node metrics carry both node_id and node_name.
+func (o *Options) nodeName(c client.SpectrumHTTP, id string, name string) string {
+	if name != "" {
+		return name
+	}
+	return o.objectName(c, "node", id)
+}
@@ -0,0 +1,2810 @@
+// All currently supported probes
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cpuCoreStatRE matches the per-core CPU statistics, e.g. cpu_core_3_pc,
+// which only some firmware levels report
+var cpuCoreStatRE = regexp.MustCompile(`^cpu_core_?(\d+)_pc$`)
+
+// latencyStatRE matches the read and write latency statistics of the
+// volume (front-end), MDisk (back-end) and drive layers, e.g. vdisk_r_ms
+var latencyStatRE = regexp.MustCompile(`^(vdisk|mdisk|drive)_([rw])_ms$`)
+
+var latencyOps = map[string]string{"r": "read", "w": "write"}
+
+func probeNodeStats(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mCmpCPU = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_compression_usage_ratio",
+				Help: "Current ratio of allocated CPU for compresion",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mSysCPU = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_system_usage_ratio",
+				Help: "Current ratio of allocated CPU for system",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mCacheWrite = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_write_cache_usage_ratio",
+				Help: "Ratio of the write cache usage for the node",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mCacheTotal = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_total_cache_usage_ratio",
+				Help: "Total percentage for both the write and read cache usage for the node",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mFcBytes = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_fc_bps",
+				Help: "Current bytes-per-second being transferred over Fibre Channel",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mFcIO = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_fc_iops",
+				Help: "Current I/O-per-second being transferred over Fibre Channel",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mISCSIBytes = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_iscsi_bps",
+				Help: "Current bytes-per-second being transferred over iSCSI",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mISCSIIO = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_iscsi_iops",
+				Help: "Current I/O-per-second being transferred over iSCSI",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mSASBytes = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_sas_bps",
+				Help: "Current bytes-per-second being transferred over backend SAS",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mSASIO = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_sas_iops",
+				Help: "Current I/O-per-second being transferred over backend SAS",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mFcRaw = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_fc_mb_raw",
+				Help: "Raw fc_mb value as reported by the node, before unit conversion",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mISCSIRaw = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_iscsi_mb_raw",
+				Help: "Raw iscsi_mb value as reported by the node, before unit conversion",
+			},
+			[]string{"node_id", "node_name"},
+		)
+		mSASRaw = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_sas_mb_raw",
+				Help: "Raw sas_mb value as reported by the node, before unit conversion",
+			},
+			[]string{"node_id", "node_name"},
+		)
+	)
+
+	mCoreCPU := newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "spectrum_node_cpu_core_usage_ratio",
+			Help: "Current ratio of CPU usage per core, where the node reports it",
+		},
+		[]string{"node_id", "node_name", "core"},
+	)
+
+	mLatency := newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "spectrum_node_latency_seconds",
+			Help: "Current average latency of the node by layer, vdisk (front-end), mdisk (back-end) or drive",
+		},
+		[]string{"node_id", "node_name", "layer", "op"},
+	)
+
+	registry.MustRegister(mSysCPU)
+	registry.MustRegister(mCmpCPU)
+	registry.MustRegister(mCoreCPU)
+	registry.MustRegister(mLatency)
+	registry.MustRegister(mCacheWrite)
+	registry.MustRegister(mCacheTotal)
+	registry.MustRegister(mFcBytes)
+	registry.MustRegister(mFcIO)
+	registry.MustRegister(mISCSIBytes)
+	registry.MustRegister(mISCSIIO)
+	registry.MustRegister(mSASBytes)
+	registry.MustRegister(mSASIO)
+	registry.MustRegister(mFcRaw)
+	registry.MustRegister(mISCSIRaw)
+	registry.MustRegister(mSASRaw)
+
+	type nodeStat struct {
+		NodeID      string `json:"node_id"`
+		NodeName    string `json:"node_name"`
+		StatName    string `json:"stat_name"`
+		StatCurrent int    `json:"stat_current,string"`
+	}
+	var st []nodeStat
+
+	if err := c.Get("rest/lsnodecanisterstats", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		name := opts.nodeName(c, s.NodeID, s.NodeName)
+		if !opts.filter("node_stats").Match(name) {
+			continue
+		}
+		if s.StatName == "compression_cpu_pc" {
+			mCmpCPU.WithLabelValues(s.NodeID, name).Set(float64(s.StatCurrent) / 100.0)
+		} else if s.StatName == "cpu_pc" {
+			mSysCPU.WithLabelValues(s.NodeID, name).Set(float64(s.StatCurrent) / 100.0)
+		} else if s.StatName == "fc_mb" {
+			mFcBytes.WithLabelValues(s.NodeID, name).Set(opts.mbToBytes(s.StatCurrent))
+			mFcRaw.WithLabelValues(s.NodeID, name).Set(float64(s.StatCurrent))
+		} else if s.StatName == "fc_io" {
+			mFcIO.WithLabelValues(s.NodeID, name).Set(float64(s.StatCurrent))
+		} else if s.StatName == "iscsi_mb" {
+			mISCSIBytes.WithLabelValues(s.NodeID, name).Set(opts.mbToBytes(s.StatCurrent))
+			mISCSIRaw.WithLabelValues(s.NodeID, name).Set(float64(s.StatCurrent))
+		} else if s.StatName == "iscsi_io" {
+			mISCSIIO.WithLabelValues(s.NodeID, name).Set(float64(s.StatCurrent))
+		} else if s.StatName == "sas_mb" {
+			mSASBytes.WithLabelValues(s.NodeID, name).Set(opts.mbToBytes(s.StatCurrent))
+			mSASRaw.WithLabelValues(s.NodeID, name).Set(float64(s.StatCurrent))
+		} else if s.StatName == "sas_io" {
+			mSASIO.WithLabelValues(s.NodeID, name).Set(float64(s.StatCurrent))
+		} else if s.StatName == "write_cache_pc" {
+			mCacheWrite.WithLabelValues(s.NodeID, name).Set(float64(s.StatCurrent) / 100.0)
+		} else if s.StatName == "total_cache_pc" {
+			mCacheTotal.WithLabelValues(s.NodeID, name).Set(float64(s.StatCurrent) / 100.0)
+		} else if m := cpuCoreStatRE.FindStringSubmatch(s.StatName); m != nil {
+			mCoreCPU.WithLabelValues(s.NodeID, name, m[1]).Set(float64(s.StatCurrent) / 100.0)
+		} else if m := latencyStatRE.FindStringSubmatch(s.StatName); m != nil {
+			mLatency.WithLabelValues(s.NodeID, name, m[1], latencyOps[m[2]]).Set(float64(s.StatCurrent) / 1000.0)
+		}
+	}
+	return true
+}
+
+func probeIOGroupCache(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"io_group_id", "io_group_name"}
+	var (
+		mWriteCache = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_io_group_write_cache_usage_ratio",
+				Help: "Highest ratio of the write cache usage of the nodes of the I/O group",
+			},
+			labels,
+		)
+		mDestageBytes = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_io_group_destage_bps",
+				Help: "Current bytes-per-second written from the cache of the I/O group to the back-end",
+			},
+			labels,
+		)
+		mDestageIO = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_io_group_destage_iops",
+				Help: "Current I/O-per-second written from the cache of the I/O group to the back-end",
+			},
+			labels,
+		)
+	)
+
+	registry.MustRegister(mWriteCache)
+	registry.MustRegister(mDestageBytes)
+	registry.MustRegister(mDestageIO)
+
+	type node struct {
+		ID          string
+		IOGroupID   string `json:"IO_group_id"`
+		IOGroupName string `json:"IO_group_name"`
+	}
+	var n node
+	nodes := map[string]node{}
+	if err := c.GetEach("rest/lsnodecanister", "", &n, func() { nodes[n.ID] = n }); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	type nodeStat struct {
+		NodeID      string `json:"node_id"`
+		StatName    string `json:"stat_name"`
+		StatCurrent int    `json:"stat_current,string"`
+	}
+	var st []nodeStat
+
+	if err := c.Get("rest/lsnodecanisterstats", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	// The write cache is mirrored between the nodes of an I/O group, the
+	// fuller one is what throttles the hosts. The back-end writes of the
+	// nodes are the destaged data and add up.
+	writeCache := map[node]int{}
+	for _, s := range st {
+		n, ok := nodes[s.NodeID]
+		if !ok {
+			continue
+		}
+		// Key on the I/O group only
+		n.ID = ""
+		switch s.StatName {
+		case "write_cache_pc":
+			if pc, ok := writeCache[n]; !ok || s.StatCurrent > pc {
+				writeCache[n] = s.StatCurrent
+			}
+		case "mdisk_w_mb":
+			mDestageBytes.WithLabelValues(n.IOGroupID, n.IOGroupName).Add(opts.mbToBytes(s.StatCurrent))
+		case "mdisk_w_io":
+			mDestageIO.WithLabelValues(n.IOGroupID, n.IOGroupName).Add(float64(s.StatCurrent))
+		}
+	}
+	for n, pc := range writeCache {
+		mWriteCache.WithLabelValues(n.IOGroupID, n.IOGroupName).Set(float64(pc) / 100.0)
+	}
+	return true
+}
+
+func probeSystemStats(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	mLatency := newGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "spectrum_system_latency_seconds",
+			Help: "Current average latency of the system by layer, vdisk (front-end), mdisk (back-end) or drive",
+		},
+		[]string{"layer", "op"},
+	)
+
+	registry.MustRegister(mLatency)
+
+	type systemStat struct {
+		StatName    string `json:"stat_name"`
+		StatCurrent int    `json:"stat_current,string"`
+	}
+	var st []systemStat
+
+	if err := c.Get("rest/lssystemstats", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		if m := latencyStatRE.FindStringSubmatch(s.StatName); m != nil {
+			mLatency.WithLabelValues(m[1], latencyOps[m[2]]).Set(float64(s.StatCurrent) / 1000.0)
+		}
+	}
+	return true
+}
+
+func probeEnclosureStats(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mPower = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_power_watts",
+				Help: "Current power draw of enclosure in watts",
+			},
+			[]string{"enclosure"},
+		)
+		mTemp = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_temperature",
+				Help: "Current enclosure temperature in celsius",
+			},
+			[]string{"enclosure"},
+		)
+	)
+
+	registry.MustRegister(mPower)
+	registry.MustRegister(mTemp)
+
+	type enclosureStats struct {
+		EnclosureID string `json:"enclosure_id"`
+		StatName    string `json:"stat_name"`
+		StatCurrent int    `json:"stat_current,string"`
+	}
+	var st []enclosureStats
+
+	if err := c.Get("rest/lsenclosurestats", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		if s.StatName == "power_w" {
+			mPower.WithLabelValues(s.EnclosureID).Set(float64(s.StatCurrent))
+		} else if s.StatName == "temp_c" {
+			mTemp.WithLabelValues(s.EnclosureID).Set(float64(s.StatCurrent))
+		}
+	}
+	return true
+}
+
+func probeDrives(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"enclosure", "slot_id", "id"}
+	var (
+		mStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_drive_status",
+				Help: "Status of drive",
+			},
+			append(labels, "status"),
+		)
+	)
+
+	registry.MustRegister(mStatus)
+
+	type drive struct {
+		ID          string
+		Status      string
+		Use         string
+		Capacity    string
+		SlotID      string `json:"slot_id"`
+		MdiskID     string `json:"mdisk_id"`
+		MdiskName   string `json:"mdisk_name"`
+		EnclosureID string `json:"enclosure_id"`
+	}
+	var st []drive
+
+	if err := c.Get("rest/lsdrive", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		setOneHot(mStatus, "drive", "status", driveStatuses, s.Status, s.EnclosureID, s.SlotID, s.ID)
+	}
+	return true
+}
+
+func probeEnclosurePSUs(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"enclosure", "id"}
+	var (
+		mStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_psu_status",
+				Help: "Status of PSU",
+			},
+			append(labels, "status"),
+		)
+		mInput = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_psu_input_power",
+				Help: "Type of input power of PSU, failed if the PSU has no input power",
+			},
+			append(labels, "input"),
+		)
+	)
+
+	registry.MustRegister(mStatus)
+	registry.MustRegister(mInput)
+
+	type psu struct {
+		Status      string
+		PSUID       string `json:"psu_id"`
+		EnclosureID string `json:"enclosure_id"`
+		InputPower  string `json:"input_power"`
+	}
+	var st []psu
+
+	if err := c.Get("rest/lsenclosurepsu", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		setOneHot(mStatus, "enclosure_psu", "status", psuStatuses, s.Status, s.EnclosureID, s.PSUID)
+		setOneHot(mInput, "enclosure_psu", "input_power", psuInputs, s.InputPower, s.EnclosureID, s.PSUID)
+	}
+	return true
+}
+
+func probeEnclosureFans(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_fan_module_status",
+				Help: "Status of enclosure fan module",
+			},
+			[]string{"enclosure", "id", "status"},
+		)
+	)
+
+	registry.MustRegister(mStatus)
+
+	type fanModule struct {
+		Status      string
+		FanModuleID string `json:"fan_module_id"`
+		EnclosureID string `json:"enclosure_id"`
+	}
+	var st []fanModule
+
+	if err := c.Get("rest/lsenclosurefanmodule", "", &st); err != nil {
+		if client.IsUnsupported(err) {
+			// Only systems with separate fan modules have the command
+			return true
+		}
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		setOneHot(mStatus, "enclosure_fan", "status", fanModuleStatuses, s.Status, s.EnclosureID, s.FanModuleID)
+	}
+	return true
+}
+
+func probeEnclosureSlots(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mSlots = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_enclosure_drive_slots",
+				Help: "Number of drive slots of enclosure",
+			},
+			[]string{"enclosure"},
+		)
+		mPopulated = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_enclosure_drive_slots_populated",
+				Help: "Number of drive slots of enclosure with a drive present",
+			},
+			[]string{"enclosure"},
+		)
+	)
+
+	registry.MustRegister(mSlots)
+	registry.MustRegister(mPopulated)
+
+	type slot struct {
+		EnclosureID  string `json:"enclosure_id"`
+		DrivePresent string `json:"drive_present"`
+	}
+	var s slot
+	err := c.GetEach("rest/lsenclosureslot", "", &s, func() {
+		mSlots.WithLabelValues(s.EnclosureID).Inc()
+		// Export empty enclosures too
+		mPopulated.WithLabelValues(s.EnclosureID).Add(0)
+		if s.DrivePresent == "yes" {
+			mPopulated.WithLabelValues(s.EnclosureID).Inc()
+		}
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	return true
+}
+
+func probePool(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	// The site is empty unless the system has a stretched or HyperSwap topology
+	labels := []string{"id", "name", "site_id", "site_name"}
+	var (
+		mStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_pool_status",
+				Help: "Status of pool",
+			},
+			append(labels, "status"),
+		)
+		mVdiskCount = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_pool_volume_count", Help: "Number of volumes associated with pool"}, labels)
+		mCapacity   = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_pool_capacity_bytes", Help: "Capacity of pool in bytes"}, labels)
+		mFree       = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_pool_free_bytes", Help: "Free bytes in pool"}, labels)
+		mUsed       = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_pool_used_bytes", Help: "Used bytes in pool"}, labels)
+		// Only reported by firmware supporting snapshots, from 8.5.2
+		mSnapshot = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_pool_snapshot_written_bytes", Help: "Capacity in pool written to by the snapshots of its volumes"}, labels)
+		mEasyTier = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_pool_easy_tier_mode",
+				Help: "Configured Easy Tier mode of pool",
+			},
+			append(labels, "mode"),
+		)
+		mEasyTierStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_pool_easy_tier_status",
+				Help: "Easy Tier status of pool",
+			},
+			append(labels, "status"),
+		)
+	)
+
+	registry.MustRegister(mStatus)
+	registry.MustRegister(mVdiskCount)
+	registry.MustRegister(mCapacity)
+	registry.MustRegister(mFree)
+	registry.MustRegister(mUsed)
+	registry.MustRegister(mSnapshot)
+	registry.MustRegister(mEasyTier)
+	registry.MustRegister(mEasyTierStatus)
+
+	type pool struct {
+		ID                  string
+		Status              string
+		Name                string
+		VdiskCount          int `json:"vdisk_count,string"`
+		Capacity            string
+		FreeCapacity        string `json:"free_capacity"`
+		VirtualCapacity     string `json:"virtual_capacity"`
+		UsedCapacity        string `json:"used_capacity"`
+		RealCapacity        string `json:"real_capacity"`
+		ReclaimableCapacity string `json:"reclaimable_capacity"`
+		SnapshotWritten     string `json:"snapshot_written_capacity"`
+		EasyTier            string `json:"easy_tier"`
+		EasyTierStatus      string `json:"easy_tier_status"`
+		SiteID              string `json:"site_id"`
+		SiteName            string `json:"site_name"`
+	}
+	var st []pool
+
+	if err := c.Get("rest/lsmdiskgrp", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		if !opts.filter("pool").Match(s.Name) {
+			continue
+		}
+		setOneHot(mStatus, "pool", "status", poolStatuses, s.Status, s.ID, s.Name, s.SiteID, s.SiteName)
+
+		mVdiskCount.WithLabelValues(s.ID, s.Name, s.SiteID, s.SiteName).Set(float64(s.VdiskCount))
+
+		setOneHot(mEasyTier, "pool", "easy_tier", easyTierModes, s.EasyTier, s.ID, s.Name, s.SiteID, s.SiteName)
+		setOneHot(mEasyTierStatus, "pool", "easy_tier_status", easyTierStatuses, s.EasyTierStatus, s.ID, s.Name, s.SiteID, s.SiteName)
+
+		free, err := parseCapacity(s.FreeCapacity)
+		if err != nil {
+			logParseError("pool", "free_capacity", s.FreeCapacity, err)
+		} else {
+			mFree.WithLabelValues(s.ID, s.Name, s.SiteID, s.SiteName).Set(float64(free))
+		}
+
+		capacity, err := parseCapacity(s.Capacity)
+		if err != nil {
+			logParseError("pool", "capacity", s.Capacity, err)
+		} else {
+			mCapacity.WithLabelValues(s.ID, s.Name, s.SiteID, s.SiteName).Set(float64(capacity))
+		}
+
+		used, err := parseCapacity(s.UsedCapacity)
+		if err != nil {
+			logParseError("pool", "used_capacity", s.UsedCapacity, err)
+		} else {
+			mUsed.WithLabelValues(s.ID, s.Name, s.SiteID, s.SiteName).Set(float64(used))
+		}
+
+		if s.SnapshotWritten == "" {
+			continue
+		}
+		snapshot, err := parseCapacity(s.SnapshotWritten)
+		if err != nil {
+			logParseError("pool", "snapshot_written_capacity", s.SnapshotWritten, err)
+		} else {
+			mSnapshot.WithLabelValues(s.ID, s.Name, s.SiteID, s.SiteName).Set(float64(snapshot))
+		}
+	}
+	return true
+}
+
+func probeHost(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"id", "name"}
+	var (
+		mStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_host_status",
+				Help: "Status of host, degraded if some of its ports are not logged in",
+			},
+			append(labels, "status"),
+		)
+		mPorts = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_host_port_count",
+				Help: "Number of ports defined for host",
+			},
+			labels,
+		)
+		mIOGroups = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_host_iogrp_count",
+				Help: "Number of I/O groups host can access volumes through",
+			},
+			labels,
+		)
+	)
+
+	registry.MustRegister(mStatus)
+	registry.MustRegister(mPorts)
+	registry.MustRegister(mIOGroups)
+
+	type host struct {
+		ID         string
+		Name       string
+		Status     string
+		PortCount  string `json:"port_count"`
+		IOGrpCount string `json:"iogrp_count"`
+	}
+	var h host
+	err := c.GetEach("rest/lshost", "", &h, func() {
+		if !opts.filter("host").Match(h.Name) {
+			return
+		}
+		setOneHot(mStatus, "host", "status", hostStatuses, h.Status, h.ID, h.Name)
+		if n, err := strconv.Atoi(h.PortCount); err != nil {
+			logParseError("host", "port_count", h.PortCount, err)
+		} else {
+			mPorts.WithLabelValues(h.ID, h.Name).Set(float64(n))
+		}
+		if n, err := strconv.Atoi(h.IOGrpCount); err != nil {
+			logParseError("host", "iogrp_count", h.IOGrpCount, err)
+		} else {
+			mIOGroups.WithLabelValues(h.ID, h.Name).Set(float64(n))
+		}
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	return true
+}
+
+func probeHostMappings(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"host_id", "host_name"}
+	var (
+		mVolumes = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_host_mapped_volumes",
+				Help: "Number of volumes mapped to host",
+			},
+			labels,
+		)
+		mInfo = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_host_volume_mapping_info",
+				Help: "Mapping of volume to host",
+			},
+			append(labels, "volume_id", "volume_name", "scsi_id"),
+		)
+	)
+
+	registry.MustRegister(mVolumes)
+	registry.MustRegister(mInfo)
+
+	// Hosts without any mapping are not in lshostvdiskmap, yet they are
+	// the ones to alert on
+	type host struct {
+		ID   string
+		Name string
+	}
+	var h host
+	err := c.GetEach("rest/lshost", "", &h, func() {
+		if !opts.filter("host_mapping").Match(h.Name) {
+			return
+		}
+		mVolumes.WithLabelValues(h.ID, h.Name).Set(0)
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	type mapping struct {
+		ID        string
+		Name      string
+		SCSIID    string `json:"SCSI_id"`
+		VDiskID   string `json:"vdisk_id"`
+		VDiskName string `json:"vdisk_name"`
+	}
+	var m mapping
+	err = c.GetEach("rest/lshostvdiskmap", "", &m, func() {
+		if !opts.filter("host_mapping").Match(m.Name) {
+			return
+		}
+		mVolumes.WithLabelValues(m.ID, m.Name).Inc()
+		mInfo.WithLabelValues(m.ID, m.Name, m.VDiskID, m.VDiskName, m.SCSIID).Set(1)
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	return true
+}
+
+func probeFCPorts(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"node_id", "node_name", "adapter_location", "adapter_port_id"}
+	var (
+		mStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_fc_port_status",
+				Help: "Status of Fibre Channel port",
+			},
+			append(labels, "wwpn", "status"),
+		)
+		mSpeed = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_fc_port_speed_bps",
+				Help: "Operational speed of port in bits per second",
+			},
+			labels,
+		)
+		mConfiguredSpeed = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_fc_port_configured_speed_bps",
+				Help: "Configured speed of port in bits per second, where fixed rather than auto-negotiated",
+			},
+			labels,
+		)
+		mAutoNegotiated = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_fc_port_speed_autonegotiated",
+				Help: "Whether the speed of the port is auto-negotiated, where reported by the firmware",
+			},
+			labels,
+		)
+		mAttachment = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_fc_port_attachment",
+				Help: "What the Fibre Channel port is attached to, a switch or directly to another port",
+			},
+			append(labels, "wwpn", "attachment"),
+		)
+		mTopology = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_fc_port_topology_info",
+				Help: "Fibre Channel topology of the port, where reported by the firmware",
+			},
+			append(labels, "wwpn", "topology"),
+		)
+	)
+
+	registry.MustRegister(mStatus)
+	registry.MustRegister(mSpeed)
+	registry.MustRegister(mConfiguredSpeed)
+	registry.MustRegister(mAutoNegotiated)
+	registry.MustRegister(mAttachment)
+	registry.MustRegister(mTopology)
+
+	type fcPort struct {
+		Type            string
+		PortSpeed       string `json:"port_speed"`
+		ConfiguredSpeed string `json:"configured_port_speed"`
+		Status          string
+		Attachment      string
+		Topology        string
+		WWPN            string
+		NodeID          string `json:"node_id"`
+		NodeName        string `json:"node_name"`
+		AdapterLocation string `json:"adapter_location"`
+		AdapterPortIID  string `json:"adapter_port_id"`
+	}
+	var st []fcPort
+
+	if err := c.Get("rest/lsportfc", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		name := opts.nodeName(c, s.NodeID, s.NodeName)
+		setOneHot(mStatus, "fc_port", "status", fcPortStatuses, s.Status, s.NodeID, name, s.AdapterLocation, s.AdapterPortIID, s.WWPN)
+		setOneHot(mAttachment, "fc_port", "attachment", fcPortAttachments, s.Attachment, s.NodeID, name, s.AdapterLocation, s.AdapterPortIID, s.WWPN)
+		if s.Topology != "" {
+			mTopology.WithLabelValues(s.NodeID, name, s.AdapterLocation, s.AdapterPortIID, s.WWPN, s.Topology).Set(1)
+		}
+
+		ps, auto, err := parseLinkSpeed(s.PortSpeed)
+		if err != nil {
+			logParseError("fc_port", "port_speed", s.PortSpeed, err)
+		}
+		mSpeed.WithLabelValues(s.NodeID, name, s.AdapterLocation, s.AdapterPortIID).Set(float64(ps))
+
+		// A configured speed differing from the negotiated one points at
+		// an SFP or cable that cannot do the configured speed
+		if s.ConfiguredSpeed != "" {
+			cs, cauto, err := parseLinkSpeed(s.ConfiguredSpeed)
+			if err != nil {
+				logParseError("fc_port", "configured_port_speed", s.ConfiguredSpeed, err)
+			} else if !cauto {
+				mConfiguredSpeed.WithLabelValues(s.NodeID, name, s.AdapterLocation, s.AdapterPortIID).Set(float64(cs))
+			}
+			auto = auto || cauto
+		}
+		if s.ConfiguredSpeed != "" || auto {
+			v := 0.0
+			if auto {
+				v = 1
+			}
+			mAutoNegotiated.WithLabelValues(s.NodeID, name, s.AdapterLocation, s.AdapterPortIID).Set(v)
+		}
+	}
+	return true
+}
+
+func probeIPPorts(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"node_id", "node_name", "adapter_location", "adapter_port_id"}
+	var (
+		mState = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_ip_port_state",
+				Help: "Configuration state of Ethernet/IP port",
+			},
+			append(labels, "mac", "state"),
+		)
+		mActive = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_ip_port_link_active",
+				Help: "Whether link is active",
+			},
+			append(labels, "mac"),
+		)
+		mSpeed = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_ip_port_speed_bps",
+				Help: "Operational speed of port in bits per second",
+			},
+			labels,
+		)
+		mDuplex = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_ip_port_duplex",
+				Help: "Negotiated duplex mode of Ethernet/IP port with a link",
+			},
+			append(labels, "mac", "duplex"),
+		)
+		mMTU = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_ip_port_mtu_bytes",
+				Help: "Configured MTU of Ethernet/IP port, where reported by the firmware",
+			},
+			append(labels, "mac"),
+		)
+	)
+
+	registry.MustRegister(mState)
+	registry.MustRegister(mActive)
+	registry.MustRegister(mSpeed)
+	registry.MustRegister(mDuplex)
+	registry.MustRegister(mMTU)
+
+	type ipPort struct {
+		Speed           string
+		Duplex          string
+		MTU             string
+		State           string
+		LinkState       string `json:"link_state"`
+		MAC             string
+		NodeID          string `json:"node_id"`
+		NodeName        string `json:"node_name"`
+		AdapterLocation string `json:"adapter_location"`
+		AdapterPortIID  string `json:"adapter_port_id"`
+	}
+	var st []ipPort
+
+	if err := c.Get("rest/lsportip", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		name := opts.nodeName(c, s.NodeID, s.NodeName)
+		setOneHot(mState, "ip_port", "state", ipPortStates, s.State, s.NodeID, name, s.AdapterLocation, s.AdapterPortIID, s.MAC)
+
+		active := 0
+		if s.LinkState == "active" {
+			active = 1
+		}
+		mActive.WithLabelValues(s.NodeID, name, s.AdapterLocation, s.AdapterPortIID, s.MAC).Set(float64(active))
+
+		ps, _, err := parseLinkSpeed(s.Speed)
+		if err != nil {
+			logParseError("ip_port", "speed", s.Speed, err)
+		}
+		mSpeed.WithLabelValues(s.NodeID, name, s.AdapterLocation, s.AdapterPortIID).Set(float64(ps))
+
+		// Ports without a link report no duplex
+		if s.Duplex != "" {
+			setOneHot(mDuplex, "ip_port", "duplex", ipPortDuplexes, strings.ToLower(s.Duplex), s.NodeID, name, s.AdapterLocation, s.AdapterPortIID, s.MAC)
+		}
+		if s.MTU != "" {
+			mtu, err := strconv.Atoi(s.MTU)
+			if err != nil {
+				logParseError("ip_port", "mtu", s.MTU, err)
+			} else {
+				mMTU.WithLabelValues(s.NodeID, name, s.AdapterLocation, s.AdapterPortIID, s.MAC).Set(float64(mtu))
+			}
+		}
+	}
+	return true
+}
+
+// objectLimits holds the per-system maximum object counts for a product
+// family, as documented in the IBM Spectrum Virtualize configuration limits.
+type objectLimits struct {
+	Volumes                 int
+	Hosts                   int
+	HostMappings            int
+	FlashCopyMappings       int
+	RemoteCopyRelationships int
+}
+
+var (
+	// SAN Volume Controller, Storwize V7000 and FlashSystem 7000/9000 class
+	defaultObjectLimits = objectLimits{
+		Volumes:                 10000,
+		Hosts:                   2048,
+		HostMappings:            20000,
+		FlashCopyMappings:       10000,
+		RemoteCopyRelationships: 10000,
+	}
+	// Storwize V5000 and FlashSystem 5000 class
+	midrangeObjectLimits = objectLimits{
+		Volumes:                 8192,
+		Hosts:                   1024,
+		HostMappings:            16384,
+		FlashCopyMappings:       4096,
+		RemoteCopyRelationships: 2048,
+	}
+	// Storwize V3700, V5010 and FlashSystem 5010 class
+	entryObjectLimits = objectLimits{
+		Volumes:                 2048,
+		Hosts:                   512,
+		HostMappings:            4096,
+		FlashCopyMappings:       2048,
+		RemoteCopyRelationships: 1024,
+	}
+)
+
+func objectLimitsFor(product string) objectLimits {
+	p := strings.ToUpper(product)
+	for _, entry := range []string{"V3500", "V3700", "V5010", "FLASHSYSTEM 5010", "FLASHSYSTEM 5015"} {
+		if strings.Contains(p, entry) {
+			return entryObjectLimits
+		}
+	}
+	for _, mid := range []string{"V5000", "V5030", "V5100", "FLASHSYSTEM 5030", "FLASHSYSTEM 5035", "FLASHSYSTEM 5100"} {
+		if strings.Contains(p, mid) {
+			return midrangeObjectLimits
+		}
+	}
+	return defaultObjectLimits
+}
+
+func probeObjectLimits(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"object"}
+	var (
+		mCount = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_object_count", Help: "Number of configured objects of the given type"}, labels)
+		mLimit = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_object_limit", Help: "Maximum number of objects of the given type supported by the product"}, labels)
+		mUsage = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_object_usage_ratio", Help: "Ratio of configured objects to the supported maximum"}, labels)
+	)
+
+	registry.MustRegister(mCount)
+	registry.MustRegister(mLimit)
+	registry.MustRegister(mUsage)
+
+	type system struct {
+		ProductName string `json:"product_name"`
+	}
+	var sys system
+
+	if err := c.Get("rest/lssystem", "", &sys); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	limits := objectLimitsFor(sys.ProductName)
+
+	objects := []struct {
+		name  string
+		path  string
+		limit int
+	}{
+		{"volume", "rest/lsvdisk", limits.Volumes},
+		{"host", "rest/lshost", limits.Hosts},
+		{"host_mapping", "rest/lshostvdiskmap", limits.HostMappings},
+		{"flashcopy_mapping", "rest/lsfcmap", limits.FlashCopyMappings},
+		{"remote_copy_relationship", "rest/lsrcrelationship", limits.RemoteCopyRelationships},
+	}
+
+	for _, o := range objects {
+		var obj struct{}
+		count := 0
+		if err := c.GetEach(o.path, "", &obj, func() { count++ }); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		mCount.WithLabelValues(o.name).Set(float64(count))
+		mLimit.WithLabelValues(o.name).Set(float64(o.limit))
+		mUsage.WithLabelValues(o.name).Set(float64(count) / float64(o.limit))
+	}
+	return true
+}
+
+func probeLicense(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"name"}
+	var (
+		mExpiry    = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_feature_trial_expiry_timestamp_seconds", Help: "Time when the trial license of the feature expires"}, labels)
+		mRemaining = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_feature_trial_days_remaining", Help: "Days remaining until the trial license of the feature expires"}, labels)
+	)
+
+	registry.MustRegister(mExpiry)
+	registry.MustRegister(mRemaining)
+
+	type feature struct {
+		ID                  string
+		Name                string
+		State               string
+		TrialExpirationDate string `json:"trial_expiration_date"`
+	}
+	var st []feature
+
+	if err := c.Get("rest/lsfeature", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		if s.TrialExpirationDate == "" {
+			continue
+		}
+		expiry, err := parseSpectrumTime(s.TrialExpirationDate)
+		if err != nil {
+			logParseError("license", "trial_expiration_date", s.TrialExpirationDate, err)
+			continue
+		}
+		mExpiry.WithLabelValues(s.Name).Set(float64(expiry.Unix()))
+		mRemaining.WithLabelValues(s.Name).Set(expiry.Sub(timeNow()).Hours() / 24)
+	}
+	return true
+}
+
+func probeEncryption(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"id", "name"}
+	var (
+		mEnabled   = prometheus.NewGauge(prometheus.GaugeOpts{Name: "spectrum_encryption_enabled", Help: "Whether encryption is enabled on the system"})
+		mUSBKeys   = prometheus.NewGauge(prometheus.GaugeOpts{Name: "spectrum_encryption_usb_keys", Help: "Number of USB flash drives with a valid encryption key detected"})
+		mProviders = prometheus.NewGauge(prometheus.GaugeOpts{Name: "spectrum_encryption_providers_online", Help: "Number of encryption key providers (USB keys and key servers) online"})
+		mStatus    = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_keyserver_status",
+				Help: "Status of key server",
+			},
+			append(labels, "status"),
+		)
+		mCertExpiry = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_keyserver_certificate_expiry_timestamp_seconds", Help: "Time when the key server certificate expires"}, labels)
+	)
+
+	registry.MustRegister(mEnabled)
+	registry.MustRegister(mUSBKeys)
+	registry.MustRegister(mProviders)
+	registry.MustRegister(mStatus)
+	registry.MustRegister(mCertExpiry)
+
+	type encryption struct {
+		Status      string
+		USBKeyCount int `json:"usb_key_count,string"`
+	}
+	var enc encryption
+
+	if err := c.Get("rest/lsencryption", "", &enc); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	type keyServer struct {
+		ID      string
+		Name    string
+		Status  string
+		SSLCert string
+	}
+	var st []keyServer
+
+	if err := c.Get("rest/lskeyserver", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	enabled := 0
+	if enc.Status == "enabled" {
+		enabled = 1
+	}
+	mEnabled.Set(float64(enabled))
+	mUSBKeys.Set(float64(enc.USBKeyCount))
+
+	providers := 0
+	if enc.USBKeyCount > 0 {
+		providers++
+	}
+	for _, s := range st {
+		if s.Status == "online" {
+			providers++
+		}
+		setOneHot(mStatus, "encryption", "status", keyserverStatuses, s.Status, s.ID, s.Name)
+
+		if s.SSLCert != "yes" {
+			continue
+		}
+		// The certificate expiry is only part of the detailed view
+		type keyServerDetails struct {
+			SSLCertExpiry string `json:"sslcert_expiry"`
+		}
+		var d keyServerDetails
+		if err := c.Get("rest/lskeyserver/"+s.ID, "", &d); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		expiry, err := parseSpectrumTime(d.SSLCertExpiry)
+		if err != nil {
+			logParseError("encryption", "sslcert_expiry", d.SSLCertExpiry, err)
+			continue
+		}
+		mCertExpiry.WithLabelValues(s.ID, s.Name).Set(float64(expiry.Unix()))
+	}
+	mProviders.Set(float64(providers))
+	return true
+}
+
+func probeSystemTime(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mTime     = prometheus.NewGauge(prometheus.GaugeOpts{Name: "spectrum_system_time_seconds", Help: "Current time of the system clock in seconds since epoch"})
+		mTimeZone = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_system_timezone_info", Help: "Time zone configured on the system"}, []string{"time_zone"})
+	)
+
+	registry.MustRegister(mTime)
+	registry.MustRegister(mTimeZone)
+
+	type system struct {
+		TimeZone string `json:"time_zone"`
+	}
+	var sys system
+
+	if err := c.Get("rest/lssystem", "", &sys); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	type clock struct {
+		Time string
+	}
+	var clk clock
+
+	if err := c.Get("rest/svqueryclock", "", &clk); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	if clk.Time == "" {
+		log.Printf("Error: svqueryclock returned no time")
+		return false
+	}
+
+	// time_zone is reported as "<id> <name>", e.g. "522 UTC"
+	tz := sys.TimeZone
+	if f := strings.Fields(tz); len(f) == 2 {
+		tz = f[1]
+	}
+	mTimeZone.WithLabelValues(tz).Set(1)
+
+	t, err := parseSpectrumTime(clk.Time)
+	if err != nil {
+		logParseError("system_time", "time", clk.Time, err)
+	} else {
+		mTime.Set(float64(t.Unix()))
+	}
+	return true
+}
+
+func probeNodeInfo(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mInfo = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_info",
+				Help: "Inventory information about the node",
+			},
+			[]string{"node_id", "node_name", "panel_name", "wwnn", "serial_number", "product_mtm", "io_group"},
+		)
+		mStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_status",
+				Help: "Status of node",
+			},
+			[]string{"node_id", "node_name", "status"},
+		)
+	)
+
+	registry.MustRegister(mInfo)
+	registry.MustRegister(mStatus)
+
+	type node struct {
+		ID                    string
+		Name                  string
+		Status                string
+		WWNN                  string
+		PanelName             string `json:"panel_name"`
+		IOGroupName           string `json:"IO_group_name"`
+		EnclosureSerialNumber string `json:"enclosure_serial_number"`
+	}
+	var st []node
+
+	if err := c.Get("rest/lsnodecanister", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		setOneHot(mStatus, "node_info", "status", nodeStatuses, s.Status, s.ID, s.Name)
+
+		// Serial number and machine type/model are only part of the detailed view
+		type nodeDetails struct {
+			ProductMTM   string `json:"product_mtm"`
+			SerialNumber string `json:"serial_number"`
+		}
+		var d nodeDetails
+		if err := c.Get("rest/lsnodecanister/"+s.ID, "", &d); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		serial := d.SerialNumber
+		if serial == "" {
+			serial = s.EnclosureSerialNumber
+		}
+		mInfo.WithLabelValues(s.ID, s.Name, s.PanelName, s.WWNN, serial, d.ProductMTM, s.IOGroupName).Set(1)
+	}
+	return true
+}
+
+func probeDriveFirmware(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mFirmware = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_drive_firmware_info",
+				Help: "Number of drives running the given firmware level",
+			},
+			[]string{"firmware", "tech_type"},
+		)
+	)
+
+	registry.MustRegister(mFirmware)
+
+	type drive struct {
+		ID       string
+		TechType string `json:"tech_type"`
+	}
+	var st []drive
+
+	if err := c.Get("rest/lsdrive", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		// The firmware level is only part of the detailed view
+		type driveDetails struct {
+			FirmwareLevel string `json:"firmware_level"`
+		}
+		var d driveDetails
+		if err := c.Get("rest/lsdrive/"+s.ID, "", &d); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		mFirmware.WithLabelValues(d.FirmwareLevel, s.TechType).Inc()
+	}
+	return true
+}
+
+func probePortStats(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"node_id", "node_name", "port_id"}
+	var (
+		mBufferCreditZero = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_fc_port_buffer_credit_zero_ratio",
+				Help: "Ratio of time the Fibre Channel port had zero buffer-to-buffer credits",
+			},
+			labels,
+		)
+		mBusy = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_fc_port_busy_ratio",
+				Help: "Ratio of time the Fibre Channel port was busy",
+			},
+			labels,
+		)
+	)
+
+	registry.MustRegister(mBufferCreditZero)
+	registry.MustRegister(mBusy)
+
+	type portStat struct {
+		NodeID      string `json:"node_id"`
+		NodeName    string `json:"node_name"`
+		PortID      string `json:"port_id"`
+		StatName    string `json:"stat_name"`
+		StatCurrent int    `json:"stat_current,string"`
+	}
+	var st []portStat
+
+	if err := c.Get("rest/lsportstats", "", &st); err != nil {
+		if client.IsUnsupported(err) {
+			// Not all firmware levels provide per-port statistics
+			return true
+		}
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		name := opts.nodeName(c, s.NodeID, s.NodeName)
+		if s.StatName == "bbcz_pc" {
+			mBufferCreditZero.WithLabelValues(s.NodeID, name, s.PortID).Set(float64(s.StatCurrent) / 100.0)
+		} else if s.StatName == "busy_pc" {
+			mBusy.WithLabelValues(s.NodeID, name, s.PortID).Set(float64(s.StatCurrent) / 100.0)
+		}
+	}
+	return true
+}
+
+// capacityWarningEvent is the event raised by the system when its own
+// capacity thresholds are exceeded, the thin-provisioned volume copy space
+// warning
+const capacityWarningEvent = "060001"
+
+// capacityWarningQuery fetches the unfixed capacity warnings rather than
+// the whole event log
+var capacityWarningQuery = url.Values{"filtervalue": {"fixed=no:event_id=" + capacityWarningEvent}}.Encode()
+
+func probeCapacityWarning(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"id", "name", "site_id", "site_name"}
+	var (
+		mWarning = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_capacity_warning",
+				Help: "Whether the system itself considers a capacity warning threshold exceeded, by source",
+			},
+			[]string{"source"},
+		)
+		mPoolThreshold = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_pool_capacity_warning_threshold_ratio", Help: "Ratio of pool capacity in use at which the system raises a warning, 0 if disabled"}, labels)
+		mPoolWarning   = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_pool_capacity_warning", Help: "Whether the pool capacity in use exceeds the warning threshold of the pool"}, labels)
+	)
+
+	registry.MustRegister(mWarning)
+	registry.MustRegister(mPoolThreshold)
+	registry.MustRegister(mPoolWarning)
+
+	type pool struct {
+		ID           string
+		Name         string
+		Capacity     string
+		FreeCapacity string `json:"free_capacity"`
+		Warning      string
+		SiteID       string `json:"site_id"`
+		SiteName     string `json:"site_name"`
+	}
+	var st []pool
+
+	if err := c.Get("rest/lsmdiskgrp", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	poolWarning := 0
+	for _, s := range st {
+		if !opts.filter("pool").Match(s.Name) {
+			continue
+		}
+		threshold, err := strconv.Atoi(s.Warning)
+		if err != nil {
+			logParseError("capacity_warning", "warning", s.Warning, err)
+			continue
+		}
+		mPoolThreshold.WithLabelValues(s.ID, s.Name, s.SiteID, s.SiteName).Set(float64(threshold) / 100.0)
+		capacity, err := parseCapacity(s.Capacity)
+		if err != nil {
+			logParseError("capacity_warning", "capacity", s.Capacity, err)
+			continue
+		}
+		free, err := parseCapacity(s.FreeCapacity)
+		if err != nil {
+			logParseError("capacity_warning", "free_capacity", s.FreeCapacity, err)
+			continue
+		}
+		exceeded := 0
+		if threshold > 0 && capacity > 0 && float64(capacity-free)/float64(capacity)*100 >= float64(threshold) {
+			exceeded = 1
+			poolWarning = 1
+		}
+		mPoolWarning.WithLabelValues(s.ID, s.Name, s.SiteID, s.SiteName).Set(float64(exceeded))
+	}
+	mWarning.WithLabelValues("pool_threshold").Set(float64(poolWarning))
+
+	type event struct {
+		Fixed   string
+		EventID string `json:"event_id"`
+	}
+	var e event
+	eventWarning := 0
+	// Checked again for the backends that cannot filter
+	err := c.GetEach("rest/lseventlog", capacityWarningQuery, &e, func() {
+		if e.Fixed == "no" && e.EventID == capacityWarningEvent {
+			eventWarning = 1
+		}
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	mWarning.WithLabelValues("event_log").Set(float64(eventWarning))
+	return true
+}
+
+func probePartnerships(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"id", "name", "type"}
+	var (
+		mBandwidth   = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_partnership_link_bandwidth_bps", Help: "Configured bandwidth of the partnership link in bits per second"}, labels)
+		mThroughput  = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_partnership_throughput_bps", Help: "Replication throughput over the IP partnership links in bits per second"}, labels)
+		mUtilization = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_partnership_link_utilization_ratio", Help: "Ratio of the configured link bandwidth used by replication"}, labels)
+	)
+
+	registry.MustRegister(mBandwidth)
+	registry.MustRegister(mThroughput)
+	registry.MustRegister(mUtilization)
+
+	type partnership struct {
+		ID       string
+		Name     string
+		Location string
+		Type     string
+	}
+	var st []partnership
+
+	if err := c.Get("rest/lspartnership", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	type systemStat struct {
+		StatName    string `json:"stat_name"`
+		StatCurrent int    `json:"stat_current,string"`
+	}
+	hasIP := false
+	for _, s := range st {
+		if s.Location != "local" && strings.HasPrefix(s.Type, "ipv") {
+			hasIP = true
+		}
+	}
+	iplink := -1
+	if hasIP {
+		var stats []systemStat
+		if err := c.Get("rest/lssystemstats", "", &stats); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		for _, stat := range stats {
+			if stat.StatName == "iplink_mb" {
+				iplink = stat.StatCurrent
+			}
+		}
+	}
+
+	for _, s := range st {
+		// The system itself is listed as a local partnership
+		if s.Location == "local" {
+			continue
+		}
+		// The link bandwidth is only part of the detailed view
+		type partnershipDetails struct {
+			LinkBandwidthMbits string `json:"link_bandwidth_mbits"`
+		}
+		var d partnershipDetails
+		if err := c.Get("rest/lspartnership/"+s.ID, "", &d); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		mbits, err := strconv.Atoi(d.LinkBandwidthMbits)
+		if err != nil {
+			logParseError("partnership", "link_bandwidth_mbits", d.LinkBandwidthMbits, err)
+			continue
+		}
+		bandwidth := float64(mbits) * 1000 * 1000
+		mBandwidth.WithLabelValues(s.ID, s.Name, s.Type).Set(bandwidth)
+
+		// Only IP partnerships have a throughput statistic. It covers all IP
+		// links, so with several IP partnerships each is attributed the total.
+		if !strings.HasPrefix(s.Type, "ipv") || iplink < 0 {
+			continue
+		}
+		throughput := opts.mbToBytes(iplink) * 8
+		mThroughput.WithLabelValues(s.ID, s.Name, s.Type).Set(throughput)
+		if bandwidth > 0 {
+			mUtilization.WithLabelValues(s.ID, s.Name, s.Type).Set(throughput / bandwidth)
+		}
+	}
+	return true
+}
+
+func probeNetwork(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mIP = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_management_ip_info",
+				Help: "Management IP address configuration of the system, by Ethernet port",
+			},
+			[]string{"port_id", "family", "address", "prefix", "gateway"},
+		)
+		mGateway = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_management_gateway_configured",
+				Help: "Whether a default gateway is configured for the management IP address",
+			},
+			[]string{"port_id", "family"},
+		)
+		mRoute = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_management_route_info",
+				Help: "Routes of the management network of the configuration node",
+			},
+			[]string{"destination", "gateway", "genmask", "interface"},
+		)
+	)
+
+	registry.MustRegister(mIP)
+	registry.MustRegister(mGateway)
+	registry.MustRegister(mRoute)
+
+	type systemIP struct {
+		PortID     string `json:"port_id"`
+		Location   string
+		IPAddress  string `json:"IP_address"`
+		SubnetMask string `json:"subnet_mask"`
+		Gateway    string
+		IPAddress6 string `json:"IP_address_6"`
+		Prefix6    string `json:"prefix_6"`
+		Gateway6   string `json:"gateway_6"`
+	}
+	var st []systemIP
+
+	if err := c.Get("rest/lssystemip", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		// Older firmware also lists the addresses of partner systems
+		if s.Location != "" && s.Location != "local" {
+			continue
+		}
+		for _, a := range []struct{ family, address, prefix, gateway string }{
+			{"ipv4", s.IPAddress, s.SubnetMask, s.Gateway},
+			{"ipv6", s.IPAddress6, s.Prefix6, s.Gateway6},
+		} {
+			if a.address == "" {
+				continue
+			}
+			mIP.WithLabelValues(s.PortID, a.family, a.address, a.prefix, a.gateway).Set(1)
+			configured := 0
+			if a.gateway != "" {
+				configured = 1
+			}
+			mGateway.WithLabelValues(s.PortID, a.family).Set(float64(configured))
+		}
+	}
+
+	type route struct {
+		Destination string
+		Gateway     string
+		Genmask     string
+		Iface       string
+	}
+	var routes []route
+
+	if err := c.Get("rest/lsroute", "", &routes); err != nil {
+		var se *json.SyntaxError
+		var te *json.UnmarshalTypeError
+		if client.IsUnsupported(err) {
+			return true
+		}
+		if errors.As(err, &se) || errors.As(err, &te) {
+			// The routing table is a plain text dump on some firmware levels
+			logParseError("network", "lsroute", "", err)
+			return true
+		}
+		log.Printf("Error: %v", err)
+		return false
+	}
+	for _, r := range routes {
+		mRoute.WithLabelValues(r.Destination, r.Gateway, r.Genmask, r.Iface).Set(1)
+	}
+	return true
+}
+
+func probeThrottles(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"id", "name", "object_type", "object_id", "object_name"}
+	var (
+		mIOPS      = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_throttle_iops_limit", Help: "Configured I/O operations per second limit of the throttle"}, labels)
+		mBandwidth = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_throttle_bandwidth_limit_bytes_per_second", Help: "Configured bandwidth limit of the throttle in bytes per second"}, labels)
+	)
+
+	registry.MustRegister(mIOPS)
+	registry.MustRegister(mBandwidth)
+
+	type throttle struct {
+		ThrottleID       string `json:"throttle_id"`
+		ThrottleName     string `json:"throttle_name"`
+		ObjectID         string `json:"object_id"`
+		ObjectName       string `json:"object_name"`
+		ThrottleType     string `json:"throttle_type"`
+		IOPsLimit        string `json:"IOPs_limit"`
+		BandwidthLimitMB string `json:"bandwidth_limit_MB"`
+	}
+	var st []throttle
+
+	if err := c.Get("rest/lsthrottle", "", &st); err != nil {
+		if client.IsUnsupported(err) {
+			// Throttles were introduced in 7.7
+			return true
+		}
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		labels := []string{s.ThrottleID, s.ThrottleName, s.ThrottleType, s.ObjectID, s.ObjectName}
+		// Throttles limit either or both of IOPS and bandwidth, the other is empty
+		if s.IOPsLimit != "" {
+			iops, err := strconv.Atoi(s.IOPsLimit)
+			if err != nil {
+				logParseError("throttle", "IOPs_limit", s.IOPsLimit, err)
+			} else {
+				mIOPS.WithLabelValues(labels...).Set(float64(iops))
+			}
+		}
+		if s.BandwidthLimitMB != "" {
+			mb, err := strconv.Atoi(s.BandwidthLimitMB)
+			if err != nil {
+				logParseError("throttle", "bandwidth_limit_MB", s.BandwidthLimitMB, err)
+			} else {
+				mBandwidth.WithLabelValues(labels...).Set(opts.mbToBytes(mb))
+			}
+		}
+	}
+	return true
+}
+
+func probeVolumeCopies(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mCopies = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_copies",
+				Help: "Number of volume copies by synchronization state",
+			},
+			[]string{"sync"},
+		)
+		mProgress = prometheus.NewGauge(prometheus.GaugeOpts{Name: "spectrum_volume_copy_sync_progress_min_ratio", Help: "Synchronization progress of the least synchronized volume copy, 1 if all are synchronized"})
+		// Only set while copies are synchronizing
+		mCompletion = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_volume_copy_sync_estimated_completion_timestamp_seconds", Help: "Estimated completion time of the last volume copy synchronization to complete"}, []string{})
+	)
+
+	registry.MustRegister(mCopies)
+	registry.MustRegister(mProgress)
+	registry.MustRegister(mCompletion)
+
+	type vdiskCopy struct {
+		Sync string
+	}
+	var cp vdiskCopy
+	synced, unsynced := 0, 0
+	err := c.GetEach("rest/lsvdiskcopy", "", &cp, func() {
+		if cp.Sync == "yes" {
+			synced++
+		} else {
+			unsynced++
+		}
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	mCopies.WithLabelValues("yes").Set(float64(synced))
+	mCopies.WithLabelValues("no").Set(float64(unsynced))
+
+	type syncProgress struct {
+		Progress                string
+		EstimatedCompletionTime string `json:"estimated_completion_time"`
+	}
+	var sp syncProgress
+	progress := 100
+	var completion time.Time
+	err = c.GetEach("rest/lsvdisksyncprogress", "", &sp, func() {
+		p, err := strconv.Atoi(sp.Progress)
+		if err != nil {
+			logParseError("volume_copy", "progress", sp.Progress, err)
+			return
+		}
+		if p < progress {
+			progress = p
+		}
+		// Synchronized copies have no estimated completion time
+		if sp.EstimatedCompletionTime == "" {
+			return
+		}
+		t, err := parseSpectrumTime(sp.EstimatedCompletionTime)
+		if err != nil {
+			logParseError("volume_copy", "estimated_completion_time", sp.EstimatedCompletionTime, err)
+			return
+		}
+		if t.After(completion) {
+			completion = t
+		}
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	mProgress.Set(float64(progress) / 100.0)
+	if !completion.IsZero() {
+		mCompletion.WithLabelValues().Set(float64(completion.Unix()))
+	}
+	return true
+}
+
+func probeVolumeCopyDetails(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"volume_id", "volume_name", "copy_id"}
+	var (
+		mSync = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_copy_synchronized",
+				Help: "Whether volume copy is synchronized",
+			},
+			labels,
+		)
+		mPrimary = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_copy_primary",
+				Help: "Whether volume copy is the primary copy, the one reads are served from",
+			},
+			labels,
+		)
+		mUsed = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_copy_used_capacity_bytes",
+				Help: "Capacity used by the data of volume copy in bytes",
+			},
+			labels,
+		)
+		mReal = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_copy_real_capacity_bytes",
+				Help: "Capacity allocated to volume copy in bytes, the full capacity unless thin-provisioned or compressed",
+			},
+			labels,
+		)
+		// Only exported for thin-provisioned and compressed copies
+		mAutoexpand = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_copy_autoexpand",
+				Help: "Whether the real capacity of thin-provisioned or compressed volume copy expands automatically",
+			},
+			labels,
+		)
+	)
+
+	registry.MustRegister(mSync)
+	registry.MustRegister(mPrimary)
+	registry.MustRegister(mUsed)
+	registry.MustRegister(mReal)
+	registry.MustRegister(mAutoexpand)
+
+	// The capacity of the thin-provisioned and compressed copies is only
+	// in the detailed view of lsvdiskcopy, which takes a call per copy
+	type seCopy struct {
+		VDiskID      string `json:"vdisk_id"`
+		CopyID       string `json:"copy_id"`
+		UsedCapacity string `json:"used_capacity"`
+		RealCapacity string `json:"real_capacity"`
+		Autoexpand   string
+	}
+	var se seCopy
+	seCopies := map[[2]string]seCopy{}
+	err := c.GetEach("rest/lssevdiskcopy", "", &se, func() {
+		seCopies[[2]string{se.VDiskID, se.CopyID}] = se
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	type vdiskCopy struct {
+		VDiskID   string `json:"vdisk_id"`
+		VDiskName string `json:"vdisk_name"`
+		CopyID    string `json:"copy_id"`
+		Sync      string
+		Primary   string
+		Capacity  string
+	}
+	var cp vdiskCopy
+	err = c.GetEach("rest/lsvdiskcopy", "", &cp, func() {
+		if !opts.filter("volume_copy_detail").Match(cp.VDiskName) {
+			return
+		}
+		l := []string{cp.VDiskID, cp.VDiskName, cp.CopyID}
+		mSync.WithLabelValues(l...).Set(boolValue(cp.Sync == "yes"))
+		mPrimary.WithLabelValues(l...).Set(boolValue(cp.Primary == "yes"))
+		used, real := cp.Capacity, cp.Capacity
+		if se, ok := seCopies[[2]string{cp.VDiskID, cp.CopyID}]; ok {
+			used, real = se.UsedCapacity, se.RealCapacity
+			mAutoexpand.WithLabelValues(l...).Set(boolValue(se.Autoexpand == "on"))
+		}
+		if b, err := parseCapacity(used); err != nil {
+			logParseError("volume_copy_detail", "used_capacity", used, err)
+		} else {
+			mUsed.WithLabelValues(l...).Set(float64(b))
+		}
+		if b, err := parseCapacity(real); err != nil {
+			logParseError("volume_copy_detail", "real_capacity", real, err)
+		} else {
+			mReal.WithLabelValues(l...).Set(float64(b))
+		}
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	return true
+}
+
+// migrationTypes are the kinds of migration reported by lsmigrate, from
+// migratevdisk, migrateexts and migratetoimage. Each is exported even if
+// none is running, so that the count drops to 0 when the last finishes.
+var migrationTypes = []string{"MDisk_Group_Migration", "MDisk_Extents_Migration", "Migrate_to_Image_Mode"}
+
+func probeMigrations(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mProgress = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_migration_progress_ratio",
+				Help: "Progress of a running volume migration",
+			},
+			[]string{"type", "volume_id", "volume_name", "copy_id", "target_pool_id", "target_pool_name"},
+		)
+		mCount = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_migrations",
+				Help: "Number of running volume migrations by type",
+			},
+			[]string{"type"},
+		)
+	)
+
+	registry.MustRegister(mProgress)
+	registry.MustRegister(mCount)
+
+	type migration struct {
+		MigrateType              string `json:"migrate_type"`
+		Progress                 string
+		MigrateSourceVdiskIndex  string `json:"migrate_source_vdisk_index"`
+		MigrateSourceVdiskCopyID string `json:"migrate_source_vdisk_copy_id"`
+		MigrateTargetMdiskGrp    string `json:"migrate_target_mdisk_grp"`
+	}
+	var st []migration
+
+	if err := c.Get("rest/lsmigrate", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	counts := map[string]int{}
+	for _, t := range migrationTypes {
+		counts[t] = 0
+	}
+	for _, s := range st {
+		counts[s.MigrateType]++
+		p, err := strconv.Atoi(s.Progress)
+		if err != nil {
+			logParseError("migration", "progress", s.Progress, err)
+			continue
+		}
+		volume := opts.objectName(c, "volume", s.MigrateSourceVdiskIndex)
+		pool := opts.objectName(c, "pool", s.MigrateTargetMdiskGrp)
+		mProgress.WithLabelValues(s.MigrateType, s.MigrateSourceVdiskIndex, volume, s.MigrateSourceVdiskCopyID, s.MigrateTargetMdiskGrp, pool).Set(float64(p) / 100.0)
+	}
+	for t, n := range counts {
+		mCount.WithLabelValues(t).Set(float64(n))
+	}
+	return true
+}
+
+// keyValues decodes a JSON object into its key-value pairs in order,
+// keeping repeated keys such as those of the adapters in the detailed
+// lsnodehw view
+func keyValues(raw json.RawMessage) ([][2]string, error) {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := d.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, fmt.Errorf("expected object, got %v", t)
+	}
+	var kvs [][2]string
+	for d.More() {
+		k, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return nil, err
+		}
+		s, _ := v.(string)
+		kvs = append(kvs, [2]string{k.(string), s})
+	}
+	return kvs, nil
+}
+
+// nodeAdapter is an adapter slot from the detailed lsnodehw view
+type nodeAdapter struct {
+	Location   string
+	Configured string
+	Actual     string
+	Valid      string
+}
+
+func nodeAdapters(kvs [][2]string) []nodeAdapter {
+	var r []nodeAdapter
+	for _, kv := range kvs {
+		if kv[0] == "adapter_location" {
+			r = append(r, nodeAdapter{Location: kv[1]})
+			continue
+		}
+		if len(r) == 0 {
+			continue
+		}
+		a := &r[len(r)-1]
+		switch kv[0] {
+		case "adapter_configured":
+			a.Configured = kv[1]
+		case "adapter_actual":
+			a.Actual = kv[1]
+		case "adapter_valid":
+			a.Valid = kv[1]
+		}
+	}
+	return r
+}
+
+// poolTier is a storage tier from the detailed lsmdiskgrp view
+type poolTier struct {
+	Tier         string
+	MDiskCount   string
+	Capacity     string
+	FreeCapacity string
+}
+
+func poolTiers(kvs [][2]string) []poolTier {
+	var r []poolTier
+	for _, kv := range kvs {
+		if kv[0] == "tier" {
+			r = append(r, poolTier{Tier: kv[1]})
+			continue
+		}
+		if len(r) == 0 {
+			continue
+		}
+		t := &r[len(r)-1]
+		switch kv[0] {
+		case "tier_mdisk_count":
+			t.MDiskCount = kv[1]
+		case "tier_capacity":
+			t.Capacity = kv[1]
+		case "tier_free_capacity":
+			t.FreeCapacity = kv[1]
+		}
+	}
+	return r
+}
+
+func probeNodeHardware(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"node_id", "node_name", "location"}
+	var (
+		mAccelerator = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_compression_accelerator_valid",
+				Help: "Whether the compression accelerator installed matches the configured one",
+			},
+			labels,
+		)
+		mAdapterInfo = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_adapter_info",
+				Help: "Configured and installed adapter of a node slot, empty if none",
+			},
+			append(labels, "configured", "actual"),
+		)
+		mAdapterValid = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_adapter_valid",
+				Help: "Whether the adapter installed in a node slot matches the configured one",
+			},
+			labels,
+		)
+	)
+
+	registry.MustRegister(mAccelerator)
+	registry.MustRegister(mAdapterInfo)
+	registry.MustRegister(mAdapterValid)
+
+	type node struct {
+		ID   string
+		Name string
+	}
+	var st []node
+
+	// Storwize systems have node canisters rather than nodes
+	cmd := "rest/lsnodehw"
+	err := c.Get(cmd, "", &st)
+	if client.IsUnsupported(err) {
+		cmd = "rest/lsnodecanisterhw"
+		err = c.Get(cmd, "", &st)
+	}
+	if err != nil {
+		if client.IsUnsupported(err) {
+			return true
+		}
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		// The adapters are only part of the detailed view
+		var raw json.RawMessage
+		if err := c.Get(cmd+"/"+s.ID, "", &raw); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		kvs, err := keyValues(raw)
+		if err != nil {
+			logParseError("node_hardware", "lsnodehw", s.ID, err)
+			continue
+		}
+		for _, a := range nodeAdapters(kvs) {
+			valid := 0
+			if a.Valid == "yes" {
+				valid = 1
+			}
+			mAdapterInfo.WithLabelValues(s.ID, s.Name, a.Location, a.Configured, a.Actual).Set(1)
+			mAdapterValid.WithLabelValues(s.ID, s.Name, a.Location).Set(float64(valid))
+
+			// A failed accelerator shifts compression to the CPUs
+			if !strings.Contains(strings.ToLower(a.Configured+a.Actual), "compression") {
+				continue
+			}
+			mAccelerator.WithLabelValues(s.ID, s.Name, a.Location).Set(float64(valid))
+		}
+	}
+	return true
+}
+
+func probeNPIV(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mMode = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_iogrp_fc_target_port_mode",
+				Help: "NPIV target port mode of an I/O group, changing it moves the host paths between the physical and virtual ports",
+			},
+			[]string{"iogrp_id", "iogrp_name", "mode"},
+		)
+		mTargetPorts = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_node_fc_target_ports",
+				Help: "Number of FC target ports owned by a node",
+			},
+			[]string{"node_id", "node_name", "virtualized", "host_io_permitted"},
+		)
+	)
+
+	registry.MustRegister(mMode)
+	registry.MustRegister(mTargetPorts)
+
+	type iogrp struct {
+		ID        string
+		Name      string
+		NodeCount int `json:"node_count,string"`
+	}
+	var st []iogrp
+
+	if err := c.Get("rest/lsiogrp", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		// The recovery I/O group has no nodes and no ports
+		if s.NodeCount == 0 {
+			continue
+		}
+		// The target port mode is only part of the detailed view
+		type iogrpDetails struct {
+			FCTargetPortMode string `json:"fctargetportmode"`
+		}
+		var d iogrpDetails
+		if err := c.Get("rest/lsiogrp/"+s.ID, "", &d); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		// Firmware without NPIV support does not report the mode
+		if d.FCTargetPortMode == "" {
+			continue
+		}
+		setOneHot(mMode, "npiv", "fctargetportmode", npivModes, d.FCTargetPortMode, s.ID, s.Name)
+	}
+
+	type targetPort struct {
+		OwningNodeID    string `json:"owning_node_id"`
+		HostIOPermitted string `json:"host_io_permitted"`
+		Virtualized     string
+	}
+	var ports []targetPort
+
+	if err := c.Get("rest/lstargetportfc", "", &ports); err != nil {
+		if client.IsUnsupported(err) {
+			return true
+		}
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, p := range ports {
+		mTargetPorts.WithLabelValues(p.OwningNodeID, opts.nodeName(c, p.OwningNodeID, ""), p.Virtualized, p.HostIOPermitted).Inc()
+	}
+	return true
+}
+
+func probePoolTiers(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"id", "name", "tier"}
+	var (
+		mCapacity = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_pool_tier_capacity_bytes",
+				Help: "Capacity of a storage tier of the pool",
+			},
+			labels,
+		)
+		mFree = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_pool_tier_free_bytes",
+				Help: "Free capacity of a storage tier of the pool",
+			},
+			labels,
+		)
+		mMDisks = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_pool_tier_mdisks",
+				Help: "Number of MDisks in a storage tier of the pool",
+			},
+			labels,
+		)
+	)
+
+	registry.MustRegister(mCapacity)
+	registry.MustRegister(mFree)
+	registry.MustRegister(mMDisks)
+
+	type pool struct {
+		ID   string
+		Name string
+	}
+	var st []pool
+
+	if err := c.Get("rest/lsmdiskgrp", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		if !opts.filter("pool").Match(s.Name) {
+			continue
+		}
+		// The tiers are only part of the detailed view
+		var raw json.RawMessage
+		if err := c.Get("rest/lsmdiskgrp/"+s.ID, "", &raw); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		kvs, err := keyValues(raw)
+		if err != nil {
+			logParseError("easy_tier", "lsmdiskgrp", s.ID, err)
+			continue
+		}
+		for _, t := range poolTiers(kvs) {
+			// Every pool lists all tiers, most of them empty
+			mdisks, err := strconv.Atoi(t.MDiskCount)
+			if err != nil {
+				logParseError("easy_tier", "tier_mdisk_count", t.MDiskCount, err)
+				continue
+			}
+			if mdisks == 0 {
+				continue
+			}
+			capacity, err := parseCapacity(t.Capacity)
+			if err != nil {
+				logParseError("easy_tier", "tier_capacity", t.Capacity, err)
+				continue
+			}
+			free, err := parseCapacity(t.FreeCapacity)
+			if err != nil {
+				logParseError("easy_tier", "tier_free_capacity", t.FreeCapacity, err)
+				continue
+			}
+			mMDisks.WithLabelValues(s.ID, s.Name, t.Tier).Set(float64(mdisks))
+			mCapacity.WithLabelValues(s.ID, s.Name, t.Tier).Set(float64(capacity))
+			mFree.WithLabelValues(s.ID, s.Name, t.Tier).Set(float64(free))
+		}
+	}
+	return true
+}
+
+func probeHostClusters(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"id", "name"}
+	var (
+		mStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_host_cluster_status",
+				Help: "Status of host cluster",
+			},
+			append(labels, "status"),
+		)
+		mHosts = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_host_cluster_hosts",
+				Help: "Number of hosts that are members of the host cluster",
+			},
+			labels,
+		)
+		mMappings = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_host_cluster_mappings",
+				Help: "Number of volumes mapped to all hosts of the host cluster",
+			},
+			labels,
+		)
+	)
+
+	registry.MustRegister(mStatus)
+	registry.MustRegister(mHosts)
+	registry.MustRegister(mMappings)
+
+	type hostCluster struct {
+		ID           string
+		Name         string
+		Status       string
+		HostCount    int `json:"host_count,string"`
+		MappingCount int `json:"mapping_count,string"`
+	}
+	var st []hostCluster
+
+	if err := c.Get("rest/lshostcluster", "", &st); err != nil {
+		if client.IsUnsupported(err) {
+			// Host clusters were introduced in 7.7.1
+			return true
+		}
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		setOneHot(mStatus, "host_cluster", "status", hostClusterStatuses, s.Status, s.ID, s.Name)
+		mHosts.WithLabelValues(s.ID, s.Name).Set(float64(s.HostCount))
+		mMappings.WithLabelValues(s.ID, s.Name).Set(float64(s.MappingCount))
+	}
+	return true
+}
+
+func probeVASAProvider(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_vasa_provider_status",
+				Help: "Status of the embedded VASA provider serving VMware vVols",
+			},
+			[]string{"status"},
+		)
+	)
+
+	registry.MustRegister(mStatus)
+
+	type vasaProvider struct {
+		Status string
+	}
+	var vp vasaProvider
+
+	if err := c.Get("rest/lsvasaprovider", "", &vp); err != nil {
+		if client.IsUnsupported(err) {
+			// The embedded VASA provider was introduced in 8.5.1
+			return true
+		}
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	if vp.Status == "" {
+		// Not reported by systems without the provider configured
+		return true
+	}
+	setOneHot(mStatus, "vasa_provider", "status", vasaProviderStatuses, vp.Status)
+	return true
+}
+
+// probePing only checks that the REST API answers a logged in client. The
+// login itself is done when the client is created.
+func probePing(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mDuration = prometheus.NewGauge(prometheus.GaugeOpts{Name: "spectrum_api_ping_seconds", Help: "Time taken by the REST API to answer a trivial command"})
+	)
+
+	registry.MustRegister(mDuration)
+
+	type currentUser struct {
+		Name string
+	}
+	var u currentUser
+
+	start := timeNow()
+	if err := c.Get("rest/lscurrentuser", "", &u); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	mDuration.Set(timeNow().Sub(start).Seconds())
+	return true
+}
+
+// configBackupKinds maps the prefixes of the configuration backup files to
+// how they were created
+var configBackupKinds = []struct {
+	prefix string
+	kind   string
+}{
+	{"svc.config.cron.xml", "cron"},
+	{"svc.config.backup.xml", "manual"},
+}
+
+func probeConfigBackup(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mFiles = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_config_backup_files",
+				Help: "Number of configuration backup files on the configuration node",
+			},
+			[]string{"kind"},
+		)
+	)
+
+	registry.MustRegister(mFiles)
+
+	type dump struct {
+		Filename string
+	}
+	var st []dump
+
+	// Without a node lsdumps lists the files of the configuration node,
+	// which is where svcconfig and the daily cron job write the backups
+	if err := c.Get("rest/lsdumps", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	files := map[string]int{}
+	for _, s := range st {
+		for _, k := range configBackupKinds {
+			if strings.HasPrefix(s.Filename, k.prefix) {
+				files[k.kind]++
+				break
+			}
+		}
+	}
+	for _, k := range configBackupKinds {
+		mFiles.WithLabelValues(k.kind).Set(float64(files[k.kind]))
+	}
+	return true
+}
+
+func probeHostPorts(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"host_id", "host_name", "wwpn"}
+	var (
+		mState = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_host_port_login_status",
+				Help: "Login state of a Fibre Channel port of a host",
+			},
+			append(labels, "state"),
+		)
+		mLoggedIn = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_host_port_logged_in_nodes",
+				Help: "Number of nodes the Fibre Channel port of a host is logged in to",
+			},
+			labels,
+		)
+	)
+
+	registry.MustRegister(mState)
+	registry.MustRegister(mLoggedIn)
+
+	type host struct {
+		ID   string
+		Name string
+	}
+	var st []host
+
+	if err := c.Get("rest/lshost", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		if !opts.filter("host_port").Match(s.Name) {
+			continue
+		}
+		// The ports are only part of the detailed view. iSCSI and NVMe
+		// hosts list their ports without a WWPN.
+		type hostDetails struct {
+			Nodes []struct {
+				WWPN              string
+				NodeLoggedInCount int `json:"node_logged_in_count,string"`
+				State             string
+			}
+		}
+		var d hostDetails
+		if err := c.Get("rest/lshost/"+s.ID, "", &d); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		for _, p := range d.Nodes {
+			if p.WWPN == "" {
+				continue
+			}
+			setOneHot(mState, "host_port", "state", hostPortStates, p.State, s.ID, s.Name, p.WWPN)
+			mLoggedIn.WithLabelValues(s.ID, s.Name, p.WWPN).Set(float64(p.NodeLoggedInCount))
+		}
+	}
+	return true
+}
+
+// unmappedVolumeCopies are the copy services an unmapped volume may be
+// part of. FlashCopy targets and remote copy secondaries are unmapped by
+// design and not wasted.
+var unmappedVolumeCopies = []string{"none", "flashcopy", "remote_copy"}
+
+func probeUnmappedVolumes(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"copy"}
+	var (
+		mCount    = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_volumes_unmapped", Help: "Number of volumes not mapped to any host, by the copy service they are part of"}, labels)
+		mCapacity = newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_volumes_unmapped_capacity_bytes", Help: "Total capacity of the volumes not mapped to any host, by the copy service they are part of"}, labels)
+	)
+
+	registry.MustRegister(mCount)
+	registry.MustRegister(mCapacity)
+
+	type mapping struct {
+		VDiskID string `json:"vdisk_id"`
+	}
+	var m mapping
+	mapped := map[string]bool{}
+	if err := c.GetEach("rest/lshostvdiskmap", "", &m, func() { mapped[m.VDiskID] = true }); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	type vdisk struct {
+		ID       string
+		Capacity string
+		FCID     string `json:"FC_id"`
+		RCID     string `json:"RC_id"`
+	}
+	var v vdisk
+	count, capacity := map[string]int{}, map[string]int64{}
+	err := c.GetEach("rest/lsvdisk", "", &v, func() {
+		if mapped[v.ID] {
+			return
+		}
+		// A remote copy secondary may also be the source of a FlashCopy
+		// mapping, it is counted as remote copy
+		kind := "none"
+		if v.RCID != "" {
+			kind = "remote_copy"
+		} else if v.FCID != "" {
+			kind = "flashcopy"
+		}
+		count[kind]++
+		b, err := parseCapacity(v.Capacity)
+		if err != nil {
+			logParseError("unmapped_volume", "capacity", v.Capacity, err)
+			return
+		}
+		capacity[kind] += b
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	for _, kind := range unmappedVolumeCopies {
+		mCount.WithLabelValues(kind).Set(float64(count[kind]))
+		mCapacity.WithLabelValues(kind).Set(float64(capacity[kind]))
+	}
+	return true
+}
+
+// volumePreferredNodeReasons are why a volume is not served by its
+// preferred node
+var volumePreferredNodeReasons = []string{"node_offline", "io_group_mismatch"}
+
+func probeVolumePreferredNodes(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mOff = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volumes_off_preferred_node",
+				Help: "Number of volumes of the caching I/O group not served by their preferred node",
+			},
+			[]string{"io_group_id", "io_group_name", "reason"},
+		)
+	)
+
+	registry.MustRegister(mOff)
+
+	type node struct {
+		ID        string
+		Status    string
+		IOGroupID string `json:"IO_group_id"`
+	}
+	var n node
+	nodes := map[string]node{}
+	if err := c.GetEach("rest/lsnodecanister", "", &n, func() { nodes[n.ID] = n }); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	type vdisk struct {
+		ID          string
+		Name        string
+		IOGroupID   string `json:"IO_group_id"`
+		IOGroupName string `json:"IO_group_name"`
+	}
+	var st []vdisk
+	if err := c.Get("rest/lsvdisk", "", &st); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+
+	for _, s := range st {
+		if !opts.filter("volume_preferred_node").Match(s.Name) {
+			continue
+		}
+		for _, r := range volumePreferredNodeReasons {
+			mOff.WithLabelValues(s.IOGroupID, s.IOGroupName, r).Add(0)
+		}
+		// The preferred node is only part of the detailed view
+		type vdiskDetails struct {
+			PreferredNodeID string `json:"preferred_node_id"`
+		}
+		var d vdiskDetails
+		if err := c.Get("rest/lsvdisk/"+s.ID, "", &d); err != nil {
+			log.Printf("Error: %v", err)
+			return false
+		}
+		if d.PreferredNodeID == "" {
+			continue
+		}
+		// The API does not tell which node currently serves a volume. It
+		// is the partner node in the caching I/O group while the preferred
+		// node is not online, and a preferred node outside of the caching
+		// I/O group is left behind by a volume move.
+		p, ok := nodes[d.PreferredNodeID]
+		switch {
+		case !ok || p.IOGroupID != s.IOGroupID:
+			mOff.WithLabelValues(s.IOGroupID, s.IOGroupName, "io_group_mismatch").Inc()
+		case p.Status != "online":
+			mOff.WithLabelValues(s.IOGroupID, s.IOGroupName, "node_offline").Inc()
+		}
+	}
+	return true
+}
+
+// capacitySavings derives the capacity savings of a volume from its copies
+// on firmware not reporting it
+func capacitySavings(seCopies int, compressedCopies int, deduplicatedCopies int) string {
+	switch {
+	case deduplicatedCopies > 0:
+		return "deduplicated"
+	case compressedCopies > 0:
+		return "compressed"
+	case seCopies > 0:
+		return "thin"
+	default:
+		return "none"
+	}
+}
+
+func probeVolumeProvisioning(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	var (
+		mInfo = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_provisioning_info",
+				Help: "Capacity savings and provisioning policy of volume",
+			},
+			[]string{"id", "name", "capacity_savings", "provisioning_policy"},
+		)
+	)
+
+	registry.MustRegister(mInfo)
+
+	// Provisioning policies are assigned to pools, from 8.5.1
+	type pool struct {
+		ID                     string
+		ProvisioningPolicyName string `json:"provisioning_policy_name"`
+	}
+	var pools []pool
+
+	if err := c.Get("rest/lsmdiskgrp", "", &pools); err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	policies := map[string]string{}
+	for _, p := range pools {
+		policies[p.ID] = p.ProvisioningPolicyName
+	}
+
+	type vdisk struct {
+		ID                     string
+		Name                   string
+		MdiskGrpID             string `json:"mdisk_grp_id"`
+		CapacitySavings        string `json:"capacity_savings"`
+		SECopyCount            int    `json:"se_copy_count,string"`
+		CompressedCopyCount    int    `json:"compressed_copy_count,string"`
+		DeduplicatedCopyCount  int    `json:"deduplicated_copy_count,string"`
+		ProvisioningPolicyName string `json:"provisioning_policy_name"`
+	}
+	var v vdisk
+	err := c.GetEach("rest/lsvdisk", "", &v, func() {
+		if !opts.filter("volume_provisioning").Match(v.Name) {
+			return
+		}
+		savings := v.CapacitySavings
+		if savings == "" {
+			savings = capacitySavings(v.SECopyCount, v.CompressedCopyCount, v.DeduplicatedCopyCount)
+		}
+		// Mirrored volumes in several pools have mdisk_grp_id "many" and
+		// no single policy
+		policy := v.ProvisioningPolicyName
+		if policy == "" {
+			policy = policies[v.MdiskGrpID]
+		}
+		mInfo.WithLabelValues(v.ID, v.Name, savings, policy).Set(1)
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	return true
+}
+
+func probeVolumes(c client.SpectrumHTTP, registry prometheus.Registerer, opts *Options) bool {
+	labels := []string{"id", "name"}
+	var (
+		mCapacity = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_capacity_bytes",
+				Help: "Provisioned capacity of volume in bytes",
+			},
+			labels,
+		)
+		mStatus = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_status",
+				Help: "Status of volume",
+			},
+			append(labels, "status"),
+		)
+		mThin = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_thin_provisioned",
+				Help: "Whether any copy of volume is thin-provisioned",
+			},
+			labels,
+		)
+		mCompressed = newGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "spectrum_volume_compressed",
+				Help: "Whether any copy of volume is compressed",
+			},
+			labels,
+		)
+	)
+
+	registry.MustRegister(mCapacity)
+	registry.MustRegister(mStatus)
+	registry.MustRegister(mThin)
+	registry.MustRegister(mCompressed)
+
+	type vdisk struct {
+		ID                  string
+		Name                string
+		Status              string
+		Capacity            string
+		SECopyCount         int `json:"se_copy_count,string"`
+		CompressedCopyCount int `json:"compressed_copy_count,string"`
+	}
+	var v vdisk
+	err := c.GetEach("rest/lsvdisk", "", &v, func() {
+		if !opts.filter("volume").Match(v.Name) {
+			return
+		}
+		setOneHot(mStatus, "volume", "status", volumeStatuses, v.Status, v.ID, v.Name)
+		thin, compressed := 0.0, 0.0
+		if v.SECopyCount > 0 {
+			thin = 1.0
+		}
+		if v.CompressedCopyCount > 0 {
+			compressed = 1.0
+		}
+		mThin.WithLabelValues(v.ID, v.Name).Set(thin)
+		mCompressed.WithLabelValues(v.ID, v.Name).Set(compressed)
+		b, err := parseCapacity(v.Capacity)
+		if err != nil {
+			logParseError("volume", "capacity", v.Capacity, err)
+			return
+		}
+		mCapacity.WithLabelValues(v.ID, v.Name).Set(float64(b))
+	})
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return false
+	}
+	return true
+}
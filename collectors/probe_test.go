@@ -0,0 +1,2148 @@
+// Tests of spectrum_virtualize_exporter
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+	"github.com/bluecmd/spectrum_virtualize_exporter/config"
+	"github.com/google/go-jsonnet"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeClient struct {
+	data map[string][]byte
+	errs map[string]error
+	// queries holds the last query sent per path
+	queries map[string]string
+}
+
+func (c *fakeClient) fail(path string, err error) {
+	c.errs[path] = err
+}
+
+func (c *fakeClient) prepare(path string, jfile string) {
+	vm := jsonnet.MakeVM()
+	b, err := ioutil.ReadFile(jfile)
+	if err != nil {
+		log.Fatalf("Failed to read jsonnet %q: %v", jfile, err)
+	}
+	output, err := vm.EvaluateSnippet(jfile, string(b))
+	if err != nil {
+		log.Fatalf("Failed to evaluate jsonnet %q: %v", jfile, err)
+	}
+	c.data[path] = []byte(output)
+}
+
+// prepareJSON serves the JSON file as-is, for responses jsonnet cannot
+// express such as objects with repeated keys
+func (c *fakeClient) prepareJSON(path string, jfile string) {
+	b, err := ioutil.ReadFile(jfile)
+	if err != nil {
+		log.Fatalf("Failed to read JSON %q: %v", jfile, err)
+	}
+	c.data[path] = b
+}
+
+func (c *fakeClient) Get(path string, query string, obj interface{}) error {
+	c.queries[path] = query
+	if err, ok := c.errs[path]; ok {
+		return err
+	}
+	d, ok := c.data[path]
+	if !ok {
+		log.Fatalf("Tried to get unprepared URL %q", path)
+	}
+	return json.Unmarshal(d, obj)
+}
+
+func (c *fakeClient) GetEach(path string, query string, obj interface{}, fn func()) error {
+	c.queries[path] = query
+	if err, ok := c.errs[path]; ok {
+		return err
+	}
+	d, ok := c.data[path]
+	if !ok {
+		log.Fatalf("Tried to get unprepared URL %q", path)
+	}
+	return client.DecodeEach(bytes.NewReader(d), obj, fn)
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{data: map[string][]byte{}, errs: map[string]error{}, queries: map[string]string{}}
+}
+
+func TestEnclosureStats(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsenclosurestats", "testdata/lsenclosurestats.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeEnclosureStats(c, r, &Options{}) {
+		t.Errorf("probeEnclosureStats() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_power_watts Current power draw of enclosure in watts
+	# TYPE spectrum_power_watts gauge
+	spectrum_power_watts{enclosure="1"} 427
+	# HELP spectrum_temperature Current enclosure temperature in celsius
+	# TYPE spectrum_temperature gauge
+	spectrum_temperature{enclosure="1"} 26
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+func TestDrive(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsdrive", "testdata/lsdrive.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeDrives(c, r, &Options{}) {
+		t.Errorf("probeDrives() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_drive_status Status of drive
+	# TYPE spectrum_drive_status gauge
+	spectrum_drive_status{enclosure="1",id="0",slot_id="5",status="degraded"} 0
+	spectrum_drive_status{enclosure="1",id="0",slot_id="5",status="other"} 0
+	spectrum_drive_status{enclosure="1",id="0",slot_id="5",status="offline"} 0
+	spectrum_drive_status{enclosure="1",id="0",slot_id="5",status="online"} 1
+	spectrum_drive_status{enclosure="1",id="1",slot_id="1",status="degraded"} 1
+	spectrum_drive_status{enclosure="1",id="1",slot_id="1",status="other"} 0
+	spectrum_drive_status{enclosure="1",id="1",slot_id="1",status="offline"} 0
+	spectrum_drive_status{enclosure="1",id="1",slot_id="1",status="online"} 0
+	spectrum_drive_status{enclosure="1",id="17",slot_id="8",status="degraded"} 0
+	spectrum_drive_status{enclosure="1",id="17",slot_id="8",status="other"} 0
+	spectrum_drive_status{enclosure="1",id="17",slot_id="8",status="offline"} 0
+	spectrum_drive_status{enclosure="1",id="17",slot_id="8",status="online"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestEnclosurePSU(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsenclosurepsu", "testdata/lsenclosurepsu.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeEnclosurePSUs(c, r, &Options{}) {
+		t.Errorf("probeEnclosurePSUs() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_psu_input_power Type of input power of PSU, failed if the PSU has no input power
+	# TYPE spectrum_psu_input_power gauge
+	spectrum_psu_input_power{enclosure="1",id="1",input="ac"} 1
+	spectrum_psu_input_power{enclosure="1",id="1",input="dc"} 0
+	spectrum_psu_input_power{enclosure="1",id="1",input="failed"} 0
+	spectrum_psu_input_power{enclosure="1",id="1",input="other"} 0
+	spectrum_psu_input_power{enclosure="1",id="2",input="ac"} 1
+	spectrum_psu_input_power{enclosure="1",id="2",input="dc"} 0
+	spectrum_psu_input_power{enclosure="1",id="2",input="failed"} 0
+	spectrum_psu_input_power{enclosure="1",id="2",input="other"} 0
+	# HELP spectrum_psu_status Status of PSU
+	# TYPE spectrum_psu_status gauge
+	spectrum_psu_status{enclosure="1",id="1",status="degraded"} 0
+	spectrum_psu_status{enclosure="1",id="1",status="other"} 0
+	spectrum_psu_status{enclosure="1",id="1",status="offline"} 0
+	spectrum_psu_status{enclosure="1",id="1",status="online"} 1
+	spectrum_psu_status{enclosure="1",id="2",status="degraded"} 0
+	spectrum_psu_status{enclosure="1",id="2",status="other"} 0
+	spectrum_psu_status{enclosure="1",id="2",status="offline"} 0
+	spectrum_psu_status{enclosure="1",id="2",status="online"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestEnclosureFans(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsenclosurefanmodule", "testdata/lsenclosurefanmodule.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeEnclosureFans(c, r, &Options{}) {
+		t.Errorf("probeEnclosureFans() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_fan_module_status Status of enclosure fan module
+	# TYPE spectrum_fan_module_status gauge
+	spectrum_fan_module_status{enclosure="1",id="1",status="degraded"} 0
+	spectrum_fan_module_status{enclosure="1",id="1",status="offline"} 0
+	spectrum_fan_module_status{enclosure="1",id="1",status="online"} 1
+	spectrum_fan_module_status{enclosure="1",id="1",status="other"} 0
+	spectrum_fan_module_status{enclosure="1",id="2",status="degraded"} 0
+	spectrum_fan_module_status{enclosure="1",id="2",status="offline"} 1
+	spectrum_fan_module_status{enclosure="1",id="2",status="online"} 0
+	spectrum_fan_module_status{enclosure="1",id="2",status="other"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestEnclosureSlots(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsenclosureslot", "testdata/lsenclosureslot.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeEnclosureSlots(c, r, &Options{}) {
+		t.Errorf("probeEnclosureSlots() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_enclosure_drive_slots Number of drive slots of enclosure
+	# TYPE spectrum_enclosure_drive_slots gauge
+	spectrum_enclosure_drive_slots{enclosure="1"} 24
+	spectrum_enclosure_drive_slots{enclosure="2"} 12
+	# HELP spectrum_enclosure_drive_slots_populated Number of drive slots of enclosure with a drive present
+	# TYPE spectrum_enclosure_drive_slots_populated gauge
+	spectrum_enclosure_drive_slots_populated{enclosure="1"} 20
+	spectrum_enclosure_drive_slots_populated{enclosure="2"} 4
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestHostClusters(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lshostcluster", "testdata/lshostcluster.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeHostClusters(c, r, &Options{}) {
+		t.Errorf("probeHostClusters() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_host_cluster_hosts Number of hosts that are members of the host cluster
+	# TYPE spectrum_host_cluster_hosts gauge
+	spectrum_host_cluster_hosts{id="0",name="esx-prod"} 4
+	spectrum_host_cluster_hosts{id="1",name="esx-test"} 2
+	# HELP spectrum_host_cluster_mappings Number of volumes mapped to all hosts of the host cluster
+	# TYPE spectrum_host_cluster_mappings gauge
+	spectrum_host_cluster_mappings{id="0",name="esx-prod"} 12
+	spectrum_host_cluster_mappings{id="1",name="esx-test"} 3
+	# HELP spectrum_host_cluster_status Status of host cluster
+	# TYPE spectrum_host_cluster_status gauge
+	spectrum_host_cluster_status{id="0",name="esx-prod",status="host_cluster_degraded"} 0
+	spectrum_host_cluster_status{id="0",name="esx-prod",status="host_degraded"} 0
+	spectrum_host_cluster_status{id="0",name="esx-prod",status="offline"} 0
+	spectrum_host_cluster_status{id="0",name="esx-prod",status="online"} 1
+	spectrum_host_cluster_status{id="0",name="esx-prod",status="other"} 0
+	spectrum_host_cluster_status{id="1",name="esx-test",status="host_cluster_degraded"} 0
+	spectrum_host_cluster_status{id="1",name="esx-test",status="host_degraded"} 1
+	spectrum_host_cluster_status{id="1",name="esx-test",status="offline"} 0
+	spectrum_host_cluster_status{id="1",name="esx-test",status="online"} 0
+	spectrum_host_cluster_status{id="1",name="esx-test",status="other"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestVASAProvider(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsvasaprovider", "testdata/lsvasaprovider.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeVASAProvider(c, r, &Options{}) {
+		t.Errorf("probeVASAProvider() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_vasa_provider_status Status of the embedded VASA provider serving VMware vVols
+	# TYPE spectrum_vasa_provider_status gauge
+	spectrum_vasa_provider_status{status="disabled"} 0
+	spectrum_vasa_provider_status{status="offline"} 1
+	spectrum_vasa_provider_status{status="online"} 0
+	spectrum_vasa_provider_status{status="other"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time {
+		now = now.Add(250 * time.Millisecond)
+		return now
+	}
+	defer func() { timeNow = time.Now }()
+
+	c := newFakeClient()
+	c.prepare("rest/lscurrentuser", "testdata/lscurrentuser.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probePing(c, r, &Options{}) {
+		t.Errorf("probePing() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_api_ping_seconds Time taken by the REST API to answer a trivial command
+	# TYPE spectrum_api_ping_seconds gauge
+	spectrum_api_ping_seconds 0.25
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestConfigBackup(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsdumps", "testdata/lsdumps.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeConfigBackup(c, r, &Options{}) {
+		t.Errorf("probeConfigBackup() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_config_backup_files Number of configuration backup files on the configuration node
+	# TYPE spectrum_config_backup_files gauge
+	spectrum_config_backup_files{kind="cron"} 1
+	spectrum_config_backup_files{kind="manual"} 2
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestHost(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lshost", "testdata/lshost.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeHost(c, r, &Options{}) {
+		t.Errorf("probeHost() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_host_iogrp_count Number of I/O groups host can access volumes through
+	# TYPE spectrum_host_iogrp_count gauge
+	spectrum_host_iogrp_count{id="2",name="zzzzzzzzzzzz"} 4
+	spectrum_host_iogrp_count{id="3",name="BCVM1"} 4
+	# HELP spectrum_host_port_count Number of ports defined for host
+	# TYPE spectrum_host_port_count gauge
+	spectrum_host_port_count{id="2",name="zzzzzzzzzzzz"} 1
+	spectrum_host_port_count{id="3",name="BCVM1"} 3
+	# HELP spectrum_host_status Status of host, degraded if some of its ports are not logged in
+	# TYPE spectrum_host_status gauge
+	spectrum_host_status{id="2",name="zzzzzzzzzzzz",status="degraded"} 0
+	spectrum_host_status{id="2",name="zzzzzzzzzzzz",status="offline"} 0
+	spectrum_host_status{id="2",name="zzzzzzzzzzzz",status="online"} 1
+	spectrum_host_status{id="2",name="zzzzzzzzzzzz",status="other"} 0
+	spectrum_host_status{id="3",name="BCVM1",status="degraded"} 1
+	spectrum_host_status{id="3",name="BCVM1",status="offline"} 0
+	spectrum_host_status{id="3",name="BCVM1",status="online"} 0
+	spectrum_host_status{id="3",name="BCVM1",status="other"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestHostMappings(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lshost", "testdata/lshost-unmapped.jsonnet")
+	c.prepare("rest/lshostvdiskmap", "testdata/lshostvdiskmap.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeHostMappings(c, r, &Options{}) {
+		t.Errorf("probeHostMappings() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_host_mapped_volumes Number of volumes mapped to host
+	# TYPE spectrum_host_mapped_volumes gauge
+	spectrum_host_mapped_volumes{host_id="2",host_name="zzzzzzzzzzzz"} 2
+	spectrum_host_mapped_volumes{host_id="3",host_name="BCVM1"} 2
+	spectrum_host_mapped_volumes{host_id="4",host_name="db-restore"} 0
+	# HELP spectrum_host_volume_mapping_info Mapping of volume to host
+	# TYPE spectrum_host_volume_mapping_info gauge
+	spectrum_host_volume_mapping_info{host_id="2",host_name="zzzzzzzzzzzz",scsi_id="0",volume_id="0",volume_name="esx-ds01"} 1
+	spectrum_host_volume_mapping_info{host_id="2",host_name="zzzzzzzzzzzz",scsi_id="1",volume_id="1",volume_name="esx-ds02"} 1
+	spectrum_host_volume_mapping_info{host_id="3",host_name="BCVM1",scsi_id="0",volume_id="0",volume_name="esx-ds01"} 1
+	spectrum_host_volume_mapping_info{host_id="3",host_name="BCVM1",scsi_id="1",volume_id="1",volume_name="esx-ds02"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestHostPorts(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lshost", "testdata/lshost.jsonnet")
+	c.prepare("rest/lshost/2", "testdata/lshost-iscsi.jsonnet")
+	c.prepare("rest/lshost/3", "testdata/lshost-fc.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeHostPorts(c, r, &Options{}) {
+		t.Errorf("probeHostPorts() returned non-success")
+	}
+
+	// The iSCSI host has no Fibre Channel ports
+	em := `
+	# HELP spectrum_host_port_logged_in_nodes Number of nodes the Fibre Channel port of a host is logged in to
+	# TYPE spectrum_host_port_logged_in_nodes gauge
+	spectrum_host_port_logged_in_nodes{host_id="3",host_name="BCVM1",wwpn="C05076E76A801100"} 0
+	spectrum_host_port_logged_in_nodes{host_id="3",host_name="BCVM1",wwpn="C05076E76A801800"} 1
+	spectrum_host_port_logged_in_nodes{host_id="3",host_name="BCVM1",wwpn="C05076E76A801C00"} 2
+	# HELP spectrum_host_port_login_status Login state of a Fibre Channel port of a host
+	# TYPE spectrum_host_port_login_status gauge
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="active",wwpn="C05076E76A801100"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="degraded",wwpn="C05076E76A801100"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="inactive",wwpn="C05076E76A801100"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="offline",wwpn="C05076E76A801100"} 1
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="other",wwpn="C05076E76A801100"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="active",wwpn="C05076E76A801800"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="degraded",wwpn="C05076E76A801800"} 1
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="inactive",wwpn="C05076E76A801800"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="offline",wwpn="C05076E76A801800"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="other",wwpn="C05076E76A801800"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="active",wwpn="C05076E76A801C00"} 1
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="degraded",wwpn="C05076E76A801C00"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="inactive",wwpn="C05076E76A801C00"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="offline",wwpn="C05076E76A801C00"} 0
+	spectrum_host_port_login_status{host_id="3",host_name="BCVM1",state="other",wwpn="C05076E76A801C00"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestPoolSnapshots(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp-snapshot.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probePool(c, r, &Options{}) {
+		t.Errorf("probePool() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_pool_snapshot_written_bytes Capacity in pool written to by the snapshots of its volumes
+	# TYPE spectrum_pool_snapshot_written_bytes gauge
+	spectrum_pool_snapshot_written_bytes{id="0",name="Pool0",site_id="",site_name=""} 1.29385889792e+11
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_pool_snapshot_written_bytes"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestUnmappedVolumes(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lshostvdiskmap", "testdata/lshostvdiskmap.jsonnet")
+	c.prepare("rest/lsvdisk", "testdata/lsvdisk-replicated.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeUnmappedVolumes(c, r, &Options{}) {
+		t.Errorf("probeUnmappedVolumes() returned non-success")
+	}
+
+	// sql-data and scratch are not part of any copy service, the FlashCopy
+	// and remote copy targets are counted apart
+	em := `
+	# HELP spectrum_volumes_unmapped Number of volumes not mapped to any host, by the copy service they are part of
+	# TYPE spectrum_volumes_unmapped gauge
+	spectrum_volumes_unmapped{copy="flashcopy"} 1
+	spectrum_volumes_unmapped{copy="none"} 2
+	spectrum_volumes_unmapped{copy="remote_copy"} 1
+	# HELP spectrum_volumes_unmapped_capacity_bytes Total capacity of the volumes not mapped to any host, by the copy service they are part of
+	# TYPE spectrum_volumes_unmapped_capacity_bytes gauge
+	spectrum_volumes_unmapped_capacity_bytes{copy="flashcopy"} 5.36870912e+11
+	spectrum_volumes_unmapped_capacity_bytes{copy="none"} 6.442450944e+11
+	spectrum_volumes_unmapped_capacity_bytes{copy="remote_copy"} 5.36870912e+11
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestVolumes(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsvdisk", "testdata/lsvdisk.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeVolumes(c, r, &Options{}) {
+		t.Errorf("probeVolumes() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_volume_capacity_bytes Provisioned capacity of volume in bytes
+	# TYPE spectrum_volume_capacity_bytes gauge
+	spectrum_volume_capacity_bytes{id="0",name="esx-ds01"} 1.099511627776e+12
+	spectrum_volume_capacity_bytes{id="1",name="esx-ds02"} 2.199023255552e+12
+	spectrum_volume_capacity_bytes{id="2",name="sql-data"} 5.36870912e+11
+	# HELP spectrum_volume_compressed Whether any copy of volume is compressed
+	# TYPE spectrum_volume_compressed gauge
+	spectrum_volume_compressed{id="0",name="esx-ds01"} 0
+	spectrum_volume_compressed{id="1",name="esx-ds02"} 1
+	spectrum_volume_compressed{id="2",name="sql-data"} 0
+	# HELP spectrum_volume_status Status of volume
+	# TYPE spectrum_volume_status gauge
+	spectrum_volume_status{id="0",name="esx-ds01",status="degraded"} 0
+	spectrum_volume_status{id="0",name="esx-ds01",status="offline"} 0
+	spectrum_volume_status{id="0",name="esx-ds01",status="online"} 1
+	spectrum_volume_status{id="0",name="esx-ds01",status="other"} 0
+	spectrum_volume_status{id="1",name="esx-ds02",status="degraded"} 0
+	spectrum_volume_status{id="1",name="esx-ds02",status="offline"} 0
+	spectrum_volume_status{id="1",name="esx-ds02",status="online"} 1
+	spectrum_volume_status{id="1",name="esx-ds02",status="other"} 0
+	spectrum_volume_status{id="2",name="sql-data",status="degraded"} 1
+	spectrum_volume_status{id="2",name="sql-data",status="offline"} 0
+	spectrum_volume_status{id="2",name="sql-data",status="online"} 0
+	spectrum_volume_status{id="2",name="sql-data",status="other"} 0
+	# HELP spectrum_volume_thin_provisioned Whether any copy of volume is thin-provisioned
+	# TYPE spectrum_volume_thin_provisioned gauge
+	spectrum_volume_thin_provisioned{id="0",name="esx-ds01"} 0
+	spectrum_volume_thin_provisioned{id="1",name="esx-ds02"} 1
+	spectrum_volume_thin_provisioned{id="2",name="sql-data"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestVolumeProvisioning(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp-policy.jsonnet")
+	c.prepare("rest/lsvdisk", "testdata/lsvdisk-savings.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeVolumeProvisioning(c, r, &Options{}) {
+		t.Errorf("probeVolumeProvisioning() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_volume_provisioning_info Capacity savings and provisioning policy of volume
+	# TYPE spectrum_volume_provisioning_info gauge
+	spectrum_volume_provisioning_info{capacity_savings="compressed",id="1",name="esx-ds02",provisioning_policy="thin-compressed"} 1
+	spectrum_volume_provisioning_info{capacity_savings="none",id="2",name="sql-data",provisioning_policy=""} 1
+	spectrum_volume_provisioning_info{capacity_savings="thin",id="0",name="esx-ds01",provisioning_policy="thin-compressed"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestVolumeProvisioningFilter(t *testing.T) {
+	f := &config.ObjectFilter{Include: "^esx-"}
+	if err := f.Compile(); err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	opts := &Options{Filters: map[string]*config.ObjectFilter{"volume_provisioning": f}}
+
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp-policy.jsonnet")
+	c.prepare("rest/lsvdisk", "testdata/lsvdisk-savings.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeVolumeProvisioning(c, r, opts) {
+		t.Errorf("probeVolumeProvisioning() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_volume_provisioning_info Capacity savings and provisioning policy of volume
+	# TYPE spectrum_volume_provisioning_info gauge
+	spectrum_volume_provisioning_info{capacity_savings="compressed",id="1",name="esx-ds02",provisioning_policy="thin-compressed"} 1
+	spectrum_volume_provisioning_info{capacity_savings="thin",id="0",name="esx-ds01",provisioning_policy="thin-compressed"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestVolumePreferredNodes(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsnodecanister", "testdata/lsnodecanister-failover.jsonnet")
+	c.prepare("rest/lsvdisk", "testdata/lsvdisk.jsonnet")
+	c.prepare("rest/lsvdisk/0", "testdata/lsvdisk-0.jsonnet")
+	c.prepare("rest/lsvdisk/1", "testdata/lsvdisk-1.jsonnet")
+	c.prepare("rest/lsvdisk/2", "testdata/lsvdisk-2.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeVolumePreferredNodes(c, r, &Options{}) {
+		t.Errorf("probeVolumePreferredNodes() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_volumes_off_preferred_node Number of volumes of the caching I/O group not served by their preferred node
+	# TYPE spectrum_volumes_off_preferred_node gauge
+	spectrum_volumes_off_preferred_node{io_group_id="0",io_group_name="io_grp0",reason="io_group_mismatch"} 0
+	spectrum_volumes_off_preferred_node{io_group_id="0",io_group_name="io_grp0",reason="node_offline"} 1
+	spectrum_volumes_off_preferred_node{io_group_id="1",io_group_name="io_grp1",reason="io_group_mismatch"} 1
+	spectrum_volumes_off_preferred_node{io_group_id="1",io_group_name="io_grp1",reason="node_offline"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestPool(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probePool(c, r, &Options{}) {
+		t.Errorf("probePool() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_pool_capacity_bytes Capacity of pool in bytes
+	# TYPE spectrum_pool_capacity_bytes gauge
+	spectrum_pool_capacity_bytes{id="0",name="Pool0",site_id="",site_name=""} 1.0709243254538e+13
+	# HELP spectrum_pool_easy_tier_mode Configured Easy Tier mode of pool
+	# TYPE spectrum_pool_easy_tier_mode gauge
+	spectrum_pool_easy_tier_mode{id="0",mode="auto",name="Pool0",site_id="",site_name=""} 1
+	spectrum_pool_easy_tier_mode{id="0",mode="other",name="Pool0",site_id="",site_name=""} 0
+	spectrum_pool_easy_tier_mode{id="0",mode="balanced",name="Pool0",site_id="",site_name=""} 0
+	spectrum_pool_easy_tier_mode{id="0",mode="measure",name="Pool0",site_id="",site_name=""} 0
+	spectrum_pool_easy_tier_mode{id="0",mode="off",name="Pool0",site_id="",site_name=""} 0
+	spectrum_pool_easy_tier_mode{id="0",mode="on",name="Pool0",site_id="",site_name=""} 0
+	# HELP spectrum_pool_easy_tier_status Easy Tier status of pool
+	# TYPE spectrum_pool_easy_tier_status gauge
+	spectrum_pool_easy_tier_status{id="0",name="Pool0",site_id="",site_name="",status="active"} 0
+	spectrum_pool_easy_tier_status{id="0",name="Pool0",site_id="",site_name="",status="other"} 0
+	spectrum_pool_easy_tier_status{id="0",name="Pool0",site_id="",site_name="",status="balanced"} 1
+	spectrum_pool_easy_tier_status{id="0",name="Pool0",site_id="",site_name="",status="inactive"} 0
+	spectrum_pool_easy_tier_status{id="0",name="Pool0",site_id="",site_name="",status="measured"} 0
+	# HELP spectrum_pool_free_bytes Free bytes in pool
+	# TYPE spectrum_pool_free_bytes gauge
+	spectrum_pool_free_bytes{id="0",name="Pool0",site_id="",site_name=""} 9.829633952317e+12
+	# HELP spectrum_pool_status Status of pool
+	# TYPE spectrum_pool_status gauge
+	spectrum_pool_status{id="0",name="Pool0",site_id="",site_name="",status="offline"} 0
+	spectrum_pool_status{id="0",name="Pool0",site_id="",site_name="",status="other"} 0
+	spectrum_pool_status{id="0",name="Pool0",site_id="",site_name="",status="online"} 1
+	# HELP spectrum_pool_used_bytes Used bytes in pool
+	# TYPE spectrum_pool_used_bytes gauge
+	spectrum_pool_used_bytes{id="0",name="Pool0",site_id="",site_name=""} 5.86252298485e+11
+	# HELP spectrum_pool_volume_count Number of volumes associated with pool
+	# TYPE spectrum_pool_volume_count gauge
+	spectrum_pool_volume_count{id="0",name="Pool0",site_id="",site_name=""} 44
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestPoolSite(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp-hyperswap.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probePool(c, r, &Options{}) {
+		t.Errorf("probePool() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_pool_capacity_bytes Capacity of pool in bytes
+	# TYPE spectrum_pool_capacity_bytes gauge
+	spectrum_pool_capacity_bytes{id="0",name="Pool0",site_id="1",site_name="DC1"} 1.0709243254538e+13
+	spectrum_pool_capacity_bytes{id="1",name="Pool1",site_id="2",site_name="DC2"} 5.354621627269e+12
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_pool_capacity_bytes"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestNodeStats(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsnodecanisterstats", "testdata/lsnodecanisterstats.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeNodeStats(c, r, &Options{}) {
+		t.Errorf("probeNodeStats() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_node_compression_usage_ratio Current ratio of allocated CPU for compresion
+	# TYPE spectrum_node_compression_usage_ratio gauge
+	spectrum_node_compression_usage_ratio{node_id="1",node_name="node1"} 0.24
+	spectrum_node_compression_usage_ratio{node_id="2",node_name="node2"} 0
+	# HELP spectrum_node_fc_bps Current bytes-per-second being transferred over Fibre Channel
+	# TYPE spectrum_node_fc_bps gauge
+	spectrum_node_fc_bps{node_id="1",node_name="node1"} 1.048576e+06
+	spectrum_node_fc_bps{node_id="2",node_name="node2"} 0
+	# HELP spectrum_node_fc_iops Current I/O-per-second being transferred over Fibre Channel
+	# TYPE spectrum_node_fc_iops gauge
+	spectrum_node_fc_iops{node_id="1",node_name="node1"} 5
+	spectrum_node_fc_iops{node_id="2",node_name="node2"} 5
+	# HELP spectrum_node_fc_mb_raw Raw fc_mb value as reported by the node, before unit conversion
+	# TYPE spectrum_node_fc_mb_raw gauge
+	spectrum_node_fc_mb_raw{node_id="1",node_name="node1"} 1
+	spectrum_node_fc_mb_raw{node_id="2",node_name="node2"} 0
+	# HELP spectrum_node_iscsi_bps Current bytes-per-second being transferred over iSCSI
+	# TYPE spectrum_node_iscsi_bps gauge
+	spectrum_node_iscsi_bps{node_id="1",node_name="node1"} 0
+	spectrum_node_iscsi_bps{node_id="2",node_name="node2"} 0
+	# HELP spectrum_node_iscsi_iops Current I/O-per-second being transferred over iSCSI
+	# TYPE spectrum_node_iscsi_iops gauge
+	spectrum_node_iscsi_iops{node_id="1",node_name="node1"} 0
+	spectrum_node_iscsi_iops{node_id="2",node_name="node2"} 11
+	# HELP spectrum_node_iscsi_mb_raw Raw iscsi_mb value as reported by the node, before unit conversion
+	# TYPE spectrum_node_iscsi_mb_raw gauge
+	spectrum_node_iscsi_mb_raw{node_id="1",node_name="node1"} 0
+	spectrum_node_iscsi_mb_raw{node_id="2",node_name="node2"} 0
+	# HELP spectrum_node_latency_seconds Current average latency of the node by layer, vdisk (front-end), mdisk (back-end) or drive
+	# TYPE spectrum_node_latency_seconds gauge
+	spectrum_node_latency_seconds{layer="drive",node_id="1",node_name="node1",op="read"} 0.012
+	spectrum_node_latency_seconds{layer="drive",node_id="1",node_name="node1",op="write"} 0.007
+	spectrum_node_latency_seconds{layer="mdisk",node_id="1",node_name="node1",op="read"} 0
+	spectrum_node_latency_seconds{layer="mdisk",node_id="1",node_name="node1",op="write"} 0
+	spectrum_node_latency_seconds{layer="vdisk",node_id="1",node_name="node1",op="read"} 0
+	spectrum_node_latency_seconds{layer="vdisk",node_id="1",node_name="node1",op="write"} 0
+	spectrum_node_latency_seconds{layer="drive",node_id="2",node_name="node2",op="read"} 0
+	spectrum_node_latency_seconds{layer="drive",node_id="2",node_name="node2",op="write"} 0.011
+	spectrum_node_latency_seconds{layer="mdisk",node_id="2",node_name="node2",op="read"} 0
+	spectrum_node_latency_seconds{layer="mdisk",node_id="2",node_name="node2",op="write"} 0.007
+	spectrum_node_latency_seconds{layer="vdisk",node_id="2",node_name="node2",op="read"} 0.001
+	spectrum_node_latency_seconds{layer="vdisk",node_id="2",node_name="node2",op="write"} 0
+	# HELP spectrum_node_sas_bps Current bytes-per-second being transferred over backend SAS
+	# TYPE spectrum_node_sas_bps gauge
+	spectrum_node_sas_bps{node_id="1",node_name="node1"} 0
+	spectrum_node_sas_bps{node_id="2",node_name="node2"} 0
+	# HELP spectrum_node_sas_iops Current I/O-per-second being transferred over backend SAS
+	# TYPE spectrum_node_sas_iops gauge
+	spectrum_node_sas_iops{node_id="1",node_name="node1"} 5
+	spectrum_node_sas_iops{node_id="2",node_name="node2"} 0
+	# HELP spectrum_node_sas_mb_raw Raw sas_mb value as reported by the node, before unit conversion
+	# TYPE spectrum_node_sas_mb_raw gauge
+	spectrum_node_sas_mb_raw{node_id="1",node_name="node1"} 0
+	spectrum_node_sas_mb_raw{node_id="2",node_name="node2"} 0
+	# HELP spectrum_node_system_usage_ratio Current ratio of allocated CPU for system
+	# TYPE spectrum_node_system_usage_ratio gauge
+	spectrum_node_system_usage_ratio{node_id="1",node_name="node1"} 0.01
+	spectrum_node_system_usage_ratio{node_id="2",node_name="node2"} 0.01
+	# HELP spectrum_node_total_cache_usage_ratio Total percentage for both the write and read cache usage for the node
+	# TYPE spectrum_node_total_cache_usage_ratio gauge
+	spectrum_node_total_cache_usage_ratio{node_id="1",node_name="node1"} 0.79
+	spectrum_node_total_cache_usage_ratio{node_id="2",node_name="node2"} 0.79
+	# HELP spectrum_node_write_cache_usage_ratio Ratio of the write cache usage for the node
+	# TYPE spectrum_node_write_cache_usage_ratio gauge
+	spectrum_node_write_cache_usage_ratio{node_id="1",node_name="node1"} 0.25
+	spectrum_node_write_cache_usage_ratio{node_id="2",node_name="node2"} 0.25
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestNodeStatsFilter(t *testing.T) {
+	f := &config.ObjectFilter{Include: "^node1$"}
+	if err := f.Compile(); err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	opts := &Options{Filters: map[string]*config.ObjectFilter{"node_stats": f}}
+
+	c := newFakeClient()
+	c.prepare("rest/lsnodecanisterstats", "testdata/lsnodecanisterstats.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeNodeStats(c, r, opts) {
+		t.Errorf("probeNodeStats() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_node_system_usage_ratio Current ratio of allocated CPU for system
+	# TYPE spectrum_node_system_usage_ratio gauge
+	spectrum_node_system_usage_ratio{node_id="1",node_name="node1"} 0.01
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_node_system_usage_ratio"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestIOGroupCache(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsnodecanister", "testdata/lsnodecanister.jsonnet")
+	c.prepare("rest/lsnodecanisterstats", "testdata/lsnodecanisterstats-destage.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeIOGroupCache(c, r, &Options{}) {
+		t.Errorf("probeIOGroupCache() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_io_group_destage_bps Current bytes-per-second written from the cache of the I/O group to the back-end
+	# TYPE spectrum_io_group_destage_bps gauge
+	spectrum_io_group_destage_bps{io_group_id="0",io_group_name="io_grp0"} 2.09715200e+08
+	# HELP spectrum_io_group_destage_iops Current I/O-per-second written from the cache of the I/O group to the back-end
+	# TYPE spectrum_io_group_destage_iops gauge
+	spectrum_io_group_destage_iops{io_group_id="0",io_group_name="io_grp0"} 1500
+	# HELP spectrum_io_group_write_cache_usage_ratio Highest ratio of the write cache usage of the nodes of the I/O group
+	# TYPE spectrum_io_group_write_cache_usage_ratio gauge
+	spectrum_io_group_write_cache_usage_ratio{io_group_id="0",io_group_name="io_grp0"} 0.71
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestNodeStatsCores(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsnodecanisterstats", "testdata/lsnodecanisterstats-cores.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeNodeStats(c, r, &Options{}) {
+		t.Errorf("probeNodeStats() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_node_cpu_core_usage_ratio Current ratio of CPU usage per core, where the node reports it
+	# TYPE spectrum_node_cpu_core_usage_ratio gauge
+	spectrum_node_cpu_core_usage_ratio{core="0",node_id="1",node_name="node1"} 0.12
+	spectrum_node_cpu_core_usage_ratio{core="0",node_id="2",node_name="node2"} 0.08
+	spectrum_node_cpu_core_usage_ratio{core="1",node_id="1",node_name="node1"} 0.97
+	spectrum_node_cpu_core_usage_ratio{core="1",node_id="2",node_name="node2"} 0.09
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_node_cpu_core_usage_ratio"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestFCPorts(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsportfc", "testdata/lsportfc.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeFCPorts(c, r, &Options{}) {
+		t.Errorf("probeFCPorts() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_fc_port_speed_bps Operational speed of port in bits per second
+	# TYPE spectrum_fc_port_speed_bps gauge
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="1",node_id="1",node_name="node1"} 8e+09
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="1",node_id="2",node_name="node2"} 8e+09
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="2",node_id="1",node_name="node1"} 8e+09
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="2",node_id="2",node_name="node2"} 8e+09
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="3",node_id="1",node_name="node1"} 0
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="3",node_id="2",node_name="node2"} 0
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="4",node_id="1",node_name="node1"} 0
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="4",node_id="2",node_name="node2"} 0
+	spectrum_fc_port_speed_bps{adapter_location="3",adapter_port_id="1",node_id="1",node_name="node1"} 0
+	spectrum_fc_port_speed_bps{adapter_location="3",adapter_port_id="1",node_id="2",node_name="node2"} 0
+	spectrum_fc_port_speed_bps{adapter_location="3",adapter_port_id="2",node_id="1",node_name="node1"} 0
+	spectrum_fc_port_speed_bps{adapter_location="3",adapter_port_id="2",node_id="2",node_name="node2"} 0
+	spectrum_fc_port_speed_bps{adapter_location="3",adapter_port_id="3",node_id="1",node_name="node1"} 0
+	spectrum_fc_port_speed_bps{adapter_location="3",adapter_port_id="3",node_id="2",node_name="node2"} 0
+	spectrum_fc_port_speed_bps{adapter_location="3",adapter_port_id="4",node_id="1",node_name="node1"} 0
+	spectrum_fc_port_speed_bps{adapter_location="3",adapter_port_id="4",node_id="2",node_name="node2"} 0
+	# HELP spectrum_fc_port_status Status of Fibre Channel port
+	# TYPE spectrum_fc_port_status gauge
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="1",node_id="1",node_name="node1",status="active",wwpn="500507680B218CF8"} 1
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="1",node_id="1",node_name="node1",status="other",wwpn="500507680B218CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="1",node_id="1",node_name="node1",status="inactive_configured",wwpn="500507680B218CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="1",node_id="1",node_name="node1",status="inactive_unconfigured",wwpn="500507680B218CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="1",node_id="2",node_name="node2",status="active",wwpn="500507680B218CF9"} 1
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="1",node_id="2",node_name="node2",status="other",wwpn="500507680B218CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="1",node_id="2",node_name="node2",status="inactive_configured",wwpn="500507680B218CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="1",node_id="2",node_name="node2",status="inactive_unconfigured",wwpn="500507680B218CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="2",node_id="1",node_name="node1",status="active",wwpn="500507680B228CF8"} 1
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="2",node_id="1",node_name="node1",status="other",wwpn="500507680B228CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="2",node_id="1",node_name="node1",status="inactive_configured",wwpn="500507680B228CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="2",node_id="1",node_name="node1",status="inactive_unconfigured",wwpn="500507680B228CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="2",node_id="2",node_name="node2",status="active",wwpn="500507680B228CF9"} 1
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="2",node_id="2",node_name="node2",status="other",wwpn="500507680B228CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="2",node_id="2",node_name="node2",status="inactive_configured",wwpn="500507680B228CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="2",node_id="2",node_name="node2",status="inactive_unconfigured",wwpn="500507680B228CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="3",node_id="1",node_name="node1",status="active",wwpn="500507680B238CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="3",node_id="1",node_name="node1",status="other",wwpn="500507680B238CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="3",node_id="1",node_name="node1",status="inactive_configured",wwpn="500507680B238CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="3",node_id="1",node_name="node1",status="inactive_unconfigured",wwpn="500507680B238CF8"} 1
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="3",node_id="2",node_name="node2",status="active",wwpn="500507680B238CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="3",node_id="2",node_name="node2",status="other",wwpn="500507680B238CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="3",node_id="2",node_name="node2",status="inactive_configured",wwpn="500507680B238CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="3",node_id="2",node_name="node2",status="inactive_unconfigured",wwpn="500507680B238CF9"} 1
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="4",node_id="1",node_name="node1",status="active",wwpn="500507680B248CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="4",node_id="1",node_name="node1",status="other",wwpn="500507680B248CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="4",node_id="1",node_name="node1",status="inactive_configured",wwpn="500507680B248CF8"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="4",node_id="1",node_name="node1",status="inactive_unconfigured",wwpn="500507680B248CF8"} 1
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="4",node_id="2",node_name="node2",status="active",wwpn="500507680B248CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="4",node_id="2",node_name="node2",status="other",wwpn="500507680B248CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="4",node_id="2",node_name="node2",status="inactive_configured",wwpn="500507680B248CF9"} 0
+	spectrum_fc_port_status{adapter_location="2",adapter_port_id="4",node_id="2",node_name="node2",status="inactive_unconfigured",wwpn="500507680B248CF9"} 1
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="1",node_id="1",node_name="node1",status="active",wwpn="500507680B318CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="1",node_id="1",node_name="node1",status="other",wwpn="500507680B318CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="1",node_id="1",node_name="node1",status="inactive_configured",wwpn="500507680B318CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="1",node_id="1",node_name="node1",status="inactive_unconfigured",wwpn="500507680B318CF8"} 1
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="1",node_id="2",node_name="node2",status="active",wwpn="500507680B318CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="1",node_id="2",node_name="node2",status="other",wwpn="500507680B318CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="1",node_id="2",node_name="node2",status="inactive_configured",wwpn="500507680B318CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="1",node_id="2",node_name="node2",status="inactive_unconfigured",wwpn="500507680B318CF9"} 1
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="2",node_id="1",node_name="node1",status="active",wwpn="500507680B328CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="2",node_id="1",node_name="node1",status="other",wwpn="500507680B328CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="2",node_id="1",node_name="node1",status="inactive_configured",wwpn="500507680B328CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="2",node_id="1",node_name="node1",status="inactive_unconfigured",wwpn="500507680B328CF8"} 1
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="2",node_id="2",node_name="node2",status="active",wwpn="500507680B328CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="2",node_id="2",node_name="node2",status="other",wwpn="500507680B328CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="2",node_id="2",node_name="node2",status="inactive_configured",wwpn="500507680B328CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="2",node_id="2",node_name="node2",status="inactive_unconfigured",wwpn="500507680B328CF9"} 1
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="3",node_id="1",node_name="node1",status="active",wwpn="500507680B338CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="3",node_id="1",node_name="node1",status="other",wwpn="500507680B338CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="3",node_id="1",node_name="node1",status="inactive_configured",wwpn="500507680B338CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="3",node_id="1",node_name="node1",status="inactive_unconfigured",wwpn="500507680B338CF8"} 1
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="3",node_id="2",node_name="node2",status="active",wwpn="500507680B338CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="3",node_id="2",node_name="node2",status="other",wwpn="500507680B338CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="3",node_id="2",node_name="node2",status="inactive_configured",wwpn="500507680B338CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="3",node_id="2",node_name="node2",status="inactive_unconfigured",wwpn="500507680B338CF9"} 1
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="4",node_id="1",node_name="node1",status="active",wwpn="500507680B348CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="4",node_id="1",node_name="node1",status="other",wwpn="500507680B348CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="4",node_id="1",node_name="node1",status="inactive_configured",wwpn="500507680B348CF8"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="4",node_id="1",node_name="node1",status="inactive_unconfigured",wwpn="500507680B348CF8"} 1
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="4",node_id="2",node_name="node2",status="active",wwpn="500507680B348CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="4",node_id="2",node_name="node2",status="other",wwpn="500507680B348CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="4",node_id="2",node_name="node2",status="inactive_configured",wwpn="500507680B348CF9"} 0
+	spectrum_fc_port_status{adapter_location="3",adapter_port_id="4",node_id="2",node_name="node2",status="inactive_unconfigured",wwpn="500507680B348CF9"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_fc_port_speed_bps", "spectrum_fc_port_status"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestFCPortAttachment(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsportfc", "testdata/lsportfc-direct.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeFCPorts(c, r, &Options{}) {
+		t.Errorf("probeFCPorts() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_fc_port_attachment What the Fibre Channel port is attached to, a switch or directly to another port
+	# TYPE spectrum_fc_port_attachment gauge
+	spectrum_fc_port_attachment{adapter_location="2",adapter_port_id="1",attachment="direct",node_id="1",node_name="node1",wwpn="500507680B218CF8"} 1
+	spectrum_fc_port_attachment{adapter_location="2",adapter_port_id="1",attachment="none",node_id="1",node_name="node1",wwpn="500507680B218CF8"} 0
+	spectrum_fc_port_attachment{adapter_location="2",adapter_port_id="1",attachment="other",node_id="1",node_name="node1",wwpn="500507680B218CF8"} 0
+	spectrum_fc_port_attachment{adapter_location="2",adapter_port_id="1",attachment="switch",node_id="1",node_name="node1",wwpn="500507680B218CF8"} 0
+	spectrum_fc_port_attachment{adapter_location="2",adapter_port_id="2",attachment="direct",node_id="1",node_name="node1",wwpn="500507680B228CF8"} 0
+	spectrum_fc_port_attachment{adapter_location="2",adapter_port_id="2",attachment="none",node_id="1",node_name="node1",wwpn="500507680B228CF8"} 0
+	spectrum_fc_port_attachment{adapter_location="2",adapter_port_id="2",attachment="other",node_id="1",node_name="node1",wwpn="500507680B228CF8"} 0
+	spectrum_fc_port_attachment{adapter_location="2",adapter_port_id="2",attachment="switch",node_id="1",node_name="node1",wwpn="500507680B228CF8"} 1
+	# HELP spectrum_fc_port_topology_info Fibre Channel topology of the port, where reported by the firmware
+	# TYPE spectrum_fc_port_topology_info gauge
+	spectrum_fc_port_topology_info{adapter_location="2",adapter_port_id="1",node_id="1",node_name="node1",topology="p2p",wwpn="500507680B218CF8"} 1
+	spectrum_fc_port_topology_info{adapter_location="2",adapter_port_id="2",node_id="1",node_name="node1",topology="fabric",wwpn="500507680B228CF8"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_fc_port_attachment", "spectrum_fc_port_topology_info"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestFCPortSpeeds(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsportfc", "testdata/lsportfc-speed.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeFCPorts(c, r, &Options{}) {
+		t.Errorf("probeFCPorts() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_fc_port_configured_speed_bps Configured speed of port in bits per second, where fixed rather than auto-negotiated
+	# TYPE spectrum_fc_port_configured_speed_bps gauge
+	spectrum_fc_port_configured_speed_bps{adapter_location="2",adapter_port_id="1",node_id="1",node_name="node1"} 1.6e+10
+	# HELP spectrum_fc_port_speed_autonegotiated Whether the speed of the port is auto-negotiated, where reported by the firmware
+	# TYPE spectrum_fc_port_speed_autonegotiated gauge
+	spectrum_fc_port_speed_autonegotiated{adapter_location="2",adapter_port_id="1",node_id="1",node_name="node1"} 0
+	spectrum_fc_port_speed_autonegotiated{adapter_location="2",adapter_port_id="2",node_id="1",node_name="node1"} 1
+	spectrum_fc_port_speed_autonegotiated{adapter_location="2",adapter_port_id="3",node_id="1",node_name="node1"} 1
+	# HELP spectrum_fc_port_speed_bps Operational speed of port in bits per second
+	# TYPE spectrum_fc_port_speed_bps gauge
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="1",node_id="1",node_name="node1"} 8e+09
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="2",node_id="1",node_name="node1"} 3.2e+10
+	spectrum_fc_port_speed_bps{adapter_location="2",adapter_port_id="3",node_id="1",node_name="node1"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_fc_port_speed_bps", "spectrum_fc_port_configured_speed_bps", "spectrum_fc_port_speed_autonegotiated"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestParseLinkSpeed(t *testing.T) {
+	tests := []struct {
+		in   string
+		bps  int64
+		auto bool
+		err  bool
+	}{
+		{"8Gb", 8e9, false, false},
+		{"16Gb", 16e9, false, false},
+		{"32Gb", 32e9, false, false},
+		{"10Gb/s", 10e9, false, false},
+		{"100Mb/s", 100e6, false, false},
+		{"25 Gbps", 25e9, false, false},
+		{"N/A", 0, false, false},
+		{"", 0, false, false},
+		{"auto", 0, true, false},
+		{"auto (16Gb)", 16e9, true, false},
+		{"fast", 0, false, true},
+		{"8Tb", 0, false, true},
+	}
+	for _, tc := range tests {
+		bps, auto, err := parseLinkSpeed(tc.in)
+		if (err != nil) != tc.err {
+			t.Errorf("parseLinkSpeed(%q) error = %v, expected error %v", tc.in, err, tc.err)
+			continue
+		}
+		if bps != tc.bps || auto != tc.auto {
+			t.Errorf("parseLinkSpeed(%q) = %d, %v, expected %d, %v", tc.in, bps, auto, tc.bps, tc.auto)
+		}
+	}
+}
+
+func TestIPPorts(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsportip", "testdata/lsportip.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeIPPorts(c, r, &Options{}) {
+		t.Errorf("probeIPPorts() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_ip_port_duplex Negotiated duplex mode of Ethernet/IP port with a link
+	# TYPE spectrum_ip_port_duplex gauge
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="1",duplex="full",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1"} 1
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="1",duplex="full",mac="40:f2:e9:70:ae:56",node_id="2",node_name="node2"} 1
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="1",duplex="half",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="1",duplex="half",mac="40:f2:e9:70:ae:56",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="1",duplex="other",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="1",duplex="other",mac="40:f2:e9:70:ae:56",node_id="2",node_name="node2"} 0
+	# HELP spectrum_ip_port_link_active Whether link is active
+	# TYPE spectrum_ip_port_link_active gauge
+	spectrum_ip_port_link_active{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1"} 1
+	spectrum_ip_port_link_active{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ae:56",node_id="2",node_name="node2"} 1
+	spectrum_ip_port_link_active{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ad:e8",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_link_active{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ae:54",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_link_active{adapter_location="0",adapter_port_id="3",mac="40:f2:e9:70:ad:eb",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_link_active{adapter_location="0",adapter_port_id="3",mac="40:f2:e9:70:ae:57",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_link_active{adapter_location="3",adapter_port_id="1",mac="40:f2:e9:e1:8e:cf",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_link_active{adapter_location="3",adapter_port_id="1",mac="40:f2:e9:e1:91:47",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_link_active{adapter_location="3",adapter_port_id="2",mac="40:f2:e9:e1:8e:ce",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_link_active{adapter_location="3",adapter_port_id="2",mac="40:f2:e9:e1:91:46",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_link_active{adapter_location="3",adapter_port_id="3",mac="40:f2:e9:e1:8e:cd",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_link_active{adapter_location="3",adapter_port_id="3",mac="40:f2:e9:e1:91:45",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_link_active{adapter_location="3",adapter_port_id="4",mac="40:f2:e9:e1:8e:cc",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_link_active{adapter_location="3",adapter_port_id="4",mac="40:f2:e9:e1:91:44",node_id="1",node_name="node1"} 0
+	# HELP spectrum_ip_port_speed_bps Operational speed of port in bits per second
+	# TYPE spectrum_ip_port_speed_bps gauge
+	spectrum_ip_port_speed_bps{adapter_location="0",adapter_port_id="1",node_id="1",node_name="node1"} 1e+09
+	spectrum_ip_port_speed_bps{adapter_location="0",adapter_port_id="1",node_id="2",node_name="node2"} 1e+09
+	spectrum_ip_port_speed_bps{adapter_location="0",adapter_port_id="2",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_speed_bps{adapter_location="0",adapter_port_id="2",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_speed_bps{adapter_location="0",adapter_port_id="3",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_speed_bps{adapter_location="0",adapter_port_id="3",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_speed_bps{adapter_location="3",adapter_port_id="1",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_speed_bps{adapter_location="3",adapter_port_id="1",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_speed_bps{adapter_location="3",adapter_port_id="2",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_speed_bps{adapter_location="3",adapter_port_id="2",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_speed_bps{adapter_location="3",adapter_port_id="3",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_speed_bps{adapter_location="3",adapter_port_id="3",node_id="2",node_name="node2"} 0
+	spectrum_ip_port_speed_bps{adapter_location="3",adapter_port_id="4",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_speed_bps{adapter_location="3",adapter_port_id="4",node_id="2",node_name="node2"} 0
+	# HELP spectrum_ip_port_state Configuration state of Ethernet/IP port
+	# TYPE spectrum_ip_port_state gauge
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1",state="configured"} 1
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1",state="other"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1",state="unconfigured"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ae:56",node_id="2",node_name="node2",state="configured"} 1
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ae:56",node_id="2",node_name="node2",state="other"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ae:56",node_id="2",node_name="node2",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ae:56",node_id="2",node_name="node2",state="unconfigured"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ad:e8",node_id="1",node_name="node1",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ad:e8",node_id="1",node_name="node1",state="other"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ad:e8",node_id="1",node_name="node1",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ad:e8",node_id="1",node_name="node1",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ae:54",node_id="2",node_name="node2",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ae:54",node_id="2",node_name="node2",state="other"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ae:54",node_id="2",node_name="node2",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ae:54",node_id="2",node_name="node2",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="3",mac="40:f2:e9:70:ad:eb",node_id="1",node_name="node1",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="3",mac="40:f2:e9:70:ad:eb",node_id="1",node_name="node1",state="other"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="3",mac="40:f2:e9:70:ad:eb",node_id="1",node_name="node1",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="3",mac="40:f2:e9:70:ad:eb",node_id="1",node_name="node1",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="3",mac="40:f2:e9:70:ae:57",node_id="2",node_name="node2",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="3",mac="40:f2:e9:70:ae:57",node_id="2",node_name="node2",state="other"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="3",mac="40:f2:e9:70:ae:57",node_id="2",node_name="node2",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="0",adapter_port_id="3",mac="40:f2:e9:70:ae:57",node_id="2",node_name="node2",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="1",mac="40:f2:e9:e1:8e:cf",node_id="2",node_name="node2",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="1",mac="40:f2:e9:e1:8e:cf",node_id="2",node_name="node2",state="other"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="1",mac="40:f2:e9:e1:8e:cf",node_id="2",node_name="node2",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="1",mac="40:f2:e9:e1:8e:cf",node_id="2",node_name="node2",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="1",mac="40:f2:e9:e1:91:47",node_id="1",node_name="node1",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="1",mac="40:f2:e9:e1:91:47",node_id="1",node_name="node1",state="other"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="1",mac="40:f2:e9:e1:91:47",node_id="1",node_name="node1",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="1",mac="40:f2:e9:e1:91:47",node_id="1",node_name="node1",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="2",mac="40:f2:e9:e1:8e:ce",node_id="2",node_name="node2",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="2",mac="40:f2:e9:e1:8e:ce",node_id="2",node_name="node2",state="other"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="2",mac="40:f2:e9:e1:8e:ce",node_id="2",node_name="node2",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="2",mac="40:f2:e9:e1:8e:ce",node_id="2",node_name="node2",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="2",mac="40:f2:e9:e1:91:46",node_id="1",node_name="node1",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="2",mac="40:f2:e9:e1:91:46",node_id="1",node_name="node1",state="other"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="2",mac="40:f2:e9:e1:91:46",node_id="1",node_name="node1",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="2",mac="40:f2:e9:e1:91:46",node_id="1",node_name="node1",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="3",mac="40:f2:e9:e1:8e:cd",node_id="2",node_name="node2",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="3",mac="40:f2:e9:e1:8e:cd",node_id="2",node_name="node2",state="other"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="3",mac="40:f2:e9:e1:8e:cd",node_id="2",node_name="node2",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="3",mac="40:f2:e9:e1:8e:cd",node_id="2",node_name="node2",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="3",mac="40:f2:e9:e1:91:45",node_id="1",node_name="node1",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="3",mac="40:f2:e9:e1:91:45",node_id="1",node_name="node1",state="other"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="3",mac="40:f2:e9:e1:91:45",node_id="1",node_name="node1",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="3",mac="40:f2:e9:e1:91:45",node_id="1",node_name="node1",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="4",mac="40:f2:e9:e1:8e:cc",node_id="2",node_name="node2",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="4",mac="40:f2:e9:e1:8e:cc",node_id="2",node_name="node2",state="other"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="4",mac="40:f2:e9:e1:8e:cc",node_id="2",node_name="node2",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="4",mac="40:f2:e9:e1:8e:cc",node_id="2",node_name="node2",state="unconfigured"} 1
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="4",mac="40:f2:e9:e1:91:44",node_id="1",node_name="node1",state="configured"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="4",mac="40:f2:e9:e1:91:44",node_id="1",node_name="node1",state="other"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="4",mac="40:f2:e9:e1:91:44",node_id="1",node_name="node1",state="management_only"} 0
+	spectrum_ip_port_state{adapter_location="3",adapter_port_id="4",mac="40:f2:e9:e1:91:44",node_id="1",node_name="node1",state="unconfigured"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestIPPortLinkSettings(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsportip", "testdata/lsportip-mtu.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeIPPorts(c, r, &Options{}) {
+		t.Errorf("probeIPPorts() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_ip_port_duplex Negotiated duplex mode of Ethernet/IP port with a link
+	# TYPE spectrum_ip_port_duplex gauge
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="1",duplex="full",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1"} 1
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="1",duplex="half",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="1",duplex="other",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="2",duplex="full",mac="40:f2:e9:70:ad:e8",node_id="1",node_name="node1"} 0
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="2",duplex="half",mac="40:f2:e9:70:ad:e8",node_id="1",node_name="node1"} 1
+	spectrum_ip_port_duplex{adapter_location="0",adapter_port_id="2",duplex="other",mac="40:f2:e9:70:ad:e8",node_id="1",node_name="node1"} 0
+	# HELP spectrum_ip_port_mtu_bytes Configured MTU of Ethernet/IP port, where reported by the firmware
+	# TYPE spectrum_ip_port_mtu_bytes gauge
+	spectrum_ip_port_mtu_bytes{adapter_location="0",adapter_port_id="1",mac="40:f2:e9:70:ad:ea",node_id="1",node_name="node1"} 9000
+	spectrum_ip_port_mtu_bytes{adapter_location="0",adapter_port_id="2",mac="40:f2:e9:70:ad:e8",node_id="1",node_name="node1"} 1500
+	# HELP spectrum_ip_port_speed_bps Operational speed of port in bits per second
+	# TYPE spectrum_ip_port_speed_bps gauge
+	spectrum_ip_port_speed_bps{adapter_location="0",adapter_port_id="1",node_id="1",node_name="node1"} 1e+09
+	spectrum_ip_port_speed_bps{adapter_location="0",adapter_port_id="2",node_id="1",node_name="node1"} 1e+08
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_ip_port_duplex", "spectrum_ip_port_mtu_bytes", "spectrum_ip_port_speed_bps"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestObjectLimits(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lssystem", "testdata/lssystem.jsonnet")
+	c.prepare("rest/lsvdisk", "testdata/lsvdisk.jsonnet")
+	c.prepare("rest/lshost", "testdata/lshost.jsonnet")
+	c.prepare("rest/lshostvdiskmap", "testdata/lshostvdiskmap.jsonnet")
+	c.prepare("rest/lsfcmap", "testdata/lsfcmap.jsonnet")
+	c.prepare("rest/lsrcrelationship", "testdata/lsrcrelationship.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeObjectLimits(c, r, &Options{}) {
+		t.Errorf("probeObjectLimits() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_object_count Number of configured objects of the given type
+	# TYPE spectrum_object_count gauge
+	spectrum_object_count{object="flashcopy_mapping"} 1
+	spectrum_object_count{object="host"} 2
+	spectrum_object_count{object="host_mapping"} 4
+	spectrum_object_count{object="remote_copy_relationship"} 0
+	spectrum_object_count{object="volume"} 3
+	# HELP spectrum_object_limit Maximum number of objects of the given type supported by the product
+	# TYPE spectrum_object_limit gauge
+	spectrum_object_limit{object="flashcopy_mapping"} 10000
+	spectrum_object_limit{object="host"} 2048
+	spectrum_object_limit{object="host_mapping"} 20000
+	spectrum_object_limit{object="remote_copy_relationship"} 10000
+	spectrum_object_limit{object="volume"} 10000
+	# HELP spectrum_object_usage_ratio Ratio of configured objects to the supported maximum
+	# TYPE spectrum_object_usage_ratio gauge
+	spectrum_object_usage_ratio{object="flashcopy_mapping"} 0.0001
+	spectrum_object_usage_ratio{object="host"} 0.0009765625
+	spectrum_object_usage_ratio{object="host_mapping"} 0.0002
+	spectrum_object_usage_ratio{object="remote_copy_relationship"} 0
+	spectrum_object_usage_ratio{object="volume"} 0.0003
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestLicense(t *testing.T) {
+	timeNow = func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { timeNow = time.Now }()
+
+	c := newFakeClient()
+	c.prepare("rest/lsfeature", "testdata/lsfeature.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeLicense(c, r, &Options{}) {
+		t.Errorf("probeLicense() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_feature_trial_days_remaining Days remaining until the trial license of the feature expires
+	# TYPE spectrum_feature_trial_days_remaining gauge
+	spectrum_feature_trial_days_remaining{name="encryption"} 30
+	# HELP spectrum_feature_trial_expiry_timestamp_seconds Time when the trial license of the feature expires
+	# TYPE spectrum_feature_trial_expiry_timestamp_seconds gauge
+	spectrum_feature_trial_expiry_timestamp_seconds{name="encryption"} 1.6120512e+09
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestEncryption(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsencryption", "testdata/lsencryption.jsonnet")
+	c.prepare("rest/lskeyserver", "testdata/lskeyserver.jsonnet")
+	c.prepare("rest/lskeyserver/1", "testdata/lskeyserver-1.jsonnet")
+	c.prepare("rest/lskeyserver/2", "testdata/lskeyserver-2.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeEncryption(c, r, &Options{}) {
+		t.Errorf("probeEncryption() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_encryption_enabled Whether encryption is enabled on the system
+	# TYPE spectrum_encryption_enabled gauge
+	spectrum_encryption_enabled 1
+	# HELP spectrum_encryption_providers_online Number of encryption key providers (USB keys and key servers) online
+	# TYPE spectrum_encryption_providers_online gauge
+	spectrum_encryption_providers_online 2
+	# HELP spectrum_encryption_usb_keys Number of USB flash drives with a valid encryption key detected
+	# TYPE spectrum_encryption_usb_keys gauge
+	spectrum_encryption_usb_keys 2
+	# HELP spectrum_keyserver_certificate_expiry_timestamp_seconds Time when the key server certificate expires
+	# TYPE spectrum_keyserver_certificate_expiry_timestamp_seconds gauge
+	spectrum_keyserver_certificate_expiry_timestamp_seconds{id="1",name="sklm01"} 1.647336600e+09
+	spectrum_keyserver_certificate_expiry_timestamp_seconds{id="2",name="sklm02"} 1.6225056e+09
+	# HELP spectrum_keyserver_status Status of key server
+	# TYPE spectrum_keyserver_status gauge
+	spectrum_keyserver_status{id="1",name="sklm01",status="offline"} 0
+	spectrum_keyserver_status{id="1",name="sklm01",status="other"} 0
+	spectrum_keyserver_status{id="1",name="sklm01",status="online"} 1
+	spectrum_keyserver_status{id="2",name="sklm02",status="offline"} 1
+	spectrum_keyserver_status{id="2",name="sklm02",status="other"} 0
+	spectrum_keyserver_status{id="2",name="sklm02",status="online"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestSystemTime(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lssystem", "testdata/lssystem.jsonnet")
+	c.prepare("rest/svqueryclock", "testdata/svqueryclock.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeSystemTime(c, r, &Options{}) {
+		t.Errorf("probeSystemTime() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_system_time_seconds Current time of the system clock in seconds since epoch
+	# TYPE spectrum_system_time_seconds gauge
+	spectrum_system_time_seconds 1.602763205e+09
+	# HELP spectrum_system_timezone_info Time zone configured on the system
+	# TYPE spectrum_system_timezone_info gauge
+	spectrum_system_timezone_info{time_zone="UTC"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestPoolFilter(t *testing.T) {
+	f := &config.ObjectFilter{Exclude: "^Test"}
+	if err := f.Compile(); err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	opts := &Options{Filters: map[string]*config.ObjectFilter{"pool": f}}
+
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp-filter.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probePool(c, r, opts) {
+		t.Errorf("probePool() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_pool_volume_count Number of volumes associated with pool
+	# TYPE spectrum_pool_volume_count gauge
+	spectrum_pool_volume_count{id="0",name="Pool0",site_id="",site_name=""} 44
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_pool_volume_count"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestNodeInfo(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsnodecanister", "testdata/lsnodecanister.jsonnet")
+	c.prepare("rest/lsnodecanister/1", "testdata/lsnodecanister-1.jsonnet")
+	c.prepare("rest/lsnodecanister/2", "testdata/lsnodecanister-2.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeNodeInfo(c, r, &Options{}) {
+		t.Errorf("probeNodeInfo() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_node_info Inventory information about the node
+	# TYPE spectrum_node_info gauge
+	spectrum_node_info{io_group="io_grp0",node_id="1",node_name="node1",panel_name="01-1",product_mtm="2076-524",serial_number="78ABCDE",wwnn="500507680B008CF8"} 1
+	spectrum_node_info{io_group="io_grp0",node_id="2",node_name="node2",panel_name="01-2",product_mtm="2076-524",serial_number="78ABCDE",wwnn="500507680B008CF9"} 1
+	# HELP spectrum_node_status Status of node
+	# TYPE spectrum_node_status gauge
+	spectrum_node_status{node_id="1",node_name="node1",status="adding"} 0
+	spectrum_node_status{node_id="1",node_name="node1",status="other"} 0
+	spectrum_node_status{node_id="1",node_name="node1",status="deleting"} 0
+	spectrum_node_status{node_id="1",node_name="node1",status="flushing"} 0
+	spectrum_node_status{node_id="1",node_name="node1",status="offline"} 0
+	spectrum_node_status{node_id="1",node_name="node1",status="online"} 1
+	spectrum_node_status{node_id="1",node_name="node1",status="pending"} 0
+	spectrum_node_status{node_id="1",node_name="node1",status="service"} 0
+	spectrum_node_status{node_id="2",node_name="node2",status="adding"} 0
+	spectrum_node_status{node_id="2",node_name="node2",status="other"} 0
+	spectrum_node_status{node_id="2",node_name="node2",status="deleting"} 0
+	spectrum_node_status{node_id="2",node_name="node2",status="flushing"} 0
+	spectrum_node_status{node_id="2",node_name="node2",status="offline"} 0
+	spectrum_node_status{node_id="2",node_name="node2",status="online"} 1
+	spectrum_node_status{node_id="2",node_name="node2",status="pending"} 0
+	spectrum_node_status{node_id="2",node_name="node2",status="service"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestDriveFirmware(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsdrive", "testdata/lsdrive.jsonnet")
+	c.prepare("rest/lsdrive/0", "testdata/lsdrive-0.jsonnet")
+	c.prepare("rest/lsdrive/1", "testdata/lsdrive-1.jsonnet")
+	c.prepare("rest/lsdrive/17", "testdata/lsdrive-17.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeDriveFirmware(c, r, &Options{}) {
+		t.Errorf("probeDriveFirmware() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_drive_firmware_info Number of drives running the given firmware level
+	# TYPE spectrum_drive_firmware_info gauge
+	spectrum_drive_firmware_info{firmware="B3C2",tech_type="tier_enterprise"} 1
+	spectrum_drive_firmware_info{firmware="B3D0",tech_type="tier_enterprise"} 2
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestPortStats(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsportstats", "testdata/lsportstats.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probePortStats(c, r, &Options{}) {
+		t.Errorf("probePortStats() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_fc_port_buffer_credit_zero_ratio Ratio of time the Fibre Channel port had zero buffer-to-buffer credits
+	# TYPE spectrum_fc_port_buffer_credit_zero_ratio gauge
+	spectrum_fc_port_buffer_credit_zero_ratio{node_id="1",node_name="node1",port_id="1"} 0
+	spectrum_fc_port_buffer_credit_zero_ratio{node_id="1",node_name="node1",port_id="2"} 0.03
+	spectrum_fc_port_buffer_credit_zero_ratio{node_id="2",node_name="node2",port_id="1"} 0
+	spectrum_fc_port_buffer_credit_zero_ratio{node_id="2",node_name="node2",port_id="2"} 0.03
+	# HELP spectrum_fc_port_busy_ratio Ratio of time the Fibre Channel port was busy
+	# TYPE spectrum_fc_port_busy_ratio gauge
+	spectrum_fc_port_busy_ratio{node_id="1",node_name="node1",port_id="1"} 0.12
+	spectrum_fc_port_busy_ratio{node_id="1",node_name="node1",port_id="2"} 0.4
+	spectrum_fc_port_busy_ratio{node_id="2",node_name="node2",port_id="1"} 0.12
+	spectrum_fc_port_busy_ratio{node_id="2",node_name="node2",port_id="2"} 0.4
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestPortStatsUnsupported(t *testing.T) {
+	c := newFakeClient()
+	c.fail("rest/lsportstats", &client.APIError{StatusCode: 500, Body: "CMMVC7205E The command failed because it is not supported."})
+	r := prometheus.NewPedanticRegistry()
+	if !probePortStats(c, r, &Options{}) {
+		t.Errorf("probePortStats() returned non-success for unsupported endpoint")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsdrive", "testdata/lsdrive.jsonnet")
+	c.prepare("rest/lsnodecanister", "testdata/lsnodecanister.jsonnet")
+	c.prepare("rest/lsnodecanister/1", "testdata/lsnodecanister-1.jsonnet")
+	c.prepare("rest/lsnodecanister/2", "testdata/lsnodecanister-2.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeDrives(c, r, &Options{}) {
+		t.Errorf("probeDrives() returned non-success")
+	}
+	if !probeNodeInfo(c, r, &Options{}) {
+		t.Errorf("probeNodeInfo() returned non-success")
+	}
+	if err := Health(r, r); err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+
+	// One of three drives is degraded, all nodes are online
+	em := `
+	# HELP spectrum_health_component_degraded Whether any object of the component is in an unhealthy state
+	# TYPE spectrum_health_component_degraded gauge
+	spectrum_health_component_degraded{component="drive"} 1
+	spectrum_health_component_degraded{component="node"} 0
+	# HELP spectrum_health_score Weighted health score of the target between 0 (all components unhealthy) and 100 (all healthy)
+	# TYPE spectrum_health_score gauge
+	spectrum_health_score 88.88888888888887
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_health_component_degraded", "spectrum_health_score"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	l := NewLifecycle()
+	for _, f := range []string{"lsdrive.jsonnet", "lsdrive.jsonnet", "lsdrive-replaced.jsonnet"} {
+		c := newFakeClient()
+		c.prepare("rest/lsdrive", "testdata/"+f)
+		r := prometheus.NewPedanticRegistry()
+		if !probeDrives(c, r, &Options{}) {
+			t.Errorf("probeDrives() returned non-success")
+		}
+		// Kinds missing from a probe keep their objects
+		if err := l.Update(prometheus.NewRegistry()); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if err := l.Update(r); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+
+	em := `
+	# HELP spectrum_object_created_total Number of objects that appeared on the target since the exporter started
+	# TYPE spectrum_object_created_total counter
+	spectrum_object_created_total{object="drive"} 1
+	# HELP spectrum_object_deleted_total Number of objects that disappeared from the target since the exporter started
+	# TYPE spectrum_object_deleted_total counter
+	spectrum_object_deleted_total{object="drive"} 1
+	`
+
+	if err := testutil.CollectAndCompare(l, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestStatusMetrics(t *testing.T) {
+	parseErrors := testutil.CollectAndCount(ParseErrors)
+	unknownEnums := testutil.CollectAndCount(UnknownEnums)
+	known := map[string]MetricInfo{}
+	for _, mi := range describeAll() {
+		known[mi.Name] = mi
+	}
+	if testutil.CollectAndCount(ParseErrors) != parseErrors || testutil.CollectAndCount(UnknownEnums) != unknownEnums {
+		t.Errorf("Describing the collectors counted parse errors or unknown enums")
+	}
+	if mi, ok := known["spectrum_drive_status"]; !ok || mi.Collector != "drive" || mi.Help != "Status of drive" || mi.Type != "gauge" {
+		t.Errorf("Unexpected metadata for spectrum_drive_status: %+v", mi)
+	}
+	if mi := known["spectrum_object_count"]; !reflect.DeepEqual(mi.Endpoints, []string{"rest/lssystem", "rest/lsvdisk", "rest/lshost", "rest/lshostvdiskmap", "rest/lsfcmap", "rest/lsrcrelationship"}) {
+		t.Errorf("Unexpected endpoints of spectrum_object_count: %v", mi.Endpoints)
+	}
+	for _, sm := range StatusMetrics {
+		mi, ok := known[sm.Metric]
+		if !ok {
+			t.Errorf("Status metric %q is not registered by any collector", sm.Metric)
+			continue
+		}
+		if mi.Labels[len(mi.Labels)-1] != sm.Label {
+			t.Errorf("Status metric %q has labels %v, expected %q last", sm.Metric, mi.Labels, sm.Label)
+		}
+	}
+}
+
+func TestCapacityWarning(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp-warning.jsonnet")
+	c.prepare("rest/lseventlog", "testdata/lseventlog.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeCapacityWarning(c, r, &Options{}) {
+		t.Errorf("probeCapacityWarning() returned non-success")
+	}
+	if q := c.queries["rest/lseventlog"]; q != "filtervalue=fixed%3Dno%3Aevent_id%3D060001" {
+		t.Errorf("Expected only the unfixed capacity warnings to be fetched, got query %q", q)
+	}
+
+	em := `
+	# HELP spectrum_capacity_warning Whether the system itself considers a capacity warning threshold exceeded, by source
+	# TYPE spectrum_capacity_warning gauge
+	spectrum_capacity_warning{source="event_log"} 1
+	spectrum_capacity_warning{source="pool_threshold"} 1
+	# HELP spectrum_pool_capacity_warning Whether the pool capacity in use exceeds the warning threshold of the pool
+	# TYPE spectrum_pool_capacity_warning gauge
+	spectrum_pool_capacity_warning{id="0",name="Pool0",site_id="",site_name=""} 0
+	spectrum_pool_capacity_warning{id="1",name="Pool1",site_id="",site_name=""} 1
+	spectrum_pool_capacity_warning{id="2",name="Pool2",site_id="",site_name=""} 0
+	# HELP spectrum_pool_capacity_warning_threshold_ratio Ratio of pool capacity in use at which the system raises a warning, 0 if disabled
+	# TYPE spectrum_pool_capacity_warning_threshold_ratio gauge
+	spectrum_pool_capacity_warning_threshold_ratio{id="0",name="Pool0",site_id="",site_name=""} 0.8
+	spectrum_pool_capacity_warning_threshold_ratio{id="1",name="Pool1",site_id="",site_name=""} 0.8
+	spectrum_pool_capacity_warning_threshold_ratio{id="2",name="Pool2",site_id="",site_name=""} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestPartnerships(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lspartnership", "testdata/lspartnership.jsonnet")
+	c.prepare("rest/lspartnership/00000200A2A0C1D4", "testdata/lspartnership-00000200A2A0C1D4.jsonnet")
+	c.prepare("rest/lspartnership/00000200A3B0D2E5", "testdata/lspartnership-00000200A3B0D2E5.jsonnet")
+	c.prepare("rest/lssystemstats", "testdata/lssystemstats-iplink.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probePartnerships(c, r, &Options{BytesPerMB: MBUnits["MB"]}) {
+		t.Errorf("probePartnerships() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_partnership_link_bandwidth_bps Configured bandwidth of the partnership link in bits per second
+	# TYPE spectrum_partnership_link_bandwidth_bps gauge
+	spectrum_partnership_link_bandwidth_bps{id="00000200A2A0C1D4",name="V7000-DR",type="ipv4"} 1e+08
+	spectrum_partnership_link_bandwidth_bps{id="00000200A3B0D2E5",name="V7000-FC",type="fc"} 8e+09
+	# HELP spectrum_partnership_link_utilization_ratio Ratio of the configured link bandwidth used by replication
+	# TYPE spectrum_partnership_link_utilization_ratio gauge
+	spectrum_partnership_link_utilization_ratio{id="00000200A2A0C1D4",name="V7000-DR",type="ipv4"} 0.48
+	# HELP spectrum_partnership_throughput_bps Replication throughput over the IP partnership links in bits per second
+	# TYPE spectrum_partnership_throughput_bps gauge
+	spectrum_partnership_throughput_bps{id="00000200A2A0C1D4",name="V7000-DR",type="ipv4"} 4.8e+07
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lssystemip", "testdata/lssystemip.jsonnet")
+	c.prepare("rest/lsroute", "testdata/lsroute.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeNetwork(c, r, &Options{}) {
+		t.Errorf("probeNetwork() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_management_gateway_configured Whether a default gateway is configured for the management IP address
+	# TYPE spectrum_management_gateway_configured gauge
+	spectrum_management_gateway_configured{family="ipv4",port_id="1"} 1
+	spectrum_management_gateway_configured{family="ipv6",port_id="2"} 0
+	# HELP spectrum_management_ip_info Management IP address configuration of the system, by Ethernet port
+	# TYPE spectrum_management_ip_info gauge
+	spectrum_management_ip_info{address="10.1.2.10",family="ipv4",gateway="10.1.2.1",port_id="1",prefix="255.255.255.0"} 1
+	spectrum_management_ip_info{address="fd00:7000::10",family="ipv6",gateway="",port_id="2",prefix="64"} 1
+	# HELP spectrum_management_route_info Routes of the management network of the configuration node
+	# TYPE spectrum_management_route_info gauge
+	spectrum_management_route_info{destination="0.0.0.0",gateway="10.1.2.1",genmask="0.0.0.0",interface="eth0"} 1
+	spectrum_management_route_info{destination="10.1.2.0",gateway="0.0.0.0",genmask="255.255.255.0",interface="eth0"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+
+	// A plain text routing table is counted as a parse error only
+	c.data["rest/lsroute"] = []byte("Kernel IP routing table\n")
+	parseErrors := testutil.ToFloat64(ParseErrors.WithLabelValues("network", "lsroute"))
+	if !probeNetwork(c, prometheus.NewPedanticRegistry(), &Options{}) {
+		t.Errorf("probeNetwork() returned non-success")
+	}
+	if testutil.ToFloat64(ParseErrors.WithLabelValues("network", "lsroute")) != parseErrors+1 {
+		t.Errorf("Expected a parse error to be counted")
+	}
+}
+
+func TestThrottles(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsthrottle", "testdata/lsthrottle.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeThrottles(c, r, &Options{}) {
+		t.Errorf("probeThrottles() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_throttle_bandwidth_limit_bytes_per_second Configured bandwidth limit of the throttle in bytes per second
+	# TYPE spectrum_throttle_bandwidth_limit_bytes_per_second gauge
+	spectrum_throttle_bandwidth_limit_bytes_per_second{id="1",name="backup_hosts",object_id="2",object_name="backup01",object_type="host"} 2.097152e+08
+	spectrum_throttle_bandwidth_limit_bytes_per_second{id="2",name="throttle2",object_id="0",object_name="Pool0",object_type="mdiskgrp"} 1.048576e+09
+	# HELP spectrum_throttle_iops_limit Configured I/O operations per second limit of the throttle
+	# TYPE spectrum_throttle_iops_limit gauge
+	spectrum_throttle_iops_limit{id="0",name="throttle0",object_id="3",object_name="db01_data",object_type="vdisk"} 5000
+	spectrum_throttle_iops_limit{id="2",name="throttle2",object_id="0",object_name="Pool0",object_type="mdiskgrp"} 20000
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestVolumeCopies(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsvdiskcopy", "testdata/lsvdiskcopy.jsonnet")
+	c.prepare("rest/lsvdisksyncprogress", "testdata/lsvdisksyncprogress.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeVolumeCopies(c, r, &Options{}) {
+		t.Errorf("probeVolumeCopies() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_volume_copies Number of volume copies by synchronization state
+	# TYPE spectrum_volume_copies gauge
+	spectrum_volume_copies{sync="no"} 2
+	spectrum_volume_copies{sync="yes"} 4
+	# HELP spectrum_volume_copy_sync_estimated_completion_timestamp_seconds Estimated completion time of the last volume copy synchronization to complete
+	# TYPE spectrum_volume_copy_sync_estimated_completion_timestamp_seconds gauge
+	spectrum_volume_copy_sync_estimated_completion_timestamp_seconds 1.604083512e+09
+	# HELP spectrum_volume_copy_sync_progress_min_ratio Synchronization progress of the least synchronized volume copy, 1 if all are synchronized
+	# TYPE spectrum_volume_copy_sync_progress_min_ratio gauge
+	spectrum_volume_copy_sync_progress_min_ratio 0.37
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestVolumeCopyDetails(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsvdiskcopy", "testdata/lsvdiskcopy-thin.jsonnet")
+	c.prepare("rest/lssevdiskcopy", "testdata/lssevdiskcopy.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeVolumeCopyDetails(c, r, &Options{}) {
+		t.Errorf("probeVolumeCopyDetails() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_volume_copy_autoexpand Whether the real capacity of thin-provisioned or compressed volume copy expands automatically
+	# TYPE spectrum_volume_copy_autoexpand gauge
+	spectrum_volume_copy_autoexpand{copy_id="1",volume_id="1",volume_name="vdisk1"} 1
+	spectrum_volume_copy_autoexpand{copy_id="1",volume_id="2",volume_name="vdisk2"} 0
+	# HELP spectrum_volume_copy_primary Whether volume copy is the primary copy, the one reads are served from
+	# TYPE spectrum_volume_copy_primary gauge
+	spectrum_volume_copy_primary{copy_id="0",volume_id="0",volume_name="vdisk0"} 1
+	spectrum_volume_copy_primary{copy_id="0",volume_id="1",volume_name="vdisk1"} 1
+	spectrum_volume_copy_primary{copy_id="0",volume_id="2",volume_name="vdisk2"} 1
+	spectrum_volume_copy_primary{copy_id="1",volume_id="0",volume_name="vdisk0"} 0
+	spectrum_volume_copy_primary{copy_id="1",volume_id="1",volume_name="vdisk1"} 0
+	spectrum_volume_copy_primary{copy_id="1",volume_id="2",volume_name="vdisk2"} 0
+	# HELP spectrum_volume_copy_real_capacity_bytes Capacity allocated to volume copy in bytes, the full capacity unless thin-provisioned or compressed
+	# TYPE spectrum_volume_copy_real_capacity_bytes gauge
+	spectrum_volume_copy_real_capacity_bytes{copy_id="0",volume_id="0",volume_name="vdisk0"} 1.073741824e+11
+	spectrum_volume_copy_real_capacity_bytes{copy_id="0",volume_id="1",volume_name="vdisk1"} 1.073741824e+11
+	spectrum_volume_copy_real_capacity_bytes{copy_id="0",volume_id="2",volume_name="vdisk2"} 1.073741824e+11
+	spectrum_volume_copy_real_capacity_bytes{copy_id="1",volume_id="0",volume_name="vdisk0"} 1.073741824e+11
+	spectrum_volume_copy_real_capacity_bytes{copy_id="1",volume_id="1",volume_name="vdisk1"} 2.3622320128e+10
+	spectrum_volume_copy_real_capacity_bytes{copy_id="1",volume_id="2",volume_name="vdisk2"} 3.221225472e+09
+	# HELP spectrum_volume_copy_synchronized Whether volume copy is synchronized
+	# TYPE spectrum_volume_copy_synchronized gauge
+	spectrum_volume_copy_synchronized{copy_id="0",volume_id="0",volume_name="vdisk0"} 1
+	spectrum_volume_copy_synchronized{copy_id="0",volume_id="1",volume_name="vdisk1"} 1
+	spectrum_volume_copy_synchronized{copy_id="0",volume_id="2",volume_name="vdisk2"} 1
+	spectrum_volume_copy_synchronized{copy_id="1",volume_id="0",volume_name="vdisk0"} 1
+	spectrum_volume_copy_synchronized{copy_id="1",volume_id="1",volume_name="vdisk1"} 0
+	spectrum_volume_copy_synchronized{copy_id="1",volume_id="2",volume_name="vdisk2"} 0
+	# HELP spectrum_volume_copy_used_capacity_bytes Capacity used by the data of volume copy in bytes
+	# TYPE spectrum_volume_copy_used_capacity_bytes gauge
+	spectrum_volume_copy_used_capacity_bytes{copy_id="0",volume_id="0",volume_name="vdisk0"} 1.073741824e+11
+	spectrum_volume_copy_used_capacity_bytes{copy_id="0",volume_id="1",volume_name="vdisk1"} 1.073741824e+11
+	spectrum_volume_copy_used_capacity_bytes{copy_id="0",volume_id="2",volume_name="vdisk2"} 1.073741824e+11
+	spectrum_volume_copy_used_capacity_bytes{copy_id="1",volume_id="0",volume_name="vdisk0"} 1.073741824e+11
+	spectrum_volume_copy_used_capacity_bytes{copy_id="1",volume_id="1",volume_name="vdisk1"} 2.147483648e+10
+	spectrum_volume_copy_used_capacity_bytes{copy_id="1",volume_id="2",volume_name="vdisk2"} 1.073741824e+09
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsmigrate", "testdata/lsmigrate.jsonnet")
+	c.prepare("rest/lsvdisk", "testdata/lsvdisk.jsonnet")
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp-hyperswap.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeMigrations(c, r, &Options{}) {
+		t.Errorf("probeMigrations() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_migration_progress_ratio Progress of a running volume migration
+	# TYPE spectrum_migration_progress_ratio gauge
+	spectrum_migration_progress_ratio{copy_id="0",target_pool_id="1",target_pool_name="Pool1",type="MDisk_Group_Migration",volume_id="1",volume_name="esx-ds02"} 0.96
+	spectrum_migration_progress_ratio{copy_id="1",target_pool_id="1",target_pool_name="Pool1",type="MDisk_Group_Migration",volume_id="2",volume_name="sql-data"} 0.12
+	# HELP spectrum_migrations Number of running volume migrations by type
+	# TYPE spectrum_migrations gauge
+	spectrum_migrations{type="MDisk_Extents_Migration"} 0
+	spectrum_migrations{type="MDisk_Group_Migration"} 2
+	spectrum_migrations{type="Migrate_to_Image_Mode"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestNoMigrations(t *testing.T) {
+	c := newFakeClient()
+	c.data["rest/lsmigrate"] = []byte("[]")
+	r := prometheus.NewPedanticRegistry()
+	if !probeMigrations(c, r, &Options{}) {
+		t.Errorf("probeMigrations() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_migrations Number of running volume migrations by type
+	# TYPE spectrum_migrations gauge
+	spectrum_migrations{type="MDisk_Extents_Migration"} 0
+	spectrum_migrations{type="MDisk_Group_Migration"} 0
+	spectrum_migrations{type="Migrate_to_Image_Mode"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestNodeHardware(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsnodehw", "testdata/lsnodehw.jsonnet")
+	c.prepareJSON("rest/lsnodehw/1", "testdata/lsnodehw-1.json")
+	c.prepareJSON("rest/lsnodehw/2", "testdata/lsnodehw-2.json")
+	r := prometheus.NewPedanticRegistry()
+	if !probeNodeHardware(c, r, &Options{}) {
+		t.Errorf("probeNodeHardware() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_node_adapter_info Configured and installed adapter of a node slot, empty if none
+	# TYPE spectrum_node_adapter_info gauge
+	spectrum_node_adapter_info{actual="",configured="Compression Acceleration adapter",location="2",node_id="2",node_name="node2"} 1
+	spectrum_node_adapter_info{actual="",configured="Four port 16Gb/s Fibre Channel adapter",location="1",node_id="2",node_name="node2"} 1
+	spectrum_node_adapter_info{actual="Compression Acceleration adapter",configured="Compression Acceleration adapter",location="2",node_id="1",node_name="node1"} 1
+	spectrum_node_adapter_info{actual="Four port 10Gb/s Ethernet adapter",configured="Four port 10Gb/s Ethernet adapter",location="0",node_id="1",node_name="node1"} 1
+	spectrum_node_adapter_info{actual="Four port 10Gb/s Ethernet adapter",configured="Four port 10Gb/s Ethernet adapter",location="0",node_id="2",node_name="node2"} 1
+	spectrum_node_adapter_info{actual="Four port 16Gb/s Fibre Channel adapter",configured="Four port 16Gb/s Fibre Channel adapter",location="1",node_id="1",node_name="node1"} 1
+	# HELP spectrum_node_adapter_valid Whether the adapter installed in a node slot matches the configured one
+	# TYPE spectrum_node_adapter_valid gauge
+	spectrum_node_adapter_valid{location="0",node_id="1",node_name="node1"} 1
+	spectrum_node_adapter_valid{location="0",node_id="2",node_name="node2"} 1
+	spectrum_node_adapter_valid{location="1",node_id="1",node_name="node1"} 1
+	spectrum_node_adapter_valid{location="1",node_id="2",node_name="node2"} 0
+	spectrum_node_adapter_valid{location="2",node_id="1",node_name="node1"} 1
+	spectrum_node_adapter_valid{location="2",node_id="2",node_name="node2"} 0
+	# HELP spectrum_node_compression_accelerator_valid Whether the compression accelerator installed matches the configured one
+	# TYPE spectrum_node_compression_accelerator_valid gauge
+	spectrum_node_compression_accelerator_valid{location="2",node_id="1",node_name="node1"} 1
+	spectrum_node_compression_accelerator_valid{location="2",node_id="2",node_name="node2"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestNodeCanisterHardware(t *testing.T) {
+	c := newFakeClient()
+	c.fail("rest/lsnodehw", &client.APIError{StatusCode: 404})
+	c.prepare("rest/lsnodecanisterhw", "testdata/lsnodehw.jsonnet")
+	c.prepareJSON("rest/lsnodecanisterhw/1", "testdata/lsnodehw-1.json")
+	c.prepareJSON("rest/lsnodecanisterhw/2", "testdata/lsnodehw-2.json")
+	r := prometheus.NewPedanticRegistry()
+	if !probeNodeHardware(c, r, &Options{}) {
+		t.Errorf("probeNodeHardware() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_node_compression_accelerator_valid Whether the compression accelerator installed matches the configured one
+	# TYPE spectrum_node_compression_accelerator_valid gauge
+	spectrum_node_compression_accelerator_valid{location="2",node_id="1",node_name="node1"} 1
+	spectrum_node_compression_accelerator_valid{location="2",node_id="2",node_name="node2"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_node_compression_accelerator_valid"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestUnknownEnum(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsdrive", "testdata/lsdrive-unknown.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeDrives(c, r, &Options{}) {
+		t.Errorf("probeDrives() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_drive_status Status of drive
+	# TYPE spectrum_drive_status gauge
+	spectrum_drive_status{enclosure="1",id="0",slot_id="5",status="degraded"} 0
+	spectrum_drive_status{enclosure="1",id="0",slot_id="5",status="offline"} 0
+	spectrum_drive_status{enclosure="1",id="0",slot_id="5",status="online"} 0
+	spectrum_drive_status{enclosure="1",id="0",slot_id="5",status="other"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+	if v := testutil.ToFloat64(UnknownEnums.WithLabelValues("drive", "status", "rebuilding")); v != 1 {
+		t.Errorf("Expected 1 unknown drive status, got %v", v)
+	}
+}
+
+func TestUnknownEnumOverflow(t *testing.T) {
+	for i := 0; i < maxUnknownEnumValues+5; i++ {
+		countUnknownEnum("overflow_test", "status", "state"+strconv.Itoa(i))
+	}
+	countUnknownEnum("overflow_test", "status", strings.Repeat("x", 100))
+	if v := testutil.ToFloat64(UnknownEnums.WithLabelValues("overflow_test", "status", overflowEnumValue)); v != 6 {
+		t.Errorf("Expected 6 values counted as overflow, got %v", v)
+	}
+	// Values already seen keep their own series
+	countUnknownEnum("overflow_test", "status", "state0")
+	if v := testutil.ToFloat64(UnknownEnums.WithLabelValues("overflow_test", "status", "state0")); v != 2 {
+		t.Errorf("Expected state0 to be counted twice, got %v", v)
+	}
+}
+
+func TestMetricFilter(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	opts := &Options{
+		Only: []string{"pool"},
+		Metrics: map[string]*config.MetricFilter{
+			"pool": {
+				Allow: []string{"spectrum_pool_status", "spectrum_pool_volume_count"},
+				Deny:  []string{"spectrum_pool_status"},
+			},
+		},
+	}
+	if !Probe(c, r, opts) {
+		t.Errorf("Probe() returned non-success")
+	}
+
+	// The series dropped by the filter are not counted
+	em := `
+	# HELP spectrum_collector_series_emitted Number of series exported by a collector in this probe, after the metric filters
+	# TYPE spectrum_collector_series_emitted gauge
+	spectrum_collector_series_emitted{collector="pool"} 1
+	# HELP spectrum_pool_volume_count Number of volumes associated with pool
+	# TYPE spectrum_pool_volume_count gauge
+	spectrum_pool_volume_count{id="0",name="Pool0",site_id="",site_name=""} 44
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestFilterRegisterer(t *testing.T) {
+	r := prometheus.NewPedanticRegistry()
+	fr := &filterRegisterer{Registerer: r, filter: &config.MetricFilter{Deny: []string{"spectrum_test_denied", "spectrum_test_gauge_denied"}}}
+	// The name of a gaugeVec is known without parsing its description
+	fr.MustRegister(
+		newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_test_denied", Help: `Odd help"}, variableLabels: []}`}, nil),
+		newGaugeVec(prometheus.GaugeOpts{Name: "spectrum_test_allowed", Help: "Allowed"}, nil),
+		prometheus.NewGauge(prometheus.GaugeOpts{Name: "spectrum_test_gauge_denied", Help: "Denied"}),
+	)
+	for name, registered := range map[string]bool{
+		"spectrum_test_denied":       false,
+		"spectrum_test_allowed":      true,
+		"spectrum_test_gauge_denied": false,
+	} {
+		err := r.Register(prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: "Again"}))
+		if (err != nil) != registered {
+			t.Errorf("%s: expected registered %v, got error %v", name, registered, err)
+		}
+	}
+}
+
+func TestNPIV(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsiogrp", "testdata/lsiogrp.jsonnet")
+	c.prepare("rest/lsiogrp/0", "testdata/lsiogrp-0.jsonnet")
+	c.prepare("rest/lsiogrp/1", "testdata/lsiogrp-1.jsonnet")
+	c.prepare("rest/lstargetportfc", "testdata/lstargetportfc.jsonnet")
+	c.prepare("rest/lsnodecanister", "testdata/lsnodecanister.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeNPIV(c, r, &Options{}) {
+		t.Errorf("probeNPIV() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_iogrp_fc_target_port_mode NPIV target port mode of an I/O group, changing it moves the host paths between the physical and virtual ports
+	# TYPE spectrum_iogrp_fc_target_port_mode gauge
+	spectrum_iogrp_fc_target_port_mode{iogrp_id="0",iogrp_name="io_grp0",mode="disabled"} 0
+	spectrum_iogrp_fc_target_port_mode{iogrp_id="0",iogrp_name="io_grp0",mode="enabled"} 1
+	spectrum_iogrp_fc_target_port_mode{iogrp_id="0",iogrp_name="io_grp0",mode="other"} 0
+	spectrum_iogrp_fc_target_port_mode{iogrp_id="0",iogrp_name="io_grp0",mode="transitional"} 0
+	spectrum_iogrp_fc_target_port_mode{iogrp_id="1",iogrp_name="io_grp1",mode="disabled"} 1
+	spectrum_iogrp_fc_target_port_mode{iogrp_id="1",iogrp_name="io_grp1",mode="enabled"} 0
+	spectrum_iogrp_fc_target_port_mode{iogrp_id="1",iogrp_name="io_grp1",mode="other"} 0
+	spectrum_iogrp_fc_target_port_mode{iogrp_id="1",iogrp_name="io_grp1",mode="transitional"} 0
+	# HELP spectrum_node_fc_target_ports Number of FC target ports owned by a node
+	# TYPE spectrum_node_fc_target_ports gauge
+	spectrum_node_fc_target_ports{host_io_permitted="no",node_id="1",node_name="node1",virtualized="no"} 2
+	spectrum_node_fc_target_ports{host_io_permitted="no",node_id="2",node_name="node2",virtualized="no"} 1
+	spectrum_node_fc_target_ports{host_io_permitted="yes",node_id="1",node_name="node1",virtualized="yes"} 2
+	spectrum_node_fc_target_ports{host_io_permitted="yes",node_id="2",node_name="node2",virtualized="no"} 2
+	spectrum_node_fc_target_ports{host_io_permitted="yes",node_id="2",node_name="node2",virtualized="yes"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestSystemStats(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lssystemstats", "testdata/lssystemstats.jsonnet")
+	r := prometheus.NewPedanticRegistry()
+	if !probeSystemStats(c, r, &Options{}) {
+		t.Errorf("probeSystemStats() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_system_latency_seconds Current average latency of the system by layer, vdisk (front-end), mdisk (back-end) or drive
+	# TYPE spectrum_system_latency_seconds gauge
+	spectrum_system_latency_seconds{layer="drive",op="read"} 0.005
+	spectrum_system_latency_seconds{layer="drive",op="write"} 0
+	spectrum_system_latency_seconds{layer="mdisk",op="read"} 0.005
+	spectrum_system_latency_seconds{layer="mdisk",op="write"} 0
+	spectrum_system_latency_seconds{layer="vdisk",op="read"} 0.001
+	spectrum_system_latency_seconds{layer="vdisk",op="write"} 0
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+func TestOptionalCollectors(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp.jsonnet")
+	c.prepare("rest/lsmigrate", "testdata/lsmigrate.jsonnet")
+	c.prepare("rest/lsvdisk", "testdata/lsvdisk.jsonnet")
+	opts := &Options{
+		Only:         []string{"migration", "pool"},
+		Optional:     []string{"migration"},
+		Deadline:     timeNow().Add(time.Minute),
+		MinRemaining: 5 * time.Second,
+	}
+
+	r := prometheus.NewPedanticRegistry()
+	if !Probe(c, r, opts) {
+		t.Errorf("Probe() returned non-success")
+	}
+	if n, err := testutil.GatherAndCount(r, "spectrum_migrations"); err != nil || n == 0 {
+		t.Errorf("Optional collector did not run with time left: %d series, %v", n, err)
+	}
+
+	// With less than MinRemaining left only the critical collectors run
+	opts.Deadline = timeNow().Add(time.Second)
+	r = prometheus.NewPedanticRegistry()
+	if !Probe(c, r, opts) {
+		t.Errorf("Probe() returned non-success")
+	}
+	em := `
+	# HELP spectrum_collector_skipped Whether an optional collector was skipped as the probe was running out of time
+	# TYPE spectrum_collector_skipped gauge
+	spectrum_collector_skipped{collector="migration"} 1
+	`
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_collector_skipped", "spectrum_migrations"); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+	if n, err := testutil.GatherAndCount(r, "spectrum_pool_status"); err != nil || n == 0 {
+		t.Errorf("Critical collector did not run: %d series, %v", n, err)
+	}
+}
+
+func TestUnsupportedCollectors(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp.jsonnet")
+	c.fail("rest/lsportip", &client.APIError{StatusCode: 500, Body: "CMMVC7205E The command failed because it is not supported."})
+	opts := &Options{Only: []string{"pool", "ip_port"}}
+
+	// Without anywhere to remember it the probe fails as before
+	if Probe(c, prometheus.NewPedanticRegistry(), opts) {
+		t.Errorf("Probe() succeeded without Unsupported")
+	}
+
+	opts.Unsupported = &UnsupportedCollectors{}
+	for i := 0; i < 2; i++ {
+		r := prometheus.NewPedanticRegistry()
+		if !Probe(c, r, opts) {
+			t.Fatalf("Probe() %d returned non-success", i)
+		}
+		em := `
+		# HELP spectrum_collector_unsupported Whether a collector is skipped as the target does not support an endpoint it needs
+		# TYPE spectrum_collector_unsupported gauge
+		spectrum_collector_unsupported{collector="ip_port"} 1
+		`
+		if err := testutil.GatherAndCompare(r, strings.NewReader(em), "spectrum_collector_unsupported", "spectrum_ip_port_state"); err != nil {
+			t.Fatalf("metric compare: err %v", err)
+		}
+		if n, err := testutil.GatherAndCount(r, "spectrum_pool_status"); err != nil || n == 0 {
+			t.Errorf("Supported collector did not run: %d series, %v", n, err)
+		}
+		// The skipped collector must not call the endpoint again
+		c.fail("rest/lsportip", &client.APIError{StatusCode: 500, Body: "CMMVC5786E The action failed because the cluster is not in a stable state."})
+	}
+
+	// Other errors still fail the probe once the collector runs again
+	opts.Unsupported.Reset()
+	if Probe(c, prometheus.NewPedanticRegistry(), opts) {
+		t.Errorf("Probe() succeeded after Reset")
+	}
+}
+
+func TestUnsupportedDeletedObject(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lshost", "testdata/lshost.jsonnet")
+	c.fail("rest/lshost/2", &client.APIError{StatusCode: 404})
+	c.prepare("rest/lshost/3", "testdata/lshost-fc.jsonnet")
+	opts := &Options{Only: []string{"host_port"}, Enable: []string{"host_port"}, Unsupported: &UnsupportedCollectors{}}
+
+	// A host deleted between listing and looking at it fails the probe,
+	// but does not make the collector unsupported
+	if Probe(c, prometheus.NewPedanticRegistry(), opts) {
+		t.Errorf("Probe() succeeded with a missing host")
+	}
+	if opts.Unsupported.has("host_port") {
+		t.Errorf("Collector marked unsupported on a missing object")
+	}
+
+	c.fail("rest/lshost/2", &client.APIError{StatusCode: 500, Body: "CMMVC7205E The command failed because it is not supported."})
+	if !Probe(c, prometheus.NewPedanticRegistry(), opts) || !opts.Unsupported.has("host_port") {
+		t.Errorf("Collector not marked unsupported on CMMVC7205E")
+	}
+}
+
+func TestPoolTiers(t *testing.T) {
+	c := newFakeClient()
+	c.prepare("rest/lsmdiskgrp", "testdata/lsmdiskgrp.jsonnet")
+	c.prepareJSON("rest/lsmdiskgrp/0", "testdata/lsmdiskgrp-0.json")
+	r := prometheus.NewPedanticRegistry()
+	if !probePoolTiers(c, r, &Options{}) {
+		t.Errorf("probePoolTiers() returned non-success")
+	}
+
+	em := `
+	# HELP spectrum_pool_tier_capacity_bytes Capacity of a storage tier of the pool
+	# TYPE spectrum_pool_tier_capacity_bytes gauge
+	spectrum_pool_tier_capacity_bytes{id="0",name="Pool0",tier="tier0_flash"} 1.0709243254538e+13
+	# HELP spectrum_pool_tier_free_bytes Free capacity of a storage tier of the pool
+	# TYPE spectrum_pool_tier_free_bytes gauge
+	spectrum_pool_tier_free_bytes{id="0",name="Pool0",tier="tier0_flash"} 9.829633952317e+12
+	# HELP spectrum_pool_tier_mdisks Number of MDisks in a storage tier of the pool
+	# TYPE spectrum_pool_tier_mdisks gauge
+	spectrum_pool_tier_mdisks{id="0",name="Pool0",tier="tier0_flash"} 1
+	`
+
+	if err := testutil.GatherAndCompare(r, strings.NewReader(em)); err != nil {
+		t.Fatalf("metric compare: err %v", err)
+	}
+}
+
+// countingClient counts the requests per path
+type countingClient struct {
+	*fakeClient
+	calls map[string]int
+}
+
+func (c *countingClient) GetEach(path string, query string, obj interface{}, fn func()) error {
+	c.calls[path]++
+	return c.fakeClient.GetEach(path, query, obj, fn)
+}
+
+func TestObjectNames(t *testing.T) {
+	c := &countingClient{fakeClient: newFakeClient(), calls: map[string]int{}}
+	c.prepare("rest/lsvdisk", "testdata/lsvdisk.jsonnet")
+	c.prepare("rest/lsnodecanister", "testdata/lsnodecanister.jsonnet")
+	opts := &Options{}
+
+	for id, name := range map[string]string{"0": "esx-ds01", "2": "sql-data", "99": ""} {
+		if n := opts.objectName(c, "volume", id); n != name {
+			t.Errorf("Expected volume %s to be named %q, got %q", id, name, n)
+		}
+	}
+	if n := opts.nodeName(c, "2", ""); n != "node2" {
+		t.Errorf("Expected node 2 to be named node2, got %q", n)
+	}
+	if n := opts.nodeName(c, "2", "reported"); n != "reported" {
+		t.Errorf("Expected the reported node name to be kept, got %q", n)
+	}
+	for path, n := range c.calls {
+		if n != 1 {
+			t.Errorf("Expected %s to be listed once, got %d", path, n)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Enumerated status metrics of the collectors
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StatusMetric describes a one-hot status metric, i.e. a metric with one
+// series per known state of which the one matching the object is set to 1.
+type StatusMetric struct {
+	// Object is the kind of object, e.g. "drive"
+	Object string
+	Metric string
+	// Label is the label carrying the state
+	Label  string
+	States []string
+	// Healthy lists the states that are not a sign of trouble
+	Healthy []string
+}
+
+// Unhealthy returns the states not listed as healthy
+func (sm StatusMetric) Unhealthy() []string {
+	var r []string
+	for _, s := range sm.States {
+		healthy := false
+		for _, h := range sm.Healthy {
+			if s == h {
+				healthy = true
+			}
+		}
+		if !healthy {
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+// The known values of the enumerations exported as one-hot series. Values
+// not listed here are exported in the "other" series, see setOneHot.
+var (
+	nodeStatuses      = []string{"online", "offline", "service", "pending", "adding", "flushing", "deleting"}
+	poolStatuses      = []string{"online", "offline"}
+	driveStatuses     = []string{"online", "offline", "degraded"}
+	psuStatuses       = []string{"online", "offline", "degraded"}
+	psuInputs         = []string{"ac", "dc", "failed"}
+	fanModuleStatuses = []string{"online", "offline", "degraded"}
+	fcPortStatuses    = []string{"active", "inactive_unconfigured", "inactive_configured"}
+	fcPortAttachments = []string{"switch", "direct", "none"}
+	ipPortStates      = []string{"configured", "unconfigured", "management_only"}
+	ipPortDuplexes    = []string{"full", "half"}
+	volumeStatuses    = []string{"online", "offline", "degraded"}
+	keyserverStatuses = []string{"online", "offline"}
+	hostStatuses      = []string{"online", "offline", "degraded"}
+	// A host cluster is host_degraded if any of its hosts is degraded or
+	// offline, and host_cluster_degraded if its members disagree on the
+	// shared mappings
+	hostClusterStatuses = []string{"online", "host_degraded", "host_cluster_degraded", "offline"}
+	// An inactive host port is logged in but had no I/O for five minutes
+	hostPortStates = []string{"active", "inactive", "degraded", "offline"}
+	// A disabled VASA provider is not used for vVols and thus healthy
+	vasaProviderStatuses = []string{"online", "offline", "disabled"}
+	npivModes            = []string{"enabled", "transitional", "disabled"}
+	easyTierModes        = []string{"on", "off", "auto", "measure", "balanced"}
+	easyTierStatuses     = []string{"active", "inactive", "measured", "balanced"}
+)
+
+// otherState is the state of the series set for values not in the known
+// enumeration
+const otherState = "other"
+
+// StatusMetrics lists the status metrics exported by the collectors
+var StatusMetrics = []StatusMetric{
+	{Object: "node", Metric: "spectrum_node_status", Label: "status", States: withOther(nodeStatuses), Healthy: []string{"online"}},
+	{Object: "pool", Metric: "spectrum_pool_status", Label: "status", States: withOther(poolStatuses), Healthy: []string{"online"}},
+	{Object: "drive", Metric: "spectrum_drive_status", Label: "status", States: withOther(driveStatuses), Healthy: []string{"online"}},
+	{Object: "psu", Metric: "spectrum_psu_status", Label: "status", States: withOther(psuStatuses), Healthy: []string{"online"}},
+	{Object: "fan_module", Metric: "spectrum_fan_module_status", Label: "status", States: withOther(fanModuleStatuses), Healthy: []string{"online"}},
+	// Unconfigured ports are unused and not a sign of trouble
+	{Object: "fc_port", Metric: "spectrum_fc_port_status", Label: "status", States: withOther(fcPortStatuses), Healthy: []string{"active", "inactive_unconfigured"}},
+	{Object: "keyserver", Metric: "spectrum_keyserver_status", Label: "status", States: withOther(keyserverStatuses), Healthy: []string{"online"}},
+	{Object: "vasa_provider", Metric: "spectrum_vasa_provider_status", Label: "status", States: withOther(vasaProviderStatuses), Healthy: []string{"online", "disabled"}},
+	{Object: "host_port", Metric: "spectrum_host_port_login_status", Label: "state", States: withOther(hostPortStates), Healthy: []string{"active", "inactive"}},
+	// Half duplex is a failed auto-negotiation with the switch port
+	{Object: "ip_port", Metric: "spectrum_ip_port_duplex", Label: "duplex", States: withOther(ipPortDuplexes), Healthy: []string{"full"}},
+	{Object: "volume", Metric: "spectrum_volume_status", Label: "status", States: withOther(volumeStatuses), Healthy: []string{"online"}},
+	{Object: "host", Metric: "spectrum_host_status", Label: "status", States: withOther(hostStatuses), Healthy: []string{"online"}},
+	{Object: "host_cluster", Metric: "spectrum_host_cluster_status", Label: "status", States: withOther(hostClusterStatuses), Healthy: []string{"online"}},
+}
+
+func withOther(states []string) []string {
+	return append(append([]string{}, states...), otherState)
+}
+
+// UnknownEnums counts enumeration values not known to the exporter. Like
+// ParseErrors it is not registered by default.
+var UnknownEnums = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "spectrum_unknown_enum_total",
+		Help: "Number of enumeration values returned by the API that are not known to the exporter",
+	},
+	[]string{"collector", "field", "value"},
+)
+
+const (
+	// maxUnknownEnumValues is the number of distinct unknown values counted
+	// per collector and field, any further values are counted as
+	// overflowEnumValue to bound the series of UnknownEnums
+	maxUnknownEnumValues = 10
+	overflowEnumValue    = "overflow"
+	// maxEnumValueLen is the length unknown values are truncated to
+	maxEnumValueLen = 32
+)
+
+var (
+	unknownEnumsMu     sync.Mutex
+	unknownEnumsValues = map[[2]string]map[string]bool{}
+)
+
+// countUnknownEnum counts value in UnknownEnums, truncated and folded into
+// overflowEnumValue once the field has too many distinct unknown values
+func countUnknownEnum(collector string, field string, value string) {
+	if len(value) > maxEnumValueLen {
+		// A cut multi-byte character would not be a valid label value
+		value = strings.ToValidUTF8(value[:maxEnumValueLen], "")
+	}
+	unknownEnumsMu.Lock()
+	key := [2]string{collector, field}
+	seen, ok := unknownEnumsValues[key]
+	if !ok {
+		seen = map[string]bool{}
+		unknownEnumsValues[key] = seen
+	}
+	if !seen[value] {
+		if len(seen) < maxUnknownEnumValues {
+			seen[value] = true
+		} else {
+			value = overflowEnumValue
+		}
+	}
+	unknownEnumsMu.Unlock()
+	UnknownEnums.WithLabelValues(collector, field, value).Inc()
+}
+
+// setOneHot sets the series of g for each known state to 1 if it matches
+// value and 0 otherwise. The state label is the last label of g and
+// labels are the values of the others. Unknown values set the "other"
+// series and are counted in UnknownEnums.
+func setOneHot(g *gaugeVec, collector string, field string, states []string, value string, labels ...string) {
+	known := false
+	for _, st := range states {
+		v := 0.0
+		if value == st {
+			v = 1.0
+			known = true
+		}
+		g.WithLabelValues(append(labels, st)...).Set(v)
+	}
+	other := 0.0
+	if !known {
+		other = 1.0
+		log.Printf("Unknown %s %s %q", collector, field, value)
+		countUnknownEnum(collector, field, value)
+	}
+	g.WithLabelValues(append(labels, otherState)...).Set(other)
+}
+
+func statusMetric(metric string) (StatusMetric, bool) {
+	for _, sm := range StatusMetrics {
+		if sm.Metric == metric {
+			return sm, true
+		}
+	}
+	return StatusMetric{}, false
+}
@@ -0,0 +1,92 @@
+// Collectors skipped for endpoints the target does not support
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package collectors
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bluecmd/spectrum_virtualize_exporter/client"
+)
+
+// UnsupportedCollectors remembers the collectors that failed on an endpoint
+// not supported by a target, to skip them in the later probes of the
+// target. The zero value is empty and ready to use.
+type UnsupportedCollectors struct {
+	mu    sync.Mutex
+	names map[string]bool
+}
+
+func (u *UnsupportedCollectors) has(name string) bool {
+	if u == nil {
+		return false
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.names[name]
+}
+
+func (u *UnsupportedCollectors) add(name string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.names == nil {
+		u.names = map[string]bool{}
+	}
+	u.names[name] = true
+}
+
+// Reset forgets the unsupported collectors, e.g. after a firmware upgrade
+func (u *UnsupportedCollectors) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.names = nil
+}
+
+// unsupportedClient tells whether any command failed as unsupported
+type unsupportedClient struct {
+	client.SpectrumHTTP
+	unsupported bool
+}
+
+func (c *unsupportedClient) check(path string, err error) error {
+	if !client.IsUnsupported(err) {
+		return err
+	}
+	// The details of an object, e.g. rest/lshost/5, are not found when the
+	// object is deleted after listing it, which says nothing about the
+	// command. Only the device rejecting the command counts then.
+	var ae *client.APIError
+	if strings.Count(path, "/") > 1 && errors.As(err, &ae) && ae.StatusCode == http.StatusNotFound {
+		return err
+	}
+	c.unsupported = true
+	return err
+}
+
+func (c *unsupportedClient) Get(path string, query string, obj interface{}) error {
+	return c.check(path, c.SpectrumHTTP.Get(path, query, obj))
+}
+
+func (c *unsupportedClient) GetEach(path string, query string, obj interface{}, fn func()) error {
+	return c.check(path, c.SpectrumHTTP.GetEach(path, query, obj, fn))
+}
@@ -0,0 +1,366 @@
+// Configuration of spectrum_virtualize_exporter
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	metricNameRE = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelNameRE  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	headerNameRE = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+)
+
+// Auth holds the credentials used to connect to a device
+type Auth struct {
+	User     string
+	Password string
+	// PasswordFile is read on every probe instead of using Password
+	PasswordFile string `yaml:"password_file"`
+	Token        string
+	// Backend is either "rest" (the default), "cim" or "storage_insights"
+	Backend string
+	// StorageInsights locates the device in IBM Storage Insights, required
+	// by the storage_insights backend
+	StorageInsights *StorageInsights `yaml:"storage_insights"`
+	// UserFormat, e.g. {user}@example.com, and the header names adapt the
+	// REST login to remote users, the defaults are used if empty
+	UserFormat     string `yaml:"user_format"`
+	UserHeader     string `yaml:"user_header"`
+	PasswordHeader string `yaml:"password_header"`
+	TokenHeader    string `yaml:"token_header"`
+}
+
+// Backends are the supported ways of talking to a device
+var Backends = []string{"", "rest", "cim", "storage_insights"}
+
+// StorageInsights selects a storage system registered with IBM Storage
+// Insights to read instead of the device itself
+type StorageInsights struct {
+	// URL of the Storage Insights REST API, defaults to
+	// https://insights.ibm.com
+	URL    string
+	Tenant string
+	// System is the ID of the storage system in Storage Insights
+	System string
+	APIKey string `yaml:"api_key"`
+}
+
+func (s *StorageInsights) Validate() error {
+	if s.Tenant == "" || s.System == "" || s.APIKey == "" {
+		return fmt.Errorf("storage_insights requires tenant, system and api_key")
+	}
+	return nil
+}
+
+func (a *Auth) Validate() error {
+	if a.Password != "" && a.PasswordFile != "" {
+		return fmt.Errorf("both password and password_file given")
+	}
+	if a.UserFormat != "" && !strings.Contains(a.UserFormat, "{user}") {
+		return fmt.Errorf("user_format %q does not contain {user}", a.UserFormat)
+	}
+	for _, h := range []string{a.UserHeader, a.PasswordHeader, a.TokenHeader} {
+		if h != "" && !headerNameRE.MatchString(h) {
+			return fmt.Errorf("invalid header name %q", h)
+		}
+	}
+	if a.Backend == "storage_insights" {
+		if a.StorageInsights == nil {
+			return fmt.Errorf("backend storage_insights without storage_insights")
+		}
+		if err := a.StorageInsights.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, b := range Backends {
+		if a.Backend == b {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown backend %q", a.Backend)
+}
+
+// GetPassword returns the password, reading it from PasswordFile if set.
+// Trailing newlines of the file are not part of the password.
+func (a *Auth) GetPassword() (string, error) {
+	if a.PasswordFile == "" {
+		return a.Password, nil
+	}
+	b, err := ioutil.ReadFile(a.PasswordFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// AuthMap maps target URLs to their credentials
+type AuthMap map[string]Auth
+
+// Config is the exporter configuration loaded from -config-file.
+type Config struct {
+	Aggregations []AggregationRule
+	// Filters are keyed on collector name, e.g. "pool"
+	Filters map[string]*ObjectFilter
+	// Modules are selected with the module parameter of /probe
+	Modules map[string]*Module
+	// Metrics select the exported metrics by name, keyed on collector name
+	Metrics map[string]*MetricFilter
+	// Web configures the HTTP server of the exporter, applied on reload
+	Web Web
+	// Priorities classify the collectors to keep the critical ones within
+	// the probe timeout
+	Priorities Priorities
+	// SystemName is SystemNameLabel to label all metrics of a probe with
+	// the name of the system, or SystemNameInfo to only export it in an
+	// info metric. The name is not fetched if empty.
+	SystemName string `yaml:"system_name"`
+	// RequestHeaders are added to all requests to the targets, e.g. to
+	// attribute the requests in the audit logs of the devices
+	RequestHeaders map[string]string `yaml:"request_headers"`
+}
+
+// Ways of exporting the system name
+const (
+	SystemNameLabel = "label"
+	SystemNameInfo  = "info"
+)
+
+// Priority classes of the collectors
+const (
+	PriorityCritical = "critical"
+	PriorityOptional = "optional"
+)
+
+// Priorities classify the collectors as critical or optional, optional
+// collectors are skipped when the probe is running out of time
+type Priorities struct {
+	// Collectors map collector names to their priority class, collectors
+	// not listed are critical
+	Collectors map[string]string
+	// MinRemaining is the time left of the probe timeout below which the
+	// optional collectors are skipped
+	MinRemaining time.Duration `yaml:"min_remaining"`
+}
+
+func (p *Priorities) Validate() error {
+	if p.MinRemaining < 0 {
+		return fmt.Errorf("negative min_remaining %v", p.MinRemaining)
+	}
+	for c, class := range p.Collectors {
+		if class != PriorityCritical && class != PriorityOptional {
+			return fmt.Errorf("unknown priority %q of collector %q, expected critical or optional", class, c)
+		}
+	}
+	return nil
+}
+
+// Optional returns the names of the optional collectors
+func (p *Priorities) Optional() []string {
+	var r []string
+	for c, class := range p.Collectors {
+		if class == PriorityOptional {
+			r = append(r, c)
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+// Web configures the HTTP server, it is rebound when changed on reload
+type Web struct {
+	// Listen overrides the -listen flag if set
+	Listen string
+	// TLSCertFile and TLSKeyFile enable HTTPS when set
+	TLSCertFile string `yaml:"tls_cert_file"`
+	TLSKeyFile  string `yaml:"tls_key_file"`
+}
+
+func (w *Web) Validate() error {
+	if (w.TLSCertFile == "") != (w.TLSKeyFile == "") {
+		return fmt.Errorf("tls_cert_file and tls_key_file must be given together")
+	}
+	return nil
+}
+
+// MetricFilter suppresses metrics of a collector. A metric is exported if
+// it is listed in Allow, or Allow is empty, and it is not listed in Deny.
+type MetricFilter struct {
+	Allow []string
+	Deny  []string
+}
+
+func (f *MetricFilter) Validate() error {
+	for _, n := range append(append([]string{}, f.Allow...), f.Deny...) {
+		if !metricNameRE.MatchString(n) {
+			return fmt.Errorf("invalid metric name %q", n)
+		}
+	}
+	return nil
+}
+
+// Match returns true if the metric called name should be exported. A nil
+// filter matches everything.
+func (f *MetricFilter) Match(name string) bool {
+	if f == nil {
+		return true
+	}
+	for _, n := range f.Deny {
+		if n == name {
+			return false
+		}
+	}
+	if len(f.Allow) == 0 {
+		return true
+	}
+	for _, n := range f.Allow {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Module defines what is collected by a probe and how
+type Module struct {
+	// Collectors to run, all default collectors if empty. OptIn collectors
+	// are run when listed.
+	Collectors []string
+	// Timeout of the probe, capped by -scrape-timeout
+	Timeout time.Duration
+	// Labels are added to all metrics of the probe
+	Labels map[string]string
+}
+
+func (m *Module) Validate() error {
+	if m.Timeout < 0 {
+		return fmt.Errorf("negative timeout %v", m.Timeout)
+	}
+	for l := range m.Labels {
+		if !labelNameRE.MatchString(l) {
+			return fmt.Errorf("invalid label name %q", l)
+		}
+	}
+	return nil
+}
+
+// AggregationRule computes a new metric called Name from the series of
+// Metric, grouped by the labels in By.
+type AggregationRule struct {
+	Name   string
+	Metric string
+	Op     string
+	By     []string
+}
+
+// AggregationOps are the supported aggregation operations
+var AggregationOps = []string{"sum", "count", "min", "max", "avg"}
+
+func (r *AggregationRule) Validate() error {
+	if !metricNameRE.MatchString(r.Name) {
+		return fmt.Errorf("invalid metric name %q", r.Name)
+	}
+	if r.Metric == "" {
+		return fmt.Errorf("rule %q has no source metric", r.Name)
+	}
+	for _, op := range AggregationOps {
+		if r.Op == op {
+			return nil
+		}
+	}
+	return fmt.Errorf("rule %q has unknown op %q", r.Name, r.Op)
+}
+
+func (c *Config) Validate() error {
+	if err := c.Web.Validate(); err != nil {
+		return fmt.Errorf("web: %v", err)
+	}
+	for name, f := range c.Filters {
+		if err := f.Compile(); err != nil {
+			return fmt.Errorf("filters: %s: %v", name, err)
+		}
+	}
+	for i := range c.Aggregations {
+		if err := c.Aggregations[i].Validate(); err != nil {
+			return fmt.Errorf("aggregations: %v", err)
+		}
+	}
+	for name, f := range c.Metrics {
+		if err := f.Validate(); err != nil {
+			return fmt.Errorf("metrics: %s: %v", name, err)
+		}
+	}
+	if err := c.Priorities.Validate(); err != nil {
+		return fmt.Errorf("priorities: %v", err)
+	}
+	for h := range c.RequestHeaders {
+		if !headerNameRE.MatchString(h) {
+			return fmt.Errorf("request_headers: invalid header name %q", h)
+		}
+	}
+	if c.SystemName != "" && c.SystemName != SystemNameLabel && c.SystemName != SystemNameInfo {
+		return fmt.Errorf("unknown system_name %q, expected label or info", c.SystemName)
+	}
+	for name, m := range c.Modules {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("modules: %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Load reads and validates the authentication map and the optional
+// exporter configuration file.
+func Load(authFile string, configFile string) (AuthMap, *Config, error) {
+	am := AuthMap{}
+	af, err := ioutil.ReadFile(authFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read API authentication map file: %v", err)
+	}
+	if err := yaml.Unmarshal(af, &am); err != nil {
+		return nil, nil, fmt.Errorf("Failed to parse API authentication map file: %v", err)
+	}
+	for tgt, a := range am {
+		if err := a.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("Invalid API authentication for %q: %v", tgt, err)
+		}
+	}
+
+	cfg := &Config{}
+	if configFile != "" {
+		cf, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to read config file: %v", err)
+		}
+		if err := yaml.UnmarshalStrict(cf, cfg); err != nil {
+			return nil, nil, fmt.Errorf("Failed to parse config file: %v", err)
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("Invalid config file: %v", err)
+		}
+	}
+	return am, cfg, nil
+}
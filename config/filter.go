@@ -0,0 +1,64 @@
+// Filtering of exported objects by name
+//
+// Copyright (C) 2020  Christian Svensson
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package config
+
+import (
+	"regexp"
+)
+
+// ObjectFilter selects which objects of a collector are exported based on
+// their name. An object is exported if it matches Include (when set) and
+// does not match Exclude (when set).
+type ObjectFilter struct {
+	Include string
+	Exclude string
+
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+// Compile compiles the regular expressions, it must be called before Match
+func (f *ObjectFilter) Compile() error {
+	var err error
+	if f.Include != "" {
+		if f.include, err = regexp.Compile(f.Include); err != nil {
+			return err
+		}
+	}
+	if f.Exclude != "" {
+		if f.exclude, err = regexp.Compile(f.Exclude); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match returns true if the object should be exported. A nil filter
+// matches everything.
+func (f *ObjectFilter) Match(name string) bool {
+	if f == nil {
+		return true
+	}
+	if f.include != nil && !f.include.MatchString(name) {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return false
+	}
+	return true
+}
@@ -4,7 +4,10 @@ go 1.16
 
 require (
 	github.com/alecthomas/units v0.0.0-20190924025748-f65c72e2690d
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/google/go-jsonnet v0.17.0
 	github.com/prometheus/client_golang v1.10.0
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.18.0
 	gopkg.in/yaml.v2 v2.4.0
 )